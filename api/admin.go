@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/likimiad/car-management-api/internal/catalog"
+	"github.com/likimiad/car-management-api/internal/enrichment"
+	"net/http"
+)
+
+// AdminToken returns the bearer token an administrator sends, with their name
+// in X-Actor, to reach the admin and audit routes and includeDeleted. It is
+// derived from SECRET_KEY, so rotating the key revokes every token.
+func AdminToken(secretKey, actor string) string {
+	return adminToken([]byte(secretKey), actor)
+}
+
+func adminToken(key []byte, actor string) string {
+	mac := hmac.New(sha256.New, key)
+	// The prefix keeps the tokens apart from page cursors, which are signed
+	// with the same key.
+	mac.Write([]byte("admin:" + actor))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+type upstreamStatus struct {
+	Providers []enrichment.BreakerState `json:"providers"`
+	Cache     *enrichment.CacheStats    `json:"cache,omitempty"`
+}
+
+type syncStatus struct {
+	Current *catalog.Run `json:"current"`
+	Last    *catalog.Run `json:"last"`
+}
+
+// @Summary Get upstream status
+// @Description Get the circuit breaker state of every third party car info provider and the lookup cache statistics
+// @Tags admin
+// @Produce json
+// @Param X-Actor       header string true "Administrator listed in ADMIN_ACTORS"
+// @Param Authorization header string true "Bearer token of the administrator, printed by -admin-token"
+// @Success 200 {object} upstreamStatus
+// @Failure 403 {string} string "Not an administrator"
+// @Router /api/admin/upstream [get]
+func (s *Server) handleGetUpstream() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		status := upstreamStatus{Providers: []enrichment.BreakerState{}}
+		if reporter, ok := s.Enricher.(enrichment.HealthReporter); ok {
+			status.Providers = append(status.Providers, reporter.Health()...)
+		}
+		if reporter, ok := s.Enricher.(enrichment.CacheStatsReporter); ok {
+			stats := reporter.CacheStats()
+			status.Cache = &stats
+		}
+
+		s.respondAny(w, http.StatusOK, status)
+	}
+}
+
+// @Summary Start a catalog sync
+// @Description Start importing and updating cars from the upstream catalog in the background. Progress is reported by GET /api/admin/sync
+// @Tags admin
+// @Produce json
+// @Param flagMissing query bool false "Flag stored cars no longer listed upstream (defaults to SYNC_FLAG_MISSING)"
+// @Param X-Actor       header string true "Administrator listed in ADMIN_ACTORS"
+// @Param Authorization header string true "Bearer token of the administrator, printed by -admin-token"
+// @Success 202 {object} catalog.Run
+// @Failure 403 {string} string "Not an administrator"
+// @Failure 409 {object} map[string]interface{} "A sync is already running"
+// @Failure 503 {object} map[string]interface{} "The server is shutting down"
+// @Router /api/admin/sync [post]
+func (s *Server) handlePostSync() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		flagMissing := s.SyncFlagMissing
+		switch r.URL.Query().Get("flagMissing") {
+		case "":
+		case "true":
+			flagMissing = true
+		case "false":
+			flagMissing = false
+		default:
+			s.respondWithError(w, http.StatusBadRequest, "flagMissing must be true or false")
+			return
+		}
+
+		run, err := s.Syncer.Start(context.WithoutCancel(r.Context()), catalog.TriggerManual, flagMissing)
+		if errors.Is(err, catalog.ErrSyncRunning) {
+			s.respondWithError(w, http.StatusConflict, err.Error())
+			return
+		} else if errors.Is(err, catalog.ErrSyncStopped) {
+			s.respondWithError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+
+		s.respondAny(w, http.StatusAccepted, run)
+	}
+}
+
+// @Summary Get catalog sync status
+// @Description Get the progress of the running catalog sync and the diff summary of the last finished one
+// @Tags admin
+// @Produce json
+// @Param X-Actor       header string true "Administrator listed in ADMIN_ACTORS"
+// @Param Authorization header string true "Bearer token of the administrator, printed by -admin-token"
+// @Success 200 {object} syncStatus
+// @Failure 403 {string} string "Not an administrator"
+// @Router /api/admin/sync [get]
+func (s *Server) handleGetSync() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		current, last := s.Syncer.Status()
+		s.respondAny(w, http.StatusOK, syncStatus{Current: current, Last: last})
+	}
+}
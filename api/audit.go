@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"github.com/likimiad/car-management-api/internal/database"
+	"net/http"
+	"time"
+)
+
+// @Summary Get audit log
+// @Description Get recorded mutations of cars and owners, newest first, with optional filtering and pagination
+// @Tags audit
+// @Accept  json
+// @Produce  json
+// @Param   entity     query     string  false  "Filter by entity (car, owner)"
+// @Param   entityId   query     int     false  "Filter by entity ID"
+// @Param   operation  query     string  false  "Filter by operation, e.g. car.update"
+// @Param   actor      query     string  false  "Filter by actor"
+// @Param   requestId  query     string  false  "Filter by request ID"
+// @Param   from       query     string  false  "Only entries at or after this moment, RFC 3339 or YYYY-MM-DD"
+// @Param   to         query     string  false  "Only entries before this moment, RFC 3339 or YYYY-MM-DD"
+// @Param   limit      query     int     false  "Limit number of entries returned"
+// @Param   offset     query     int     false  "Offset where to start fetching entries"
+// @Param   X-Actor        header string true "Administrator listed in ADMIN_ACTORS"
+// @Param   Authorization  header string true "Bearer token of the administrator, printed by -admin-token"
+// @Success 200 {array} database.AuditEntry
+// @Failure 400 {string} string "Invalid filter"
+// @Failure 403 {string} string "Not an administrator"
+// @Failure 500 {string} string "Server error"
+// @Router /api/audit [get]
+func (s *Server) handleGetAudit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		query := r.URL.Query()
+		filter := database.AuditFilter{
+			Entity:    query.Get("entity"),
+			EntityID:  queryInt(r, "entityId", 0),
+			Operation: query.Get("operation"),
+			Actor:     query.Get("actor"),
+			RequestID: query.Get("requestId"),
+			Limit:     queryInt(r, "limit", 10),
+			Offset:    queryInt(r, "offset", 0),
+		}
+		if filter.Limit < 0 {
+			s.respondWithError(w, http.StatusBadRequest, "limit cannot be negative")
+			return
+		}
+		if filter.Offset < 0 {
+			s.respondWithError(w, http.StatusBadRequest, "offset cannot be negative")
+			return
+		}
+		for key, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+			if value := query.Get(key); value != "" {
+				t, err := parseTime(value)
+				if err != nil {
+					s.respondWithError(w, http.StatusBadRequest, "invalid date in "+key+" parameter")
+					return
+				}
+				*dst = &t
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+		defer cancel()
+
+		entries, err := s.DB.GridAuditLog(ctx, filter)
+		if err != nil {
+			s.logError(r.Context(), err)
+			s.respondWithError(w, http.StatusInternalServerError, "server error")
+			return
+		}
+
+		s.respondAny(w, http.StatusOK, entries)
+	}
+}
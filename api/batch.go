@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/database"
+	"net/http"
+	"net/url"
+)
+
+// maxBatchSize caps the number of cars a batch request can touch, listed or
+// matched by its filter.
+const maxBatchSize = 1000
+
+// batchRequest selects the cars of a batch either by ID or by a filter
+// written as the query string of GET /api/cars, e.g. "mark=Toyota&yearTo=2005".
+type batchRequest struct {
+	IDs    []int  `json:"ids,omitempty"`
+	Filter string `json:"filter,omitempty"`
+	Atomic bool   `json:"atomic,omitempty"`
+	DryRun bool   `json:"dryRun,omitempty"`
+}
+
+type batchUpdateRequest struct {
+	batchRequest
+	Set database.CarPatch `json:"set"`
+}
+
+type batchResponse struct {
+	DryRun bool                 `json:"dryRun"`
+	Atomic bool                 `json:"atomic"`
+	Items  []database.BatchItem `json:"items"`
+}
+
+// batchFilterExcluded lists the car list parameters that make no sense for
+// selecting the cars of a batch.
+var batchFilterExcluded = []string{"limit", "offset", "cursor", "total", "sort"}
+
+// @Summary Delete cars in a batch
+// @Description Soft-delete the listed cars or the cars matching a filter written as the query string of
+// @Description GET /api/cars. Atomic batches delete every car or none; otherwise every car is deleted on its
+// @Description own. A dry run reports what would be deleted. The response is 200 when every car was deleted
+// @Description and 207 otherwise.
+// @Tags cars
+// @Accept json
+// @Produce json
+// @Param batch body batchRequest true "Car IDs or filter, atomic and dryRun flags"
+// @Success 200 {object} batchResponse "Every car was deleted"
+// @Success 207 {object} batchResponse "Some cars were not found or failed"
+// @Failure 400 {string} string "Bad request"
+// @Failure 500 {string} string "Server error"
+// @Router /api/cars:batchDelete [post]
+func (s *Server) handleBatchDeleteCars() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var req batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "error parsing batch request")
+			return
+		}
+
+		ids, ok := s.batchIDs(r.Context(), w, req)
+		if !ok {
+			return
+		}
+
+		liftWriteDeadline(w)
+		items, err := s.DB.BatchDeleteCars(r.Context(), ids, database.BatchOptions{Atomic: req.Atomic, DryRun: req.DryRun})
+		if err != nil {
+			s.logError(r.Context(), err)
+			s.respondWithError(w, http.StatusInternalServerError, "error while deleting cars")
+			return
+		}
+
+		s.respondBatch(w, req, database.BatchDeleted, items)
+	}
+}
+
+// @Summary Update cars in a batch
+// @Description Apply the same change to the listed cars or the cars matching a filter written as the query
+// @Description string of GET /api/cars; empty fields of set are left alone. Atomic batches update every car or
+// @Description none; otherwise every car is updated on its own. A dry run reports every car before and after
+// @Description the change. The response is 200 when every car was updated and 207 otherwise.
+// @Tags cars
+// @Accept json
+// @Produce json
+// @Param batch body batchUpdateRequest true "Car IDs or filter, the change, atomic and dryRun flags"
+// @Success 200 {object} batchResponse "Every car was updated"
+// @Success 207 {object} batchResponse "Some cars were not found or failed"
+// @Failure 400 {string} string "Bad request"
+// @Failure 500 {string} string "Server error"
+// @Router /api/cars:batchUpdate [patch]
+func (s *Server) handleBatchUpdateCars() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var req batchUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "error parsing batch request")
+			return
+		}
+		if req.Set == (database.CarPatch{}) {
+			s.respondWithError(w, http.StatusBadRequest, "set must change at least one field")
+			return
+		}
+		if req.Set.OwnerID != 0 && !s.DB.OwnerExists(r.Context(), req.Set.OwnerID) {
+			s.respondWithError(w, http.StatusBadRequest, "owner does not exist")
+			return
+		}
+
+		ids, ok := s.batchIDs(r.Context(), w, req.batchRequest)
+		if !ok {
+			return
+		}
+
+		liftWriteDeadline(w)
+		items, err := s.DB.BatchUpdateCars(r.Context(), ids, req.Set, database.BatchOptions{Atomic: req.Atomic, DryRun: req.DryRun})
+		if err != nil {
+			s.logError(r.Context(), err)
+			s.respondWithError(w, http.StatusInternalServerError, "error while updating cars")
+			return
+		}
+
+		s.respondBatch(w, req.batchRequest, database.BatchUpdated, items)
+	}
+}
+
+// batchIDs resolves the cars a batch applies to, without repeats and in the
+// order given or listed, answering the client itself when it cannot.
+func (s *Server) batchIDs(ctx context.Context, w http.ResponseWriter, req batchRequest) ([]int, bool) {
+	if (len(req.IDs) > 0) == (req.Filter != "") {
+		s.respondWithError(w, http.StatusBadRequest, "exactly one of ids and filter is required")
+		return nil, false
+	}
+
+	if len(req.IDs) > 0 {
+		if len(req.IDs) > maxBatchSize {
+			s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("a batch cannot have more than %d cars", maxBatchSize))
+			return nil, false
+		}
+		seen := map[int]bool{}
+		var ids []int
+		for _, id := range req.IDs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		return ids, true
+	}
+
+	query, err := url.ParseQuery(req.Filter)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "invalid filter")
+		return nil, false
+	}
+	for _, key := range batchFilterExcluded {
+		if query.Has(key) {
+			s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s cannot be used in a batch filter", key))
+			return nil, false
+		}
+	}
+	filter, err := parseCarFilter(query)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	// Deleted cars cannot be changed; matching them would only fail.
+	filter.IncludeDeleted = false
+	filter.Limit = maxBatchSize + 1
+
+	cars, err := s.DB.GridCarInfo(ctx, filter)
+	if err != nil {
+		s.logError(ctx, err)
+		s.respondWithError(w, http.StatusInternalServerError, "server error")
+		return nil, false
+	}
+	if len(cars) > maxBatchSize {
+		s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("filter matches more than %d cars", maxBatchSize))
+		return nil, false
+	}
+	ids := make([]int, len(cars))
+	for i, car := range cars {
+		ids[i] = car.ID
+	}
+	return ids, true
+}
+
+func (s *Server) respondBatch(w http.ResponseWriter, req batchRequest, status string, items []database.BatchItem) {
+	httpStatus := http.StatusOK
+	for _, item := range items {
+		if item.Status != status {
+			httpStatus = http.StatusMultiStatus
+		}
+	}
+	if items == nil {
+		items = []database.BatchItem{}
+	}
+	s.respondAny(w, httpStatus, batchResponse{DryRun: req.DryRun, Atomic: req.Atomic, Items: items})
+}
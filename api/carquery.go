@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/database"
+	"github.com/likimiad/car-management-api/internal/plates"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// carListParams whitelists the query parameters of GET /api/cars.
+var carListParams = map[string]bool{
+	"mark": true, "model": true, "year": true, "yearFrom": true, "yearTo": true,
+	"regNum": true, "ownerName": true, "ownerSurname": true, "sort": true, "includeDeleted": true,
+	"limit": true, "offset": true, "cursor": true, "total": true,
+}
+
+// parseCarFilter translates the query of a car listing into a filter. Unknown
+// parameters, sort fields and malformed values are reported as errors meant
+// for the client.
+func parseCarFilter(query url.Values) (database.CarFilter, error) {
+	var unknown []string
+	for key := range query {
+		if !carListParams[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return database.CarFilter{}, fmt.Errorf("unknown query parameter: %s", strings.Join(unknown, ", "))
+	}
+
+	var regNums []string
+	for _, prefix := range splitList(query.Get("regNum")) {
+		if prefix = plates.Normalize(prefix); prefix != "" {
+			regNums = append(regNums, prefix)
+		}
+	}
+
+	filter := database.CarFilter{
+		Marks:          splitList(query.Get("mark")),
+		Models:         splitList(query.Get("model")),
+		RegNumPrefixes: regNums,
+		OwnerNames:     splitList(query.Get("ownerName")),
+		OwnerSurnames:  splitList(query.Get("ownerSurname")),
+		IncludeDeleted: query.Get("includeDeleted") == "true",
+	}
+
+	for _, value := range splitList(query.Get("year")) {
+		year, err := strconv.Atoi(value)
+		if err != nil {
+			return database.CarFilter{}, fmt.Errorf("invalid year: %s", value)
+		}
+		filter.Years = append(filter.Years, year)
+	}
+	var err error
+	if filter.YearFrom, err = intParam(query, "yearFrom"); err != nil {
+		return database.CarFilter{}, err
+	}
+	if filter.YearTo, err = intParam(query, "yearTo"); err != nil {
+		return database.CarFilter{}, err
+	}
+	if filter.YearFrom != 0 && filter.YearTo != 0 && filter.YearFrom > filter.YearTo {
+		return database.CarFilter{}, fmt.Errorf("yearFrom cannot be after yearTo")
+	}
+
+	for _, field := range splitList(query.Get("sort")) {
+		s := database.CarSort{Field: strings.TrimPrefix(field, "+")}
+		if strings.HasPrefix(field, "-") {
+			s = database.CarSort{Field: field[1:], Desc: true}
+		}
+		if !database.IsCarSortField(s.Field) {
+			return database.CarFilter{}, fmt.Errorf("unknown sort field: %s", s.Field)
+		}
+		filter.Sort = append(filter.Sort, s)
+	}
+	return filter, nil
+}
+
+// splitList splits a comma separated parameter, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func intParam(query url.Values, key string) (int, error) {
+	value := query.Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", key, value)
+	}
+	return n, nil
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"github.com/likimiad/car-management-api/internal/database"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseCarFilter(t *testing.T) {
+	tests := []struct {
+		query string
+		want  database.CarFilter
+		err   string
+	}{
+		{query: "", want: database.CarFilter{}},
+		{
+			query: "mark=Toyota,%20Honda,&model=corolla&ownerName=ivan&ownerSurname=petrov",
+			want: database.CarFilter{
+				Marks: []string{"Toyota", "Honda"}, Models: []string{"corolla"},
+				OwnerNames: []string{"ivan"}, OwnerSurnames: []string{"petrov"},
+			},
+		},
+		{query: "regNum=A1,%20B2", want: database.CarFilter{RegNumPrefixes: []string{"A1", "B2"}}},
+		{query: "regNum=x%20123,%D0%90-1", want: database.CarFilter{RegNumPrefixes: []string{"X123", "A1"}}},
+		{query: "year=2005,2010&yearFrom=2000&yearTo=2020", want: database.CarFilter{Years: []int{2005, 2010}, YearFrom: 2000, YearTo: 2020}},
+		{query: "includeDeleted=true", want: database.CarFilter{IncludeDeleted: true}},
+		{query: "includeDeleted=yes", want: database.CarFilter{}},
+		{
+			query: "sort=year,-mark,%2BownerSurname",
+			want:  database.CarFilter{Sort: []database.CarSort{{Field: "year"}, {Field: "mark", Desc: true}, {Field: "ownerSurname"}}},
+		},
+		{query: "limit=5&offset=10&cursor=abc&total=true", want: database.CarFilter{}},
+		{query: "colour=red", err: "unknown query parameter: colour"},
+		{query: "zeta=1&alpha=2&mark=ford", err: "unknown query parameter: alpha, zeta"},
+		{query: "sort=-owner_id", err: "unknown sort field: owner_id"},
+		{query: "sort=year,price", err: "unknown sort field: price"},
+		{query: "year=2005,new", err: "invalid year: new"},
+		{query: "yearFrom=old", err: "invalid yearFrom: old"},
+		{query: "yearFrom=2010&yearTo=2005", err: "yearFrom cannot be after yearTo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseCarFilter(query)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filter = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCarsRejectsUnknownQuery(t *testing.T) {
+	server := newPagingServer(t, 1)
+	for _, query := range []string{"colour=red", "sort=price", "sort=-owner_id", "yearFrom=x"} {
+		t.Run(query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			server.handleGetCars().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/cars?"+query, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/database"
+	"strings"
+)
+
+const (
+	cursorNext = "next"
+	cursorPrev = "prev"
+)
+
+var (
+	ErrInvalidCursor  = errors.New("invalid cursor")
+	ErrCursorMismatch = errors.New("cursor does not match the filters of the request")
+)
+
+// pageCursor points at the edge of a page of cars by its sort key. It is
+// handed out signed so that clients cannot forge positions, and it remembers
+// the filters it was issued for so it is not replayed against a different
+// result set.
+type pageCursor struct {
+	Direction string          `json:"d"`
+	Key       database.CarKey `json:"k"`
+	Filter    string          `json:"f"`
+}
+
+type pageInfo struct {
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Limit int    `json:"limit"`
+	Total *int   `json:"total,omitempty"`
+}
+
+func (s *Server) encodeCursor(c pageCursor) string {
+	payload, _ := json.Marshal(c)
+	mac := hmac.New(sha256.New, s.SecretKey)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Server) decodeCursor(token string, filter database.CarFilter) (pageCursor, error) {
+	payloadStr, sigStr, ok := strings.Cut(token, ".")
+	if !ok {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(payloadStr)
+	if err != nil {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sigStr)
+	if err != nil {
+		return pageCursor{}, ErrInvalidCursor
+	}
+
+	mac := hmac.New(sha256.New, s.SecretKey)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return pageCursor{}, ErrInvalidCursor
+	}
+
+	var c pageCursor
+	if err := json.Unmarshal(payload, &c); err != nil || (c.Direction != cursorNext && c.Direction != cursorPrev) {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	if c.Filter != filterFingerprint(filter) {
+		return pageCursor{}, ErrCursorMismatch
+	}
+	if c.Key, err = filter.NormalizeKey(c.Key); err != nil {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// filterFingerprint identifies the result set and order of a filter, leaving
+// out its page bounds.
+func filterFingerprint(filter database.CarFilter) string {
+	filter.After, filter.Before, filter.Limit, filter.Offset = nil, nil, 0, 0
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%#v", filter)))
+	return hex.EncodeToString(sum[:8])
+}
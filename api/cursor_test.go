@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/database"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	server := &Server{SecretKey: []byte("secret")}
+	filter := database.CarFilter{Marks: []string{"toyota"}, Sort: []database.CarSort{{Field: "year", Desc: true}}}
+	want := pageCursor{Direction: cursorNext, Key: database.CarKey{2015, 7}, Filter: filterFingerprint(filter)}
+
+	got, err := server.decodeCursor(server.encodeCursor(want), filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded %+v, want %+v", got, want)
+	}
+}
+
+func TestCursorRejectsTampering(t *testing.T) {
+	server := &Server{SecretKey: []byte("secret")}
+	filter := database.CarFilter{Sort: []database.CarSort{{Field: "year"}}}
+	token := server.encodeCursor(pageCursor{Direction: cursorNext, Key: database.CarKey{2010, 3}, Filter: filterFingerprint(filter)})
+	payload, sig, _ := strings.Cut(token, ".")
+
+	forged, _ := json.Marshal(pageCursor{Direction: cursorNext, Key: database.CarKey{2010, 1}, Filter: filterFingerprint(filter)})
+	flipped := []byte(sig)
+	if flipped[0] == 'A' {
+		flipped[0] = 'B'
+	} else {
+		flipped[0] = 'A'
+	}
+	sign := func(direction string, key database.CarKey) string {
+		return server.encodeCursor(pageCursor{Direction: direction, Key: key, Filter: filterFingerprint(filter)})
+	}
+	tests := map[string]string{
+		"empty":             "",
+		"no signature":      payload,
+		"forged payload":    base64.RawURLEncoding.EncodeToString(forged) + "." + sig,
+		"altered signature": payload + "." + string(flipped),
+		"not base64":        "!!." + sig,
+		"other secret":      (&Server{SecretKey: []byte("other")}).encodeCursor(pageCursor{Direction: cursorNext, Key: database.CarKey{2010, 3}, Filter: filterFingerprint(filter)}),
+		"unknown direction": sign("sideways", database.CarKey{2010, 3}),
+		"short key":         sign(cursorNext, database.CarKey{3}),
+		"key of wrong type": sign(cursorNext, database.CarKey{"2010", 3}),
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := server.decodeCursor(token, filter); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestCursorFilterMismatch(t *testing.T) {
+	server := &Server{SecretKey: []byte("secret")}
+	issued := database.CarFilter{Marks: []string{"toyota"}, Sort: []database.CarSort{{Field: "year"}}, Limit: 10}
+	token := server.encodeCursor(pageCursor{Direction: cursorNext, Key: database.CarKey{2010, 3}, Filter: filterFingerprint(issued)})
+
+	tests := []struct {
+		name   string
+		filter database.CarFilter
+		want   error
+	}{
+		{"same filter, other page size", database.CarFilter{Marks: []string{"toyota"}, Sort: []database.CarSort{{Field: "year"}}, Limit: 50}, nil},
+		{"other mark", database.CarFilter{Marks: []string{"ford"}, Sort: []database.CarSort{{Field: "year"}}}, ErrCursorMismatch},
+		{"other direction", database.CarFilter{Marks: []string{"toyota"}, Sort: []database.CarSort{{Field: "year", Desc: true}}}, ErrCursorMismatch},
+		{"with deleted", database.CarFilter{Marks: []string{"toyota"}, Sort: []database.CarSort{{Field: "year"}}, IncludeDeleted: true}, ErrCursorMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := server.decodeCursor(token, tt.filter); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// newPagingServer serves a memory store holding the given number of cars.
+func newPagingServer(t *testing.T, cars int) *Server {
+	t.Helper()
+	store := database.NewMemoryStore()
+	ctx := context.Background()
+	ownerID, err := store.GetOrCreateOwner(ctx, database.Owner{Name: "Anna", Surname: "Sidorova"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < cars; i++ {
+		regNum := fmt.Sprintf("A%03dAA150", i)
+		if _, err := store.AddNewCar(ctx, regNum, "Lada", "Granta", 2010+i%2, ownerID, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Server{DB: store, Timeout: time.Second, SecretKey: []byte("secret")}
+}
+
+type carPage struct {
+	Result []database.Car `json:"result"`
+	Page   pageInfo       `json:"page"`
+}
+
+func getCarPage(t *testing.T, server *Server, query url.Values) carPage {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	server.handleGetCars().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/cars?"+query.Encode(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var page carPage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+func pageIDs(cars []database.Car) []int {
+	ids := make([]int, len(cars))
+	for i, car := range cars {
+		ids[i] = car.ID
+	}
+	return ids
+}
+
+func TestCursorPaging(t *testing.T) {
+	server := newPagingServer(t, 6)
+	query := url.Values{"sort": {"-year"}, "limit": {"2"}}
+	want := pageIDs(getCarPage(t, server, url.Values{"sort": {"-year"}, "limit": {"10"}}).Result)
+	if len(want) != 6 {
+		t.Fatalf("listing has %d cars, want 6", len(want))
+	}
+
+	var pages []carPage
+	for page := getCarPage(t, server, query); ; {
+		pages = append(pages, page)
+		if page.Page.Next == "" {
+			break
+		}
+		query.Set("cursor", page.Page.Next)
+		page = getCarPage(t, server, query)
+	}
+	if len(pages) != 3 {
+		t.Fatalf("walked %d pages forward, want 3", len(pages))
+	}
+	if pages[0].Page.Prev != "" {
+		t.Error("the first page links to a previous one")
+	}
+	var forward []int
+	for _, page := range pages {
+		forward = append(forward, pageIDs(page.Result)...)
+	}
+	if !reflect.DeepEqual(forward, want) {
+		t.Errorf("walked forward through %v, want %v", forward, want)
+	}
+
+	page := pages[len(pages)-1]
+	backward := pageIDs(page.Result)
+	for page.Page.Prev != "" {
+		query.Set("cursor", page.Page.Prev)
+		page = getCarPage(t, server, query)
+		if page.Page.Next == "" {
+			t.Error("a page reached backwards does not link forward")
+		}
+		backward = append(pageIDs(page.Result), backward...)
+	}
+	if !reflect.DeepEqual(backward, want) {
+		t.Errorf("walked backward through %v, want %v", backward, want)
+	}
+
+	query.Set("cursor", pages[1].Page.Next)
+	query.Set("mark", "toyota")
+	rec := httptest.NewRecorder()
+	server.handleGetCars().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/cars?"+query.Encode(), nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("cursor replayed with another filter: status %d, want 400", rec.Code)
+	}
+}
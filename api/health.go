@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/likimiad/car-management-api/internal/config"
+	"github.com/likimiad/car-management-api/internal/database"
+	"github.com/likimiad/car-management-api/internal/enrichment"
+	"github.com/likimiad/car-management-api/internal/health"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	checkDatabase   = "database"
+	checkMigrations = "migrations"
+	checkUpstream   = "upstream"
+)
+
+var healthChecks = []string{checkDatabase, checkMigrations, checkUpstream}
+
+// upstreamHealth is the circuit breaker of a provider along with the outcome
+// of probing its API.
+type upstreamHealth struct {
+	enrichment.BreakerState
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+}
+
+// probeCache keeps the outcome of the last upstream probe for ttl, so that
+// frequent readiness checks do not each call the third party API. Breaker
+// states are still read on every check and catch failures in between.
+type probeCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	at      time.Time
+	results map[string]enrichment.ProbeResult
+}
+
+// get returns the cached probes, probing again once they are older than the
+// ttl. Concurrent callers wait for a single probe. Probes cut short by ctx are
+// not kept.
+func (c *probeCache) get(ctx context.Context, prober enrichment.Prober) map[string]enrichment.ProbeResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results != nil && time.Since(c.at) < c.ttl {
+		return c.results
+	}
+
+	results := map[string]enrichment.ProbeResult{}
+	for _, probe := range prober.Probe(ctx) {
+		results[probe.Provider] = probe
+	}
+	if ctx.Err() == nil {
+		c.results, c.at = results, time.Now()
+	}
+	return results
+}
+
+// @Summary Liveness probe
+// @Description Report that the process is up and serving requests, whatever the state of its dependencies
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /healthz [get]
+func (s *Server) handleHealthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		s.respondAny(w, http.StatusOK, map[string]string{"status": health.StatusUp})
+	}
+}
+
+// @Summary Readiness probe
+// @Description Check the database connection, the schema version and the third party APIs, reachability and
+// @Description circuit breakers, with the details of every check. Failed checks listed in HEALTH_CRITICAL make
+// @Description the service down and the response 503; other failed checks only make it degraded. The service
+// @Description is down as soon as it starts shutting down.
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report "Up or degraded"
+// @Failure 503 {object} health.Report "Down or shutting down"
+// @Router /readyz [get]
+func (s *Server) handleReadyz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		report := s.Health.Ready(r.Context())
+		status := http.StatusOK
+		if report.Status == health.StatusDown {
+			status = http.StatusServiceUnavailable
+		}
+		s.respondAny(w, status, report)
+	}
+}
+
+// healthChecker builds the readiness checks for the dependencies of the
+// server. The database checks only apply to backends able to run them.
+func (s *Server) healthChecker(cfg config.HealthConfig) *health.Checker {
+	for _, name := range cfg.Critical {
+		if !slices.Contains(healthChecks, name) {
+			s.Logger.Warn("unknown health check in HEALTH_CRITICAL", "check", name, "known", healthChecks)
+		}
+	}
+
+	s.probes.ttl = cfg.ProbeTTL
+
+	var checks []health.Check
+	if db, ok := s.DB.(database.HealthChecker); ok {
+		checks = append(checks,
+			health.Check{Name: checkDatabase, Run: func(ctx context.Context) (any, error) {
+				return nil, db.PingContext(ctx)
+			}},
+			health.Check{Name: checkMigrations, Run: func(ctx context.Context) (any, error) {
+				status, err := db.MigrationStatus(ctx)
+				if err != nil {
+					return nil, err
+				}
+				return status, status.Err()
+			}},
+		)
+	}
+	checks = append(checks, health.Check{Name: checkUpstream, Run: s.checkUpstream})
+	return health.NewChecker(cfg.Timeout, cfg.Critical, checks...)
+}
+
+// checkUpstream probes every provider, at most once per probe ttl, and fails
+// when none of them can be used: its API is unreachable or its circuit is
+// open.
+func (s *Server) checkUpstream(ctx context.Context) (any, error) {
+	var states []enrichment.BreakerState
+	if reporter, ok := s.Enricher.(enrichment.HealthReporter); ok {
+		states = reporter.Health()
+	}
+	probes := map[string]enrichment.ProbeResult{}
+	if prober, ok := s.Enricher.(enrichment.Prober); ok {
+		probes = s.probes.get(ctx, prober)
+	}
+
+	providers := make([]upstreamHealth, len(states))
+	usable := false
+	for i, state := range states {
+		probe := probes[state.Provider]
+		providers[i] = upstreamHealth{BreakerState: state, Reachable: probe.Reachable, Error: probe.Error}
+		if probe.Reachable && state.State != enrichment.BreakerOpen {
+			usable = true
+		}
+	}
+	if !usable {
+		return providers, errors.New("no third party API is usable")
+	}
+	return providers, nil
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"github.com/likimiad/car-management-api/internal/enrichment"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingProber reports a reachable provider and counts the probes.
+type countingProber struct {
+	calls atomic.Int32
+}
+
+func (p *countingProber) Probe(ctx context.Context) []enrichment.ProbeResult {
+	p.calls.Add(1)
+	return []enrichment.ProbeResult{{Provider: "primary", Reachable: ctx.Err() == nil}}
+}
+
+func TestProbeCache(t *testing.T) {
+	prober := &countingProber{}
+	cache := probeCache{ttl: 50 * time.Millisecond}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if !cache.get(ctx, prober)["primary"].Reachable {
+			t.Fatal("cached probe is not reachable")
+		}
+	}
+	if got := prober.calls.Load(); got != 1 {
+		t.Errorf("probed %d times within the ttl, want 1", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	cache.get(ctx, prober)
+	if got := prober.calls.Load(); got != 2 {
+		t.Errorf("probed %d times after the ttl, want 2", got)
+	}
+}
+
+func TestProbeCacheSkipsCanceledProbes(t *testing.T) {
+	prober := &countingProber{}
+	cache := probeCache{ttl: time.Minute}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if cache.get(canceled, prober)["primary"].Reachable {
+		t.Fatal("canceled probe is reachable")
+	}
+	if !cache.get(context.Background(), prober)["primary"].Reachable {
+		t.Error("the canceled probe was cached")
+	}
+	if got := prober.calls.Load(); got != 2 {
+		t.Errorf("probed %d times, want 2", got)
+	}
+}
@@ -2,56 +2,76 @@ package api
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"github.com/likimiad/car-management-api/internal/database"
+	"github.com/gorilla/mux"
+	"github.com/likimiad/car-management-api/internal/logging"
+	"github.com/likimiad/car-management-api/internal/plates"
 	"net/http"
-	"runtime"
+	"strconv"
+	"strings"
 	"time"
 )
 
-var ErrCarNotFound = errors.New("car with registration number not found")
+// logError logs an error met while serving a request with the request
+// logger.
+func (s *Server) logError(ctx context.Context, err error) {
+	logging.FromContext(ctx).Error("request failed", logging.Err(err))
+}
 
-func (s *Server) fetchCarInfoWithContext(ctx context.Context, regNum string, timeout time.Duration) (database.Car, error) {
-	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+// liftWriteDeadline clears the server write timeout for a response whose
+// work grows with the request, such as a batch or a list of plates to look
+// up; HTTP_WRITE_TIMEOUT is meant for single documents.
+func liftWriteDeadline(w http.ResponseWriter) {
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
 
-	req, err := http.NewRequestWithContext(ctxWithTimeout, "GET", fmt.Sprintf("%s/info?regNum=%s", s.ThirdPartyAPIURL, regNum), nil)
+// pathID parses the {id} route variable, answering 400 itself when it is
+// missing or malformed.
+func (s *Server) pathID(w http.ResponseWriter, r *http.Request, entity string) (int, bool) {
+	idStr, ok := mux.Vars(r)["id"]
+	if !ok {
+		s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("missing %s ID", entity))
+		return 0, false
+	}
+	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return database.Car{}, fmt.Errorf("failed to create request: %v", err)
+		s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s ID", entity))
+		return 0, false
 	}
+	return id, true
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return database.Car{}, fmt.Errorf("failed to fetch car info: %v", err)
+// plateError describes a rejected plate to the client.
+func (s *Server) plateError(err error) string {
+	if errors.Is(err, plates.ErrInvalidPlate) {
+		return fmt.Sprintf("registration number does not match the plate format of %s", strings.Join(s.Plates.Countries(), ", "))
 	}
-	defer resp.Body.Close()
+	return err.Error()
+}
 
-	switch resp.StatusCode {
-	case http.StatusOK:
-		var car database.Car
-		if err := json.NewDecoder(resp.Body).Decode(&car); err != nil {
-			return database.Car{}, fmt.Errorf("failed to decode car info: %v", err)
+func queryInt(r *http.Request, key string, defaultVal int) int {
+	if valStr := r.URL.Query().Get(key); valStr != "" {
+		if val, err := strconv.Atoi(valStr); err == nil {
+			return val
 		}
-		return car, nil
-	case http.StatusNotFound:
-		return database.Car{}, ErrCarNotFound
-	default:
-		return database.Car{}, fmt.Errorf("API request failed with status: %s", resp.Status)
 	}
+	return defaultVal
 }
 
-func (s *Server) debugErrorMessage(err error) {
-	pc, file, line, ok := runtime.Caller(1)
-	if ok {
-		funcName := runtime.FuncForPC(pc).Name()
-		fmt.Printf("\t[%s] Occurred in: %s\n\t\tFile: %s, Line: %d\n", "Error", funcName, file, line)
-	} else {
-		fmt.Printf("\t[%s] %s\n", "Error", err.Error())
+// parseTime accepts RFC 3339 timestamps or plain dates, the latter meaning
+// midnight UTC.
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
 	}
+	return time.Parse(time.DateOnly, value)
 }
 
-func (s *Server) workerMessage(id int, message string) {
-	fmt.Printf("\t[%s] id: %d %s\n", "WORKER", id, message)
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
 }
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/database"
+	"github.com/likimiad/car-management-api/internal/imports"
+	"net/http"
+	"strings"
+)
+
+// importErrorsShown bounds the failed items embedded in a job status; the
+// rest are listed by GET /api/imports/{id}/items.
+const importErrorsShown = 100
+
+type importRequest struct {
+	Plates []string `json:"regNums"`
+}
+
+type importStatus struct {
+	*database.ImportJob
+	Errors []database.ImportItem `json:"errors"`
+}
+
+// @Summary Start an import job
+// @Description Queue a list of plates to be looked up and added in the background. Plates are normalized and validated
+// @Description up front; a request with an invalid plate is rejected. The job ID is returned at once; progress is
+// @Description reported by GET /api/imports/{id}
+// @Tags imports
+// @Accept json
+// @Produce json
+// @Param   regNums  body  importRequest  true  "Plates to import"
+// @Success 202 {object} database.ImportJob
+// @Failure 400 {string} string "Invalid request"
+// @Failure 500 {string} string "Server error"
+// @Router /api/imports [post]
+func (s *Server) handlePostImport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var req importRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if len(req.Plates) == 0 {
+			s.respondWithError(w, http.StatusBadRequest, "regNums cannot be empty")
+			return
+		}
+		regNums := make([]string, len(req.Plates))
+		spelled := make([]string, len(req.Plates))
+		for i, plate := range req.Plates {
+			regNum, _, err := s.Plates.Validate(plate)
+			if err != nil {
+				s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("regNums[%d] %q: %s", i, plate, s.plateError(err)))
+				return
+			}
+			regNums[i], spelled[i] = regNum, strings.TrimSpace(plate)
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+		defer cancel()
+
+		job, err := s.Importer.Submit(ctx, regNums, spelled)
+		if errors.Is(err, imports.ErrTooManyPlates) {
+			s.respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		} else if err != nil {
+			s.logError(r.Context(), err)
+			s.respondWithError(w, http.StatusInternalServerError, "error while creating import job")
+			return
+		}
+
+		s.respondAny(w, http.StatusAccepted, job)
+	}
+}
+
+// @Summary Get an import job
+// @Description Get the state, progress and item counts of an import job with its first failed plates
+// @Tags imports
+// @Produce json
+// @Param id path int true "Import job ID"
+// @Success 200 {object} importStatus
+// @Failure 400 {string} string "Invalid import job ID"
+// @Failure 404 {string} string "Import job not found"
+// @Failure 500 {string} string "Server error"
+// @Router /api/imports/{id} [get]
+func (s *Server) handleGetImport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, ok := s.pathID(w, r, "import job")
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+		defer cancel()
+
+		job, err := s.DB.GetImportJob(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			s.respondWithError(w, http.StatusNotFound, "import job not found")
+			return
+		} else if err != nil {
+			s.logError(r.Context(), err)
+			s.respondWithError(w, http.StatusInternalServerError, "server error")
+			return
+		}
+
+		failed, err := s.DB.GridImportItems(ctx, id, database.ImportItemFilter{
+			Statuses: []string{database.ItemNotFound, database.ItemFailed},
+			Limit:    importErrorsShown,
+		})
+		if err != nil {
+			s.logError(r.Context(), err)
+			s.respondWithError(w, http.StatusInternalServerError, "server error")
+			return
+		}
+
+		s.respondAny(w, http.StatusOK, importStatus{ImportJob: job, Errors: failed})
+	}
+}
+
+// @Summary List the plates of an import job
+// @Description Get the per-plate status of an import job in submission order
+// @Tags imports
+// @Produce json
+// @Param id     path  int    true  "Import job ID"
+// @Param status query string false "Only plates in these statuses, comma separated (pending, created, existing, not_found, failed)"
+// @Param limit  query int    false "Limit number of plates returned"
+// @Param offset query int    false "Offset where to start fetching plates"
+// @Success 200 {array} database.ImportItem
+// @Failure 400 {string} string "Invalid request"
+// @Failure 404 {string} string "Import job not found"
+// @Failure 500 {string} string "Server error"
+// @Router /api/imports/{id}/items [get]
+func (s *Server) handleGetImportItems() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, ok := s.pathID(w, r, "import job")
+		if !ok {
+			return
+		}
+
+		filter := database.ImportItemFilter{
+			Limit:  queryInt(r, "limit", 100),
+			Offset: queryInt(r, "offset", 0),
+		}
+		if filter.Limit < 0 {
+			s.respondWithError(w, http.StatusBadRequest, "limit cannot be negative")
+			return
+		}
+		if filter.Offset < 0 {
+			s.respondWithError(w, http.StatusBadRequest, "offset cannot be negative")
+			return
+		}
+		for _, status := range splitList(r.URL.Query().Get("status")) {
+			switch status {
+			case database.ItemPending, database.ItemCreated, database.ItemExisting, database.ItemNotFound, database.ItemFailed:
+				filter.Statuses = append(filter.Statuses, status)
+			default:
+				s.respondWithError(w, http.StatusBadRequest, "unknown item status: "+status)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+		defer cancel()
+
+		if _, err := s.DB.GetImportJob(ctx, id); errors.Is(err, sql.ErrNoRows) {
+			s.respondWithError(w, http.StatusNotFound, "import job not found")
+			return
+		} else if err != nil {
+			s.logError(r.Context(), err)
+			s.respondWithError(w, http.StatusInternalServerError, "server error")
+			return
+		}
+
+		items, err := s.DB.GridImportItems(ctx, id, filter)
+		if err != nil {
+			s.logError(r.Context(), err)
+			s.respondWithError(w, http.StatusInternalServerError, "server error")
+			return
+		}
+
+		s.respondAny(w, http.StatusOK, items)
+	}
+}
+
+// @Summary Cancel an import job
+// @Description Stop a queued or running import job. Processed plates keep their results, the rest stay pending until the job is resumed
+// @Tags imports
+// @Produce json
+// @Param id path int true "Import job ID"
+// @Success 204 {object} nil
+// @Failure 400 {string} string "Invalid import job ID"
+// @Failure 404 {string} string "Import job not found"
+// @Failure 409 {string} string "Import job is already finished"
+// @Failure 500 {string} string "Server error"
+// @Router /api/imports/{id}/cancel [post]
+func (s *Server) handleCancelImport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, ok := s.pathID(w, r, "import job")
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+		defer cancel()
+
+		s.respondImportTransition(ctx, w, s.Importer.Cancel(ctx, id), http.StatusNoContent, "import job is already finished")
+	}
+}
+
+// @Summary Resume an import job
+// @Description Restart a cancelled or failed import job from its pending plates
+// @Tags imports
+// @Produce json
+// @Param id path int true "Import job ID"
+// @Success 202 {object} nil
+// @Failure 400 {string} string "Invalid import job ID"
+// @Failure 404 {string} string "Import job not found"
+// @Failure 409 {string} string "Import job is not cancelled or failed"
+// @Failure 500 {string} string "Server error"
+// @Router /api/imports/{id}/resume [post]
+func (s *Server) handleResumeImport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, ok := s.pathID(w, r, "import job")
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+		defer cancel()
+
+		s.respondImportTransition(ctx, w, s.Importer.Resume(ctx, id), http.StatusAccepted, "import job is not cancelled or failed")
+	}
+}
+
+func (s *Server) respondImportTransition(ctx context.Context, w http.ResponseWriter, err error, status int, conflict string) {
+	switch {
+	case err == nil:
+		s.respondNoContent(w, status)
+	case errors.Is(err, sql.ErrNoRows):
+		s.respondWithError(w, http.StatusNotFound, "import job not found")
+	case errors.Is(err, database.ErrImportJobState):
+		s.respondWithError(w, http.StatusConflict, conflict)
+	default:
+		s.logError(ctx, err)
+		s.respondWithError(w, http.StatusInternalServerError, "server error")
+	}
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/database"
+	"net/http"
+)
+
+// @Summary Get list of owners
+// @Description Get owners with optional filtering by name, surname and patronymic, with pagination
+// @Tags owners
+// @Accept  json
+// @Produce  json
+// @Param   name        query     string  false  "Filter by owner name"
+// @Param   surname     query     string  false  "Filter by owner surname"
+// @Param   patronymic  query     string  false  "Filter by owner patronymic"
+// @Param   limit       query     int     false  "Limit number of owners returned"
+// @Param   offset      query     int     false  "Offset where to start fetching owners"
+// @Success 200 {array} database.Owner
+// @Failure 400 {string} string "Invalid pagination parameters"
+// @Failure 500 {string} string "Server error"
+// @Router /api/owners [get]
+func (s *Server) handleGetOwners() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		query := r.URL.Query()
+		limit := queryInt(r, "limit", 10)
+		offset := queryInt(r, "offset", 0)
+		if limit < 0 {
+			s.respondWithError(w, http.StatusBadRequest, "limit cannot be negative")
+			return
+		}
+		if offset < 0 {
+			s.respondWithError(w, http.StatusBadRequest, "offset cannot be negative")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+		defer cancel()
+
+		owners, err := s.DB.GridOwnerInfo(ctx, query.Get("name"), query.Get("surname"), query.Get("patronymic"), limit, offset)
+		if err != nil {
+			s.logError(r.Context(), err)
+			s.respondWithError(w, http.StatusInternalServerError, "server error")
+			return
+		}
+
+		s.respondAny(w, http.StatusOK, owners)
+	}
+}
+
+// @Summary Get an owner
+// @Description Get details of an owner by ID together with the cars they own
+// @Tags owners
+// @Accept json
+// @Produce json
+// @Param id path int true "Owner ID"
+// @Success 200 {object} database.OwnerDetails "Successfully retrieved the owner"
+// @Failure 400 {string} string "Invalid owner ID"
+// @Failure 404 {string} string "Owner not found"
+// @Failure 500 {string} string "Server error"
+// @Router /api/owners/{id} [get]
+func (s *Server) handleGetOwner() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := s.pathID(w, r, "owner")
+		if !ok {
+			return
+		}
+
+		owner, err := s.DB.GetOwner(r.Context(), id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				s.respondWithError(w, http.StatusNotFound, "owner not found")
+			} else {
+				s.logError(r.Context(), err)
+				s.respondWithError(w, http.StatusInternalServerError, "server error")
+			}
+			return
+		}
+
+		s.respondAny(w, http.StatusOK, owner)
+	}
+}
+
+// @Summary Add a new owner
+// @Description Create an owner from name, surname and optional patronymic
+// @Tags owners
+// @Accept json
+// @Produce json
+// @Param owner body database.Owner true "Owner data"
+// @Success 201 {object} database.Owner "Successfully created the owner"
+// @Failure 400 {string} string "Bad request due to malformed or incomplete input"
+// @Failure 409 {string} string "Owner already exists"
+// @Failure 500 {string} string "Server error"
+// @Router /api/owners [post]
+func (s *Server) handlePostOwner() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var owner database.Owner
+		if err := json.NewDecoder(r.Body).Decode(&owner); err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "error parsing owner data")
+			return
+		}
+		if owner.Name == "" || owner.Surname == "" {
+			s.respondWithError(w, http.StatusBadRequest, "name and surname are required")
+			return
+		}
+
+		id, err := s.DB.AddOwner(r.Context(), owner)
+		if errors.Is(err, database.ErrOwnerExists) {
+			s.respondWithError(w, http.StatusConflict, fmt.Sprintf("owner already exists with id %d", id))
+			return
+		} else if err != nil {
+			s.logError(r.Context(), err)
+			s.respondWithError(w, http.StatusInternalServerError, "error while adding owner")
+			return
+		}
+
+		owner.ID = int(id)
+		s.respondAny(w, http.StatusCreated, owner)
+	}
+}
+
+// @Summary Update an owner
+// @Description Update owner's information by ID, empty fields are left unchanged
+// @Tags owners
+// @Accept json
+// @Produce json
+// @Param id path int true "Owner ID"
+// @Param owner body database.Owner true "Owner data"
+// @Success 204 {object} nil
+// @Failure 400 {string} string "Bad request"
+// @Failure 404 {string} string "Owner not found"
+// @Failure 409 {string} string "Another owner with the same identity exists"
+// @Failure 500 {string} string "Server error"
+// @Router /api/owners/{id} [put]
+func (s *Server) handleUpdateOwner() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := s.pathID(w, r, "owner")
+		if !ok {
+			return
+		}
+
+		var owner database.Owner
+		if err := json.NewDecoder(r.Body).Decode(&owner); err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "error parsing owner data")
+			return
+		}
+
+		if err := s.DB.UpdateOwner(r.Context(), id, owner); err != nil {
+			switch {
+			case err == sql.ErrNoRows:
+				s.respondWithError(w, http.StatusNotFound, "owner not found")
+			case errors.Is(err, database.ErrOwnerExists):
+				s.respondWithError(w, http.StatusConflict, "another owner with the same name, surname and patronymic exists")
+			default:
+				s.logError(r.Context(), err)
+				s.respondWithError(w, http.StatusInternalServerError, "error while updating owner")
+			}
+			return
+		}
+
+		s.respondNoContent(w, http.StatusNoContent)
+	}
+}
+
+// @Summary Delete an owner
+// @Description Delete an owner by ID. Owners who still have cars are rejected unless cascade is set, which soft-deletes their cars as well. Restoring one of those cars brings the owner back
+// @Tags owners
+// @Accept json
+// @Produce json
+// @Param id path int true "Owner ID"
+// @Param cascade query bool false "Also soft-delete the owner's cars"
+// @Success 204 {object} nil
+// @Failure 400 {string} string "Invalid owner ID"
+// @Failure 404 {string} string "Owner not found"
+// @Failure 409 {string} string "Owner still has cars or appears in ownership history"
+// @Failure 500 {string} string "Error while deleting the owner"
+// @Router /api/owners/{id} [delete]
+func (s *Server) handleDeleteOwner() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := s.pathID(w, r, "owner")
+		if !ok {
+			return
+		}
+		cascade := r.URL.Query().Get("cascade") == "true"
+
+		if err := s.DB.DeleteOwner(r.Context(), id, cascade); err != nil {
+			switch {
+			case err == sql.ErrNoRows:
+				s.respondWithError(w, http.StatusNotFound, "owner not found")
+			case errors.Is(err, database.ErrOwnerHasCars):
+				s.respondWithError(w, http.StatusConflict, "owner still has cars, delete them first or pass cascade=true")
+			case errors.Is(err, database.ErrOwnerHasHistory):
+				s.respondWithError(w, http.StatusConflict, "owner appears in the ownership history of other cars")
+			default:
+				s.logError(r.Context(), err)
+				s.respondWithError(w, http.StatusInternalServerError, "error while deleting owner")
+			}
+			return
+		}
+
+		s.respondNoContent(w, http.StatusNoContent)
+	}
+}
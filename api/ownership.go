@@ -0,0 +1,172 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/likimiad/car-management-api/internal/database"
+	"net/http"
+	"time"
+)
+
+type transferRequest struct {
+	OwnerID int    `json:"ownerId"`
+	At      string `json:"at,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// @Summary Transfer a car
+// @Description Hand a car over to another owner, closing the current ownership period and opening a new one
+// @Tags cars
+// @Accept json
+// @Produce json
+// @Param id path int true "Car ID"
+// @Param transfer body transferRequest true "New owner, transfer date (RFC 3339 or YYYY-MM-DD, defaults to now, not in the future) and reason"
+// @Success 204 {object} nil
+// @Failure 400 {string} string "Bad request"
+// @Failure 404 {string} string "Car not found"
+// @Failure 409 {string} string "Car already belongs to that owner"
+// @Failure 500 {string} string "Server error"
+// @Router /api/cars/{id}/transfer [post]
+func (s *Server) handleTransferCar() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := s.pathID(w, r, "car")
+		if !ok {
+			return
+		}
+
+		var req transferRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "error parsing transfer data")
+			return
+		}
+
+		at := time.Now()
+		if req.At != "" {
+			parsed, err := parseTime(req.At)
+			if err != nil {
+				s.respondWithError(w, http.StatusBadRequest, "invalid transfer date")
+				return
+			}
+			at = parsed
+		}
+		if at.After(time.Now()) {
+			s.respondWithError(w, http.StatusBadRequest, "transfer date is in the future")
+			return
+		}
+		if req.Reason == "" {
+			req.Reason = database.ReasonTransfer
+		}
+
+		if !s.DB.OwnerExists(r.Context(), req.OwnerID) {
+			s.respondWithError(w, http.StatusBadRequest, "owner does not exist")
+			return
+		}
+
+		if err := s.DB.TransferCar(r.Context(), id, req.OwnerID, at, req.Reason); err != nil {
+			switch {
+			case err == sql.ErrNoRows:
+				s.respondWithError(w, http.StatusNotFound, "car not found")
+			case errors.Is(err, database.ErrSameOwner):
+				s.respondWithError(w, http.StatusConflict, "car already belongs to that owner")
+			case errors.Is(err, database.ErrTransferBeforeCurrent), errors.Is(err, database.ErrTransferInFuture):
+				s.respondWithError(w, http.StatusBadRequest, err.Error())
+			default:
+				s.logError(r.Context(), err)
+				s.respondWithError(w, http.StatusInternalServerError, "error while transferring car")
+			}
+			return
+		}
+
+		s.respondNoContent(w, http.StatusNoContent)
+	}
+}
+
+// @Summary Get ownership history of a car
+// @Description Get the chronological chain of owners of a car
+// @Tags cars
+// @Accept json
+// @Produce json
+// @Param id path int true "Car ID"
+// @Success 200 {array} database.OwnershipPeriod
+// @Failure 400 {string} string "Invalid car ID"
+// @Failure 404 {string} string "Car not found"
+// @Failure 500 {string} string "Server error"
+// @Router /api/cars/{id}/owners [get]
+func (s *Server) handleGetCarOwners() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := s.pathID(w, r, "car")
+		if !ok {
+			return
+		}
+
+		if _, err := s.DB.GetCar(r.Context(), id, false); err != nil {
+			if err == sql.ErrNoRows {
+				s.respondWithError(w, http.StatusNotFound, "car not found")
+			} else {
+				s.respondWithError(w, http.StatusInternalServerError, "server error")
+			}
+			return
+		}
+
+		periods, err := s.DB.CarOwnershipHistory(r.Context(), id)
+		if err != nil {
+			s.logError(r.Context(), err)
+			s.respondWithError(w, http.StatusInternalServerError, "server error")
+			return
+		}
+
+		s.respondAny(w, http.StatusOK, periods)
+	}
+}
+
+// @Summary Get cars of an owner
+// @Description Get the cars an owner held at a given moment, now by default
+// @Tags owners
+// @Accept json
+// @Produce json
+// @Param id path int true "Owner ID"
+// @Param at query string false "Moment to look at, RFC 3339 or YYYY-MM-DD"
+// @Success 200 {array} database.OwnedCar
+// @Failure 400 {string} string "Invalid owner ID or date"
+// @Failure 404 {string} string "Owner not found"
+// @Failure 500 {string} string "Server error"
+// @Router /api/owners/{id}/cars [get]
+func (s *Server) handleGetOwnerCars() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := s.pathID(w, r, "owner")
+		if !ok {
+			return
+		}
+
+		at := time.Now()
+		if atStr := r.URL.Query().Get("at"); atStr != "" {
+			parsed, err := parseTime(atStr)
+			if err != nil {
+				s.respondWithError(w, http.StatusBadRequest, "invalid date in at parameter")
+				return
+			}
+			at = parsed
+		}
+
+		if !s.DB.OwnerExists(r.Context(), id) {
+			s.respondWithError(w, http.StatusNotFound, "owner not found")
+			return
+		}
+
+		cars, err := s.DB.OwnerCarsAt(r.Context(), id, at)
+		if err != nil {
+			s.logError(r.Context(), err)
+			s.respondWithError(w, http.StatusInternalServerError, "server error")
+			return
+		}
+
+		s.respondAny(w, http.StatusOK, cars)
+	}
+}
@@ -21,6 +21,15 @@ func (s *Server) respondAny(w http.ResponseWriter, httpStatus int, obj any) {
 	})
 }
 
+func (s *Server) respondPage(w http.ResponseWriter, httpStatus int, obj any, page pageInfo) {
+	w.WriteHeader(httpStatus)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"status": httpStatus,
+		"result": obj,
+		"page":   page,
+	})
+}
+
 func (s *Server) respondNoContent(w http.ResponseWriter, httpStatus int) {
 	w.WriteHeader(httpStatus)
 }
@@ -7,28 +7,59 @@ import (
 	"errors"
 	"github.com/gorilla/mux"
 	"github.com/likimiad/car-management-api/internal/database"
+	"github.com/likimiad/car-management-api/internal/imports"
+	"github.com/likimiad/car-management-api/internal/logging"
+	"github.com/likimiad/car-management-api/internal/metrics"
+	"github.com/likimiad/car-management-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 )
 
+// plateResult is the outcome for one submitted plate. Status is one of
+// created, existing, not_found, failed or invalid; a plate repeated in the
+// request shares the result of its first occurrence, pointed to by
+// DuplicateOf.
 type plateResult struct {
-	InputPlate string `json:"inputPlate"`
-	ID         *int64 `json:"id,omitempty"`
-	Error      string `json:"error,omitempty"`
+	Index       int    `json:"index"`
+	InputPlate  string `json:"inputPlate"`
+	RegNum      string `json:"regNum,omitempty"`
+	Status      string `json:"status"`
+	ID          *int64 `json:"id,omitempty"`
+	Error       string `json:"error,omitempty"`
+	DuplicateOf *int   `json:"duplicateOf,omitempty"`
 }
 
 // @Summary Get list of cars
-// @Description Get cars with optional filtering by mark, model, and year, with pagination
+// @Description Get cars with filtering, sorting and pagination. Text filters are case-insensitive and take comma
+// @Description separated lists (mark=Toyota,Honda). Pages are addressed with the opaque cursors returned in
+// @Description page.next and page.prev; offset pagination is kept as a legacy mode. Unknown parameters are rejected.
 // @Tags cars
 // @Accept  json
 // @Produce  json
-// @Param   mark    query     string     false  "Filter by car mark"
-// @Param   model   query     string     false  "Filter by car model"
-// @Param   year    query     int        false  "Filter by car year"
-// @Param   limit   query     int        false  "Limit number of cars returned"
-// @Param   offset  query     int        false  "Offset where to start fetching cars"
+// @Param   mark          query  string  false  "Marks to match, substrings, comma separated"
+// @Param   model         query  string  false  "Models to match, substrings, comma separated"
+// @Param   year          query  string  false  "Years to match, comma separated"
+// @Param   yearFrom      query  int     false  "Earliest year"
+// @Param   yearTo        query  int     false  "Latest year"
+// @Param   regNum        query  string  false  "Registration number prefixes, comma separated"
+// @Param   ownerName     query  string  false  "Owner names to match, substrings, comma separated"
+// @Param   ownerSurname  query  string  false  "Owner surnames to match, substrings, comma separated"
+// @Param   sort          query  string  false  "Sort fields (id, regNum, mark, model, year, ownerName, ownerSurname), prefix - for descending, e.g. year,-mark"
+// @Param   limit         query  int     false  "Limit number of cars returned"
+// @Param   cursor        query  string  false  "Cursor from page.next or page.prev of a previous response"
+// @Param   offset        query  int     false  "Legacy: offset where to start fetching cars, cannot be combined with cursor"
+// @Param   total         query  bool    false  "Also count all cars matching the filters"
+// @Param   includeDeleted  query  bool  false  "Include soft-deleted cars, administrators only"
+// @Param   X-Actor       header string  false  "Acting user, must be an administrator for includeDeleted"
+// @Param   Authorization header string  false  "Bearer token of the administrator, required for includeDeleted"
 // @Success 200 {array} database.Car
+// @Failure 400 {string} string "Invalid parameters or cursor"
+// @Failure 403 {string} string "includeDeleted requested by a non-administrator"
+// @Failure 500 {string} string "Server error"
 // @Router /api/cars [get]
 func (s *Server) handleGetCars() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -46,9 +77,6 @@ func (s *Server) handleGetCars() http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
 		defer cancel()
 
-		mark := r.URL.Query().Get("mark")
-		model := r.URL.Query().Get("model")
-		year := getParam("year", 0)
 		limit := getParam("limit", 10)
 		offset := getParam("offset", 0)
 		if limit < 0 {
@@ -60,16 +88,79 @@ func (s *Server) handleGetCars() http.HandlerFunc {
 			return
 		}
 
-		cars, err := s.DB.GridCarInfo(ctx, mark, model, year, limit, offset)
+		filter, err := parseCarFilter(r.URL.Query())
 		if err != nil {
-			if s.DebugMode {
-				s.debugErrorMessage(err)
+			s.respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if filter.IncludeDeleted && !s.isAdmin(r) {
+			s.respondWithError(w, http.StatusForbidden, "includeDeleted is only available to administrators")
+			return
+		}
+		filter.Limit = limit
+		filter.Offset = offset
+
+		token := r.URL.Query().Get("cursor")
+		legacy := r.URL.Query().Has("offset")
+		if legacy && token != "" {
+			s.respondWithError(w, http.StatusBadRequest, "cursor and offset cannot be combined")
+			return
+		}
+
+		var cursor pageCursor
+		if token != "" {
+			if cursor, err = s.decodeCursor(token, filter); err != nil {
+				s.respondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if cursor.Direction == cursorNext {
+				filter.After = cursor.Key
+			} else {
+				filter.Before = cursor.Key
 			}
+		}
+		if !legacy {
+			// One extra row tells whether there is a page beyond this one.
+			filter.Limit = limit + 1
+		}
+
+		cars, err := s.DB.GridCarInfo(ctx, filter)
+		if err != nil {
+			s.logError(r.Context(), err)
 			s.respondWithError(w, http.StatusInternalServerError, "Server error")
 			return
 		}
 
-		s.respondAny(w, http.StatusOK, cars)
+		page := pageInfo{Limit: limit}
+		if !legacy {
+			more := len(cars) > limit
+			if more && cursor.Direction == cursorPrev {
+				cars = cars[1:]
+			} else if more {
+				cars = cars[:limit]
+			}
+			if len(cars) > 0 {
+				fingerprint := filterFingerprint(filter)
+				if more || cursor.Direction == cursorPrev {
+					page.Next = s.encodeCursor(pageCursor{Direction: cursorNext, Key: filter.KeyOf(cars[len(cars)-1]), Filter: fingerprint})
+				}
+				if (more && cursor.Direction == cursorPrev) || cursor.Direction == cursorNext {
+					page.Prev = s.encodeCursor(pageCursor{Direction: cursorPrev, Key: filter.KeyOf(cars[0]), Filter: fingerprint})
+				}
+			}
+		}
+
+		if r.URL.Query().Get("total") == "true" {
+			total, err := s.DB.CountCars(ctx, filter)
+			if err != nil {
+				s.logError(r.Context(), err)
+				s.respondWithError(w, http.StatusInternalServerError, "Server error")
+				return
+			}
+			page.Total = &total
+		}
+
+		s.respondPage(w, http.StatusOK, cars, page)
 	}
 }
 
@@ -79,8 +170,12 @@ func (s *Server) handleGetCars() http.HandlerFunc {
 // @Accept json
 // @Produce json
 // @Param id path int true "Car ID"
+// @Param includeDeleted query bool false "Also return the car if it is soft-deleted, administrators only"
+// @Param X-Actor header string false "Acting user, must be an administrator for includeDeleted"
+// @Param Authorization header string false "Bearer token of the administrator, required for includeDeleted"
 // @Success 200 {object} database.Car "Successfully retrieved the car"
 // @Failure 400 {string} string "Invalid car ID"
+// @Failure 403 {string} string "includeDeleted requested by a non-administrator"
 // @Failure 404 {string} string "Car not found"
 // @Failure 500 {string} string "Server error"
 // @Router /api/cars/{id} [get]
@@ -101,7 +196,13 @@ func (s *Server) handleGetCar() http.HandlerFunc {
 			return
 		}
 
-		car, err := s.DB.GetCar(r.Context(), id)
+		includeDeleted := r.URL.Query().Get("includeDeleted") == "true"
+		if includeDeleted && !s.isAdmin(r) {
+			s.respondWithError(w, http.StatusForbidden, "includeDeleted is only available to administrators")
+			return
+		}
+
+		car, err := s.DB.GetCar(r.Context(), id, includeDeleted)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				s.respondWithError(w, http.StatusNotFound, "car not found")
@@ -116,12 +217,21 @@ func (s *Server) handleGetCar() http.HandlerFunc {
 }
 
 // @Summary Add new cars
-// @Description Add new cars using registration numbers
+// @Description Add new cars using registration numbers. Plates are normalized (spaces and dashes removed, upper
+// @Description case, Cyrillic look-alike letters replaced with Latin ones) and checked against the configured
+// @Description country formats; invalid plates are rejected without an upstream lookup. Every distinct plate is
+// @Description looked up once per request; results follow the input order with a status for every plate. The
+// @Description response is 201 when every plate was created or already existed and 207 otherwise.
+// @Description With Accept: application/x-ndjson or text/event-stream the result of every plate is streamed as
+// @Description soon as it is ready, as JSON lines or as "result" events followed by a "done" event; streamed
+// @Description responses have status 200. Accept q-values are honoured: the format ranked highest wins and q=0
+// @Description rules a format out.
 // @Tags cars
 // @Accept json
-// @Produce json
+// @Produce json,application/x-ndjson,text/event-stream
 // @Param regNums body []string true "Array of registration numbers"
-// @Success 201 {array} plateResult "Successfully added cars with results for each plate"
+// @Success 201 {array} plateResult "Every plate was added or already existed"
+// @Success 207 {array} plateResult "Some plates were not found or failed"
 // @Failure 400 {string} string "Bad request due to malformed JSON input"
 // @Failure 500 {string} string "Server error"
 // @Router /api/cars [post]
@@ -137,84 +247,113 @@ func (s *Server) handlePostCar() http.HandlerFunc {
 			return
 		}
 
-		results := make([]plateResult, len(regNums.Plates))
-		ch := make(chan int, s.MaxWorkers)
-		resultCh := make(chan plateResult)
-
-		for i := 0; i < s.MaxWorkers; i++ {
-			ch <- i
+		// The response, streamed or not, takes as long as the lookups do.
+		liftWriteDeadline(w)
+		resultCh := s.addCars(r.Context(), regNums.Plates)
+		if media := streamMedia(r.Header.Get("Accept")); media != "" {
+			s.streamResults(r.Context(), w, media, resultCh)
+			return
 		}
 
-		wg := sync.WaitGroup{}
-		for idx, plate := range regNums.Plates {
-			wg.Add(1)
-			go func(index int, plate string) {
-				defer wg.Done()
-				workerID := <-ch
-				if s.DebugMode {
-					s.workerMessage(workerID, "start working")
-				}
-
-				car, err := s.fetchCarInfoWithContext(r.Context(), plate, s.IdleTimeout)
-				if errors.Is(err, ErrCarNotFound) {
-					resultCh <- plateResult{InputPlate: plate, Error: "car with registration number not found"}
-					ch <- workerID
-					return
-				} else if err != nil {
-					if s.DebugMode {
-						s.debugErrorMessage(err)
-					}
-					resultCh <- plateResult{InputPlate: plate, Error: "error with getting data from third party api"}
-					ch <- workerID
-					return
-				}
+		results := make([]plateResult, len(regNums.Plates))
+		status := http.StatusCreated
+		for res := range resultCh {
+			results[res.Index] = res
+			if res.Status != database.ItemCreated && res.Status != database.ItemExisting {
+				status = http.StatusMultiStatus
+			}
+		}
 
-				ownerId, err := s.DB.GetOrCreateOwner(r.Context(), car.Owner)
-				if err != nil {
-					if s.DebugMode {
-						s.debugErrorMessage(err)
-					}
-					resultCh <- plateResult{InputPlate: plate, Error: err.Error()}
-					ch <- workerID
-					return
-				}
+		s.respondAny(w, status, results)
+	}
+}
 
-				carID, err := s.DB.AddNewCar(r.Context(), car.RegNum, car.Mark, car.Model, car.Year, ownerId)
-				if errors.Is(err, database.ErrCarExists) {
-					resultCh <- plateResult{InputPlate: plate, ID: &carID}
-				} else if err != nil {
-					if s.DebugMode {
-						s.debugErrorMessage(err)
-					}
-					resultCh <- plateResult{InputPlate: plate, Error: "error while adding car in database"}
-				} else {
-					resultCh <- plateResult{InputPlate: plate, ID: &carID}
-				}
-				ch <- workerID
-				if s.DebugMode {
-					s.workerMessage(workerID, "successfully completed the job")
-				}
-			}(idx, plate)
+// addCars looks every distinct valid plate up and stores it with at most
+// MaxWorkers at a time, sending the result for each submitted plate, repeats
+// included, as soon as it is ready. Invalid plates are reported one by one
+// and never counted as repeats. The channel is closed once every plate is
+// done.
+func (s *Server) addCars(ctx context.Context, plates []string) <-chan plateResult {
+	ch := make(chan int, s.MaxWorkers)
+	resultCh := make(chan plateResult)
+
+	for i := 0; i < s.MaxWorkers; i++ {
+		ch <- i
+	}
+	metrics.PlateWorkers.Add(float64(s.MaxWorkers))
+
+	// positions groups the input indexes of valid plates by normalized
+	// plate, in order of first occurrence.
+	var distinct []string
+	positions := map[string][]int{}
+	var invalid []plateResult
+	for idx, plate := range plates {
+		regNum, _, err := s.Plates.Validate(plate)
+		if err != nil {
+			invalid = append(invalid, plateResult{Index: idx, InputPlate: plate, RegNum: regNum, Status: database.ItemInvalid, Error: s.plateError(err)})
+			continue
 		}
+		if _, ok := positions[regNum]; !ok {
+			distinct = append(distinct, regNum)
+		}
+		positions[regNum] = append(positions[regNum], idx)
+	}
 
+	wg := sync.WaitGroup{}
+	if len(invalid) > 0 {
+		wg.Add(1)
 		go func() {
-			wg.Wait()
-			close(ch)
-			close(resultCh)
+			defer wg.Done()
+			for _, res := range invalid {
+				metrics.Plates.WithLabelValues(metrics.SourceRequest, res.Status).Inc()
+				resultCh <- res
+			}
 		}()
-
-		idx := 0
-		for res := range resultCh {
-			results[idx] = res
-			idx++
-		}
-
-		s.respondAny(w, http.StatusCreated, results)
 	}
+	for _, regNum := range distinct {
+		wg.Add(1)
+		go func(regNum string, indexes []int) {
+			defer wg.Done()
+			ctx, span := tracing.Start(ctx, "plate", trace.WithAttributes(attribute.String("car.reg_num", regNum)))
+			defer span.End()
+			workerID := <-ch
+			span.AddEvent("worker acquired", trace.WithAttributes(attribute.Int("worker", workerID)))
+			metrics.PlateWorkersBusy.Inc()
+			logger := logging.FromContext(ctx).With("worker", workerID, "regNum", regNum)
+			logger.Debug("worker started")
+
+			item, err := imports.ImportPlate(ctx, s.DB, s.Enricher, regNum, strings.TrimSpace(plates[indexes[0]]))
+			if err != nil {
+				logger.Debug("plate import failed", logging.Err(err))
+				tracing.Fail(span, err)
+			}
+			span.SetAttributes(attribute.String("plate.status", item.Status))
+			metrics.PlateWorkersBusy.Dec()
+			ch <- workerID
+			metrics.Plates.WithLabelValues(metrics.SourceRequest, item.Status).Inc()
+
+			for i, index := range indexes {
+				res := plateResult{Index: index, InputPlate: plates[index], RegNum: regNum, Status: item.Status, ID: item.CarID, Error: item.Error}
+				if i > 0 {
+					res.DuplicateOf = &indexes[0]
+				}
+				resultCh <- res
+			}
+			logger.Debug("worker finished", "status", item.Status)
+		}(regNum, positions[regNum])
+	}
+
+	go func() {
+		wg.Wait()
+		metrics.PlateWorkers.Sub(float64(s.MaxWorkers))
+		close(ch)
+		close(resultCh)
+	}()
+	return resultCh
 }
 
 // @Summary Delete a car
-// @Description Delete a car by its ID
+// @Description Soft-delete a car by its ID, it can be restored until it is purged
 // @Tags cars
 // @Accept json
 // @Produce json
@@ -241,9 +380,11 @@ func (s *Server) handleDeleteCar() http.HandlerFunc {
 		}
 
 		if err := s.DB.DeleteCar(r.Context(), id); err != nil {
-			if s.DebugMode {
-				s.debugErrorMessage(err)
+			if err == sql.ErrNoRows {
+				s.respondWithError(w, http.StatusNotFound, "car not found")
+				return
 			}
+			s.logError(r.Context(), err)
 			s.respondWithError(w, http.StatusInternalServerError, "error while deleting car")
 			return
 		}
@@ -292,9 +433,11 @@ func (s *Server) handleUpdateCar() http.HandlerFunc {
 		}
 
 		if err := s.DB.UpdateCarInfo(r.Context(), id, car.Mark, car.Model, car.Year, car.Owner.ID); err != nil {
-			if s.DebugMode {
-				s.debugErrorMessage(err)
+			if err == sql.ErrNoRows {
+				s.respondWithError(w, http.StatusNotFound, "car not found")
+				return
 			}
+			s.logError(r.Context(), err)
 			s.respondWithError(w, http.StatusInternalServerError, "error while updating car information")
 			return
 		}
@@ -302,3 +445,41 @@ func (s *Server) handleUpdateCar() http.HandlerFunc {
 		s.respondNoContent(w, http.StatusNoContent)
 	}
 }
+
+// @Summary Restore a car
+// @Description Restore a soft-deleted car by its ID
+// @Tags cars
+// @Accept json
+// @Produce json
+// @Param id path int true "Car ID"
+// @Success 204 {object} nil
+// @Failure 400 {string} string "Invalid car ID"
+// @Failure 404 {string} string "Car not found"
+// @Failure 409 {string} string "Car is not deleted"
+// @Failure 500 {string} string "Error while restoring the car"
+// @Router /api/cars/{id}/restore [post]
+func (s *Server) handleRestoreCar() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := s.pathID(w, r, "car")
+		if !ok {
+			return
+		}
+
+		if err := s.DB.RestoreCar(r.Context(), id); err != nil {
+			switch {
+			case err == sql.ErrNoRows:
+				s.respondWithError(w, http.StatusNotFound, "car not found")
+			case errors.Is(err, database.ErrCarNotDeleted):
+				s.respondWithError(w, http.StatusConflict, "car is not deleted")
+			default:
+				s.logError(r.Context(), err)
+				s.respondWithError(w, http.StatusInternalServerError, "error while restoring car")
+			}
+			return
+		}
+
+		s.respondNoContent(w, http.StatusNoContent)
+	}
+}
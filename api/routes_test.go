@@ -0,0 +1,232 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/config"
+	"github.com/likimiad/car-management-api/internal/database"
+	"github.com/likimiad/car-management-api/internal/plates"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer serves a memory store holding an active car (id 1) and a
+// soft-deleted one (id 2).
+func newTestServer(t *testing.T, admins ...string) *Server {
+	t.Helper()
+	store := database.NewMemoryStore()
+	ctx := context.Background()
+	ownerID, err := store.GetOrCreateOwner(ctx, database.Owner{Name: "Ivan", Surname: "Petrov"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, regNum := range []string{"A111AA150", "B222BB150"} {
+		if _, err := store.AddNewCar(ctx, regNum, "Lada", "Vesta", 2020, ownerID, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.DeleteCar(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	return getServer(store, nil, nil, nil, nil, config.HTTPServer{Timeout: time.Second}, config.SyncConfig{},
+		config.HealthConfig{Timeout: time.Second}, config.AdminConfig{Actors: admins}, "secret")
+}
+
+func TestIncludeDeletedAdminsOnly(t *testing.T) {
+	server := newTestServer(t, "alice", " bob ")
+	tests := []struct {
+		name   string
+		target string
+		actor  string
+		token  string
+		want   int
+	}{
+		{"list without deleted", "/api/cars", "", "", http.StatusOK},
+		{"list with deleted, anonymous", "/api/cars?includeDeleted=true", "", "", http.StatusForbidden},
+		{"list with deleted, not an admin", "/api/cars?includeDeleted=true", "mallory", AdminToken("secret", "mallory"), http.StatusForbidden},
+		{"list with deleted, admin without token", "/api/cars?includeDeleted=true", "alice", "", http.StatusForbidden},
+		{"list with deleted, admin with another token", "/api/cars?includeDeleted=true", "alice", AdminToken("secret", "bob"), http.StatusForbidden},
+		{"list with deleted, token of another key", "/api/cars?includeDeleted=true", "alice", AdminToken("other", "alice"), http.StatusForbidden},
+		{"list with deleted, admin", "/api/cars?includeDeleted=true", "alice", AdminToken("secret", "alice"), http.StatusOK},
+		{"list with deleted, trimmed admin", "/api/cars?includeDeleted=true", "bob", AdminToken("secret", "bob"), http.StatusOK},
+		{"deleted car hidden", "/api/cars/2", "alice", AdminToken("secret", "alice"), http.StatusNotFound},
+		{"deleted car, not an admin", "/api/cars/2?includeDeleted=true", "mallory", "", http.StatusForbidden},
+		{"deleted car, admin", "/api/cars/2?includeDeleted=true", "alice", AdminToken("secret", "alice"), http.StatusOK},
+		{"includeDeleted=false needs no admin", "/api/cars/1?includeDeleted=false", "", "", http.StatusOK},
+		{"audit, anonymous", "/api/audit", "", "", http.StatusForbidden},
+		{"audit, admin without token", "/api/audit", "alice", "", http.StatusForbidden},
+		{"audit, admin", "/api/audit", "alice", AdminToken("secret", "alice"), http.StatusOK},
+		{"upstream, anonymous", "/api/admin/upstream", "alice", "", http.StatusForbidden},
+		{"sync status, anonymous", "/api/admin/sync", "", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.actor != "" {
+				req.Header.Set("X-Actor", tt.actor)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			server.Router.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestAdminWithoutSecretKey(t *testing.T) {
+	server := getServer(database.NewMemoryStore(), nil, nil, nil, nil, config.HTTPServer{Timeout: time.Second}, config.SyncConfig{},
+		config.HealthConfig{Timeout: time.Second}, config.AdminConfig{Actors: []string{"alice"}}, "")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/audit", nil)
+	req.Header.Set("X-Actor", "alice")
+	req.Header.Set("Authorization", "Bearer "+AdminToken("", "alice"))
+	rec := httptest.NewRecorder()
+	server.Router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body)
+	}
+}
+
+func TestTransferRejectsFutureDate(t *testing.T) {
+	server := newTestServer(t)
+	ownerID, err := server.DB.AddOwner(context.Background(), database.Owner{Name: "Anna", Surname: "Sidorova"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		at   string
+		want int
+	}{
+		{"tomorrow", time.Now().AddDate(0, 0, 1).Format(time.DateOnly), http.StatusBadRequest},
+		{"in an hour", time.Now().Add(time.Hour).Format(time.RFC3339), http.StatusBadRequest},
+		{"now by default", "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"ownerId": %d, "at": %q}`, ownerID, tt.at)
+			req := httptest.NewRequest(http.MethodPost, "/api/cars/1/transfer", strings.NewReader(body))
+			rec := httptest.NewRecorder()
+			server.Router.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+// slowProvider answers every lookup after a delay.
+type slowProvider struct {
+	delay time.Duration
+}
+
+func (p slowProvider) Name() string { return "slow" }
+
+func (p slowProvider) CarInfo(ctx context.Context, regNum string) (database.Car, error) {
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return database.Car{}, ctx.Err()
+	}
+	return database.Car{RegNum: regNum, Mark: "Lada", Model: "Vesta", Year: 2020, Owner: database.Owner{Name: "Oleg", Surname: "Ivanov"}}, nil
+}
+
+func TestPostCarsOutlastWriteTimeout(t *testing.T) {
+	server := newTestServer(t)
+	server.MaxWorkers = 2
+	server.Enricher = slowProvider{delay: 200 * time.Millisecond}
+	validator, err := plates.NewValidator(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Plates = validator
+
+	ts := httptest.NewUnstartedServer(server.Router)
+	ts.Config.WriteTimeout = 50 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	for _, accept := range []string{"application/json", "application/x-ndjson"} {
+		t.Run(accept, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/cars", strings.NewReader(`{"regNums": ["X123XX150"]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept", accept)
+			resp, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatalf("the write timeout cut the response: %v", err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("reading the response: %v", err)
+			}
+			if !strings.Contains(string(body), "X123XX150") {
+				t.Errorf("response %q does not mention the plate", body)
+			}
+		})
+	}
+}
+
+func TestPostCarsValidatesBeforeDedupe(t *testing.T) {
+	server := newTestServer(t)
+	server.MaxWorkers = 2
+	server.Enricher = slowProvider{}
+	validator, err := plates.NewValidator([]string{"RU"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Plates = validator
+
+	body := `{"regNums": ["", "  ", "Ж1", "ж1", "x123xx150", "X 123 XX 150", "A111AA150"]}`
+	rec := httptest.NewRecorder()
+	server.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/cars", strings.NewReader(body)))
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusMultiStatus, rec.Body)
+	}
+	var resp struct {
+		Result []plateResult `json:"result"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	results := resp.Result
+
+	first := 4
+	want := []struct {
+		status      string
+		duplicateOf *int
+	}{
+		{database.ItemInvalid, nil},
+		{database.ItemInvalid, nil},
+		{database.ItemInvalid, nil},
+		{database.ItemInvalid, nil},
+		{database.ItemCreated, nil},
+		{database.ItemCreated, &first},
+		{database.ItemExisting, nil},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %s", len(results), len(want), rec.Body)
+	}
+	for i, res := range results {
+		if res.Index != i || res.Status != want[i].status {
+			t.Errorf("results[%d] = index %d, status %q, want index %d, status %q", i, res.Index, res.Status, i, want[i].status)
+		}
+		if (res.DuplicateOf == nil) != (want[i].duplicateOf == nil) || res.DuplicateOf != nil && *res.DuplicateOf != *want[i].duplicateOf {
+			t.Errorf("results[%d].duplicateOf = %v, want %v", i, res.DuplicateOf, want[i].duplicateOf)
+		}
+		if res.Status == database.ItemInvalid && res.Error == "" {
+			t.Errorf("results[%d] has no validation error", i)
+		}
+	}
+}
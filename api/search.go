@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// @Summary Search cars and owners
+// @Description Fuzzy search over car plates, marks and models and owner names, surnames and patronymics.
+// @Description Hits of both types are ranked by relevance; highlights mark the matched parts with <em>.
+// @Tags search
+// @Accept json
+// @Produce json
+// @Param q query string true "Search text, may be partial or misspelled"
+// @Param limit query int false "Maximum number of hits, 20 by default, at most 100"
+// @Success 200 {array} database.SearchHit
+// @Failure 400 {string} string "Missing search text or invalid limit"
+// @Failure 500 {string} string "Server error"
+// @Router /api/search [get]
+func (s *Server) handleSearch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		if query == "" {
+			s.respondWithError(w, http.StatusBadRequest, "search text is missing")
+			return
+		}
+		limit := queryInt(r, "limit", 20)
+		if limit <= 0 || limit > 100 {
+			s.respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+		defer cancel()
+
+		hits, err := s.DB.Search(ctx, query, limit)
+		if err != nil {
+			s.logError(r.Context(), err)
+			s.respondWithError(w, http.StatusInternalServerError, "server error")
+			return
+		}
+
+		s.respondAny(w, http.StatusOK, hits)
+	}
+}
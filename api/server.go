@@ -1,67 +1,382 @@
 package api
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
 	_ "github.com/likimiad/car-management-api/docs"
+	"github.com/likimiad/car-management-api/internal/catalog"
 	"github.com/likimiad/car-management-api/internal/config"
 	"github.com/likimiad/car-management-api/internal/database"
+	"github.com/likimiad/car-management-api/internal/enrichment"
+	"github.com/likimiad/car-management-api/internal/health"
+	"github.com/likimiad/car-management-api/internal/imports"
+	"github.com/likimiad/car-management-api/internal/logging"
+	"github.com/likimiad/car-management-api/internal/metrics"
+	"github.com/likimiad/car-management-api/internal/plates"
+	"github.com/likimiad/car-management-api/internal/tracing"
 	httpSwagger "github.com/swaggo/http-swagger"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 type Server struct {
-	DB               *database.Database
-	Router           *mux.Router
-	Timeout          time.Duration
-	IdleTimeout      time.Duration
-	MaxWorkers       int
-	ThirdPartyAPIURL string
-	DebugMode        bool
+	DB                database.Store
+	Router            *mux.Router
+	Timeout           time.Duration
+	ReadTimeout       time.Duration
+	IdleTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	MaxHeaderBytes    int
+	ShutdownTimeout   time.Duration
+	ShutdownDelay     time.Duration
+	MaxWorkers        int
+	Enricher          enrichment.CarInfoProvider
+	Syncer            *catalog.Syncer
+	Importer          *imports.Importer
+	Plates            *plates.Validator
+	SyncFlagMissing   bool
+	SecretKey         []byte
+	Logger            *slog.Logger
+	Health            *health.Checker
+	Admins            map[string]bool
+
+	probes probeCache
+	// adminKey signs administrator tokens. It is only set when SECRET_KEY is
+	// configured, so that tokens minted offline can be checked.
+	adminKey []byte
+
+	// background is cancelled on shutdown; the tasks started with Go are
+	// waited for before the database is closed.
+	background     context.Context
+	stopBackground context.CancelFunc
+	tasks          sync.WaitGroup
 }
 
-func getServer(db *database.Database, cfg config.HTTPServer) *Server {
+func getServer(db database.Store, enricher enrichment.CarInfoProvider, syncer *catalog.Syncer, importer *imports.Importer, validator *plates.Validator, cfg config.HTTPServer, syncCfg config.SyncConfig, healthCfg config.HealthConfig, adminCfg config.AdminConfig, secretKey string) *Server {
 	server := &Server{
-		DB:               db,
-		Router:           mux.NewRouter(),
-		Timeout:          cfg.Timeout,
-		IdleTimeout:      cfg.IdleTimeout,
-		MaxWorkers:       cfg.MaxWorkers,
-		ThirdPartyAPIURL: cfg.ThirdPartyAPIURL,
-		DebugMode:        cfg.DebugMode,
+		DB:                db,
+		Router:            mux.NewRouter(),
+		Timeout:           cfg.Timeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		ShutdownTimeout:   cfg.ShutdownTimeout,
+		ShutdownDelay:     cfg.ShutdownDelay,
+		MaxWorkers:        cfg.MaxWorkers,
+		Enricher:          enricher,
+		Syncer:            syncer,
+		Importer:          importer,
+		Plates:            validator,
+		SyncFlagMissing:   syncCfg.FlagMissing,
+		SecretKey:         []byte(secretKey),
+		Logger:            slog.Default(),
+		Admins:            make(map[string]bool, len(adminCfg.Actors)),
+	}
+	for _, actor := range adminCfg.Actors {
+		if actor = strings.TrimSpace(actor); actor != "" {
+			server.Admins[actor] = true
+		}
+	}
+	server.background, server.stopBackground = context.WithCancel(context.Background())
+	if len(server.SecretKey) == 0 {
+		server.SecretKey = make([]byte, 32)
+		_, _ = rand.Read(server.SecretKey)
+		server.Logger.Warn("SECRET_KEY is empty, page cursors will not survive a restart and administrator access is disabled")
+	} else {
+		server.adminKey = server.SecretKey
 	}
+	server.Health = server.healthChecker(healthCfg)
 	server.routes()
 	return server
 }
 
-func NewServer(db *database.Database, cfg config.HTTPServer) *Server {
+func NewServer(db database.Store, enricher enrichment.CarInfoProvider, syncer *catalog.Syncer, importer *imports.Importer, validator *plates.Validator, cfg config.HTTPServer, syncCfg config.SyncConfig, healthCfg config.HealthConfig, adminCfg config.AdminConfig, secretKey string) *Server {
 	defer func(start time.Time) {
-		fmt.Printf("%s [%s] %s %s\n", time.Now().Format("2006-01-02 15:04:05"), "START", "create server and routes", time.Since(start))
+		slog.Info("create server and routes", "duration", time.Since(start))
 	}(time.Now())
-	return getServer(db, cfg)
+	return getServer(db, enricher, syncer, importer, validator, cfg, syncCfg, healthCfg, adminCfg, secretKey)
+}
+
+// Go runs a background task until the server shuts down. The task must
+// return once ctx is cancelled.
+func (s *Server) Go(task func(ctx context.Context)) {
+	s.tasks.Add(1)
+	go func() {
+		defer s.tasks.Done()
+		task(s.background)
+	}()
+}
+
+// Start serves HTTP until ctx is cancelled, then shuts down gracefully: it
+// reports not ready and keeps serving for ShutdownDelay so that load
+// balancers notice, stops accepting connections, lets in-flight requests
+// finish, stops the import jobs, the catalog sync and the tasks started with
+// Go, and closes the database. Everything has ShutdownTimeout to wind down.
+func (s *Server) Start(ctx context.Context, address string) error {
+	srv := &http.Server{
+		Addr:              address,
+		Handler:           s.Router,
+		ReadTimeout:       s.ReadTimeout,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		MaxHeaderBytes:    s.MaxHeaderBytes,
+	}
+
+	s.Logger.Info("starting server", "address", "http://"+address)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+	}
+
+	s.Logger.Info("shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+	return errors.Join(err, s.shutdown(shutdownCtx, srv))
 }
 
-func (s *Server) Start(address string) error {
-	fmt.Printf("%s [%s] %s http://%s\n", time.Now().Format("2006-01-02 15:04:05"), "START", "starting server", address)
-	return http.ListenAndServe(address, s.Router)
+func (s *Server) shutdown(ctx context.Context, srv *http.Server) error {
+	defer func(start time.Time) {
+		s.Logger.Info("server stopped", "duration", time.Since(start))
+	}(time.Now())
+
+	s.Health.Drain()
+	if s.ShutdownDelay > 0 {
+		s.Logger.Info("reporting not ready before draining requests", "delay", s.ShutdownDelay)
+		select {
+		case <-time.After(s.ShutdownDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	var errs []error
+	if err := srv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("error draining requests: %w", err))
+	}
+
+	s.stopBackground()
+	if err := s.Importer.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := s.Syncer.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	done := make(chan struct{})
+	go func() {
+		s.tasks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("error stopping background tasks: %w", ctx.Err()))
+	}
+
+	if err := s.DB.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("error closing database: %w", err))
+	}
+	return errors.Join(errs...)
 }
 
 func (s *Server) routes() {
+	s.Router.Use(s.trace, s.requestContext, s.instrument)
 	s.Router.PathPrefix("/docs/").Handler(httpSwagger.WrapHandler)
+	s.Router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	s.Router.Handle("/healthz", s.handleHealthz()).Methods("GET")
+	s.Router.Handle("/readyz", s.handleReadyz()).Methods("GET")
 
 	s.Router.Handle("/api/cars", s.logger(s.handleGetCars())).Methods("GET")
+	s.Router.Handle("/api/cars:batchDelete", s.logger(s.handleBatchDeleteCars())).Methods("POST")
+	s.Router.Handle("/api/cars:batchUpdate", s.logger(s.handleBatchUpdateCars())).Methods("PATCH")
 	s.Router.Handle("/api/cars/{id}", s.logger(s.handleGetCar())).Methods("GET")
 	s.Router.Handle("/api/cars", s.logger(s.handlePostCar())).Methods("POST")
 	s.Router.Handle("/api/cars/{id}", s.logger(s.handleDeleteCar())).Methods("DELETE")
 	s.Router.Handle("/api/cars/{id}", s.logger(s.handleUpdateCar())).Methods("PUT")
+	s.Router.Handle("/api/cars/{id}/restore", s.logger(s.handleRestoreCar())).Methods("POST")
+	s.Router.Handle("/api/cars/{id}/transfer", s.logger(s.handleTransferCar())).Methods("POST")
+	s.Router.Handle("/api/cars/{id}/owners", s.logger(s.handleGetCarOwners())).Methods("GET")
+
+	s.Router.Handle("/api/owners", s.logger(s.handleGetOwners())).Methods("GET")
+	s.Router.Handle("/api/owners/{id}", s.logger(s.handleGetOwner())).Methods("GET")
+	s.Router.Handle("/api/owners", s.logger(s.handlePostOwner())).Methods("POST")
+	s.Router.Handle("/api/owners/{id}", s.logger(s.handleUpdateOwner())).Methods("PUT")
+	s.Router.Handle("/api/owners/{id}", s.logger(s.handleDeleteOwner())).Methods("DELETE")
+	s.Router.Handle("/api/owners/{id}/cars", s.logger(s.handleGetOwnerCars())).Methods("GET")
+
+	s.Router.Handle("/api/imports", s.logger(s.handlePostImport())).Methods("POST")
+	s.Router.Handle("/api/imports/{id}", s.logger(s.handleGetImport())).Methods("GET")
+	s.Router.Handle("/api/imports/{id}/items", s.logger(s.handleGetImportItems())).Methods("GET")
+	s.Router.Handle("/api/imports/{id}/cancel", s.logger(s.handleCancelImport())).Methods("POST")
+	s.Router.Handle("/api/imports/{id}/resume", s.logger(s.handleResumeImport())).Methods("POST")
+
+	s.Router.Handle("/api/search", s.logger(s.handleSearch())).Methods("GET")
+	s.Router.Handle("/api/admin/upstream", s.logger(s.adminOnly(s.handleGetUpstream()))).Methods("GET")
+	s.Router.Handle("/api/admin/sync", s.logger(s.adminOnly(s.handleGetSync()))).Methods("GET")
+	s.Router.Handle("/api/admin/sync", s.logger(s.adminOnly(s.handlePostSync()))).Methods("POST")
+	s.Router.Handle("/api/audit", s.logger(s.adminOnly(s.handleGetAudit()))).Methods("GET")
 }
 
+// logger writes the access log entry of a request with the request logger,
+// once the handler is done.
 func (s *Server) logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func(start time.Time) {
+			level := slog.LevelInfo
+			if rec.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logging.FromContext(r.Context()).LogAttrs(r.Context(), level, "request",
+				slog.Int("status", rec.status),
+				slog.Int64("bytes", rec.bytes),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("remoteAddr", r.RemoteAddr),
+			)
+		}(time.Now())
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// trace starts a server span for every request, continuing the trace of the
+// caller when it sends a traceparent header.
+func (s *Server) trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		ctx, span := tracing.Start(tracing.Extract(r.Context(), r.Header), r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+			))
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// instrument counts and times every request by route template, method and
+// status code, and tracks the requests in flight.
+func (s *Server) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics.HTTPInFlight.Inc()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
 		defer func(start time.Time) {
-			fmt.Printf("%s [%s] %s %s %s\n", time.Now().Format("2006-01-02 15:04:05"), r.Method, r.RemoteAddr, r.URL.Path, time.Since(start))
+			metrics.HTTPInFlight.Dec()
+			labels := []string{routeTemplate(r), r.Method, strconv.Itoa(rec.status)}
+			metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+			metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
 		}(time.Now())
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// requestContext tags every request with an id, echoed in X-Request-ID, and
+// the acting user taken from X-Actor, so writes can be audited. The request
+// carries a logger with the id, the method, the route template and the trace
+// id.
+func (s *Server) requestContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		logger := s.Logger.With("requestId", requestID, "method", r.Method, "route", routeTemplate(r))
+		if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+			logger = logger.With("traceId", sc.TraceID().String())
+		}
+		ctx := database.WithAuditMeta(r.Context(), r.Header.Get("X-Actor"), requestID)
+		next.ServeHTTP(w, r.WithContext(logging.WithLogger(ctx, logger)))
+	})
+}
+
+// isAdmin reports whether the actor of the request, taken from X-Actor, is one
+// of the administrators listed in ADMIN_ACTORS and proves it with the bearer
+// token minted for them by AdminToken.
+func (s *Server) isAdmin(r *http.Request) bool {
+	actor := r.Header.Get("X-Actor")
+	if len(s.adminKey) == 0 || !s.Admins[actor] {
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(adminToken(s.adminKey, actor)))
+}
+
+// adminOnly rejects requests that do not come from an administrator.
+func (s *Server) adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.isAdmin(r) {
+			s.respondWithError(w, http.StatusForbidden, "only available to administrators")
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// routeTemplate returns the template of the matched route, such as
+// /api/cars/{id}, falling back to the path.
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
+}
+
+// statusRecorder remembers the status and size of a response. Unwrap lets
+// http.ResponseController reach the flusher of the underlying writer.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status, rec.wroteHeader = status, true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
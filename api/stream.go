@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/logging"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	mediaJSON        = "application/json"
+	mediaNDJSON      = "application/x-ndjson"
+	mediaEventStream = "text/event-stream"
+)
+
+// streamMedia picks the format Accept ranks highest among a single JSON
+// document and the streaming formats, returning "" for the JSON document.
+// Every format takes the quality of the most specific range matching it and
+// q=0 rules it out; ties go to the more specific match, then to the range
+// listed first. Without an acceptable format the JSON document is sent.
+func streamMedia(accept string) string {
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return ""
+	}
+
+	var best string
+	var bestMatch acceptMatch
+	for _, offer := range []string{mediaJSON, mediaNDJSON, mediaEventStream} {
+		match, ok := matchAccept(ranges, offer)
+		if !ok || match.q == 0 {
+			continue
+		}
+		if bestMatch.q == 0 || match.better(bestMatch) {
+			best, bestMatch = offer, match
+		}
+	}
+	if best == mediaJSON {
+		return ""
+	}
+	return best
+}
+
+// acceptRange is a media range of an Accept header with its quality and
+// position.
+type acceptRange struct {
+	mediaType string
+	q         float64
+	index     int
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if mediaType == "application/ndjson" {
+			mediaType = mediaNDJSON
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q, index: len(ranges)})
+	}
+	return ranges
+}
+
+// acceptMatch is how a media type matched an Accept header: the quality and
+// position of the most specific range matching it, where 2 is an exact
+// match, 1 a type/* range and 0 */*.
+type acceptMatch struct {
+	q           float64
+	specificity int
+	index       int
+}
+
+func (m acceptMatch) better(other acceptMatch) bool {
+	if m.q != other.q {
+		return m.q > other.q
+	}
+	if m.specificity != other.specificity {
+		return m.specificity > other.specificity
+	}
+	return m.index < other.index
+}
+
+func matchAccept(ranges []acceptRange, mediaType string) (acceptMatch, bool) {
+	kind, _, _ := strings.Cut(mediaType, "/")
+	match, found := acceptMatch{specificity: -1}, false
+	for _, r := range ranges {
+		specificity := -1
+		switch r.mediaType {
+		case mediaType:
+			specificity = 2
+		case kind + "/*":
+			specificity = 1
+		case "*/*":
+			specificity = 0
+		}
+		if specificity > match.specificity {
+			match, found = acceptMatch{q: r.q, specificity: specificity, index: r.index}, true
+		}
+	}
+	return match, found
+}
+
+// streamResults writes every result as soon as it arrives, as one JSON line
+// each or as Server-Sent Events closed by a done event. It keeps draining
+// results after the client has gone so the producers can finish.
+func (s *Server) streamResults(ctx context.Context, w http.ResponseWriter, media string, results <-chan plateResult) {
+	w.Header().Set("Content-Type", media)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	_ = rc.Flush()
+
+	var writeErr error
+	total := 0
+	for res := range results {
+		total++
+		if writeErr != nil {
+			continue
+		}
+		data, err := json.Marshal(res)
+		if err != nil {
+			writeErr = err
+			continue
+		}
+		if media == mediaEventStream {
+			_, writeErr = fmt.Fprintf(w, "event: result\ndata: %s\n\n", data)
+		} else {
+			_, writeErr = fmt.Fprintf(w, "%s\n", data)
+		}
+		if writeErr == nil {
+			writeErr = rc.Flush()
+		}
+	}
+
+	if writeErr != nil {
+		logging.FromContext(ctx).Debug("stream cut short", logging.Err(writeErr))
+		return
+	}
+	if media == mediaEventStream {
+		_, _ = fmt.Fprintf(w, "event: done\ndata: {\"total\":%d}\n\n", total)
+		_ = rc.Flush()
+	}
+}
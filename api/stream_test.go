@@ -0,0 +1,36 @@
+package api
+
+import "testing"
+
+func TestStreamMedia(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"*/*", ""},
+		{"application/json", ""},
+		{"text/html", ""},
+		{"application/x-ndjson", mediaNDJSON},
+		{"application/ndjson", mediaNDJSON},
+		{"text/event-stream", mediaEventStream},
+		{"application/x-ndjson, */*", mediaNDJSON},
+		{"application/json, application/x-ndjson;q=0.1", ""},
+		{"application/json;q=0.5, application/x-ndjson", mediaNDJSON},
+		{"application/x-ndjson;q=0", ""},
+		{"application/x-ndjson;q=0, text/event-stream;q=0.2", mediaEventStream},
+		{"*/*;q=0.1, text/event-stream;q=0", ""},
+		{"application/*;q=0.3, text/event-stream;q=0.8", mediaEventStream},
+		{"application/json, application/x-ndjson", ""},
+		{"application/x-ndjson, application/json", mediaNDJSON},
+		{"application/x-ndjson;q=2, application/json;q=0.1", ""},
+		{"application/x-ndjson;q=oops", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			if got := streamMedia(tt.accept); got != tt.want {
+				t.Errorf("streamMedia(%q) = %q, want %q", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
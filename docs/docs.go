@@ -15,9 +15,267 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api/admin/sync": {
+            "get": {
+                "description": "Get the progress of the running catalog sync and the diff summary of the last finished one",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get catalog sync status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Administrator listed in ADMIN_ACTORS",
+                        "name": "X-Actor",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bearer token of the administrator, printed by -admin-token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.syncStatus"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an administrator",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Start importing and updating cars from the upstream catalog in the background. Progress is reported by GET /api/admin/sync",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Start a catalog sync",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Flag stored cars no longer listed upstream (defaults to SYNC_FLAG_MISSING)",
+                        "name": "flagMissing",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Administrator listed in ADMIN_ACTORS",
+                        "name": "X-Actor",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bearer token of the administrator, printed by -admin-token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/catalog.Run"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an administrator",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "A sync is already running",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "The server is shutting down",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/upstream": {
+            "get": {
+                "description": "Get the circuit breaker state of every third party car info provider and the lookup cache statistics",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get upstream status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Administrator listed in ADMIN_ACTORS",
+                        "name": "X-Actor",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bearer token of the administrator, printed by -admin-token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.upstreamStatus"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an administrator",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/audit": {
+            "get": {
+                "description": "Get recorded mutations of cars and owners, newest first, with optional filtering and pagination",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "audit"
+                ],
+                "summary": "Get audit log",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by entity (car, owner)",
+                        "name": "entity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter by entity ID",
+                        "name": "entityId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by operation, e.g. car.update",
+                        "name": "operation",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by actor",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by request ID",
+                        "name": "requestId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only entries at or after this moment, RFC 3339 or YYYY-MM-DD",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only entries before this moment, RFC 3339 or YYYY-MM-DD",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit number of entries returned",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset where to start fetching entries",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Administrator listed in ADMIN_ACTORS",
+                        "name": "X-Actor",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bearer token of the administrator, printed by -admin-token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/database.AuditEntry"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an administrator",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/api/cars": {
             "get": {
-                "description": "Get cars with optional filtering by mark, model, and year, with pagination",
+                "description": "Get cars with filtering, sorting and pagination. Text filters are case-insensitive and take comma\nseparated lists (mark=Toyota,Honda). Pages are addressed with the opaque cursors returned in\npage.next and page.prev; offset pagination is kept as a legacy mode. Unknown parameters are rejected.",
                 "consumes": [
                     "application/json"
                 ],
@@ -31,33 +289,99 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Filter by car mark",
+                        "description": "Marks to match, substrings, comma separated",
                         "name": "mark",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Filter by car model",
+                        "description": "Models to match, substrings, comma separated",
                         "name": "model",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "description": "Filter by car year",
+                        "type": "string",
+                        "description": "Years to match, comma separated",
                         "name": "year",
                         "in": "query"
                     },
+                    {
+                        "type": "integer",
+                        "description": "Earliest year",
+                        "name": "yearFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Latest year",
+                        "name": "yearTo",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Registration number prefixes, comma separated",
+                        "name": "regNum",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Owner names to match, substrings, comma separated",
+                        "name": "ownerName",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Owner surnames to match, substrings, comma separated",
+                        "name": "ownerSurname",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort fields (id, regNum, mark, model, year, ownerName, ownerSurname), prefix - for descending, e.g. year,-mark",
+                        "name": "sort",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
                         "description": "Limit number of cars returned",
                         "name": "limit",
                         "in": "query"
                     },
+                    {
+                        "type": "string",
+                        "description": "Cursor from page.next or page.prev of a previous response",
+                        "name": "cursor",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
-                        "description": "Offset where to start fetching cars",
+                        "description": "Legacy: offset where to start fetching cars, cannot be combined with cursor",
                         "name": "offset",
                         "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also count all cars matching the filters",
+                        "name": "total",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft-deleted cars, administrators only",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Acting user, must be an administrator for includeDeleted",
+                        "name": "X-Actor",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bearer token of the administrator, required for includeDeleted",
+                        "name": "Authorization",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -69,16 +393,36 @@ const docTemplate = `{
                                 "$ref": "#/definitions/database.Car"
                             }
                         }
+                    },
+                    "400": {
+                        "description": "Invalid parameters or cursor",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "includeDeleted requested by a non-administrator",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             },
             "post": {
-                "description": "Add new cars using registration numbers",
+                "description": "Add new cars using registration numbers. Plates are normalized (spaces and dashes removed, upper\ncase, Cyrillic look-alike letters replaced with Latin ones) and checked against the configured\ncountry formats; invalid plates are rejected without an upstream lookup. Every distinct plate is\nlooked up once per request; results follow the input order with a status for every plate. The\nresponse is 201 when every plate was created or already existed and 207 otherwise.\nWith Accept: application/x-ndjson or text/event-stream the result of every plate is streamed as\nsoon as it is ready, as JSON lines or as \"result\" events followed by a \"done\" event; streamed\nresponses have status 200. Accept q-values are honoured: the format ranked highest wins and q=0\nrules a format out.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/x-ndjson",
+                    "text/event-stream"
                 ],
                 "tags": [
                     "cars"
@@ -100,7 +444,16 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "201": {
-                        "description": "Successfully added cars with results for each plate",
+                        "description": "Every plate was added or already existed",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.plateResult"
+                            }
+                        }
+                    },
+                    "207": {
+                        "description": "Some plates were not found or failed",
                         "schema": {
                             "type": "array",
                             "items": {
@@ -143,6 +496,24 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also return the car if it is soft-deleted, administrators only",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Acting user, must be an administrator for includeDeleted",
+                        "name": "X-Actor",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bearer token of the administrator, required for includeDeleted",
+                        "name": "Authorization",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -158,6 +529,12 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "403": {
+                        "description": "includeDeleted requested by a non-administrator",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "404": {
                         "description": "Car not found",
                         "schema": {
@@ -215,7 +592,7 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "Delete a car by its ID",
+                "description": "Soft-delete a car by its ID, it can be restored until it is purged",
                 "consumes": [
                     "application/json"
                 ],
@@ -259,19 +636,1326 @@ const docTemplate = `{
                     }
                 }
             }
-        }
+        },
+        "/api/cars/{id}/owners": {
+            "get": {
+                "description": "Get the chronological chain of owners of a car",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Get ownership history of a car",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Car ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/database.OwnershipPeriod"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid car ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Car not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/cars/{id}/restore": {
+            "post": {
+                "description": "Restore a soft-deleted car by its ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Restore a car",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Car ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid car ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Car not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Car is not deleted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Error while restoring the car",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/cars/{id}/transfer": {
+            "post": {
+                "description": "Hand a car over to another owner, closing the current ownership period and opening a new one",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Transfer a car",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Car ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New owner, transfer date (RFC 3339 or YYYY-MM-DD, defaults to now, not in the future) and reason",
+                        "name": "transfer",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.transferRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Car not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Car already belongs to that owner",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/cars:batchDelete": {
+            "post": {
+                "description": "Soft-delete the listed cars or the cars matching a filter written as the query string of\nGET /api/cars. Atomic batches delete every car or none; otherwise every car is deleted on its\nown. A dry run reports what would be deleted. The response is 200 when every car was deleted\nand 207 otherwise.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Delete cars in a batch",
+                "parameters": [
+                    {
+                        "description": "Car IDs or filter, atomic and dryRun flags",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.batchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Every car was deleted",
+                        "schema": {
+                            "$ref": "#/definitions/api.batchResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Some cars were not found or failed",
+                        "schema": {
+                            "$ref": "#/definitions/api.batchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/cars:batchUpdate": {
+            "patch": {
+                "description": "Apply the same change to the listed cars or the cars matching a filter written as the query\nstring of GET /api/cars; empty fields of set are left alone. Atomic batches update every car or\nnone; otherwise every car is updated on its own. A dry run reports every car before and after\nthe change. The response is 200 when every car was updated and 207 otherwise.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Update cars in a batch",
+                "parameters": [
+                    {
+                        "description": "Car IDs or filter, the change, atomic and dryRun flags",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.batchUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Every car was updated",
+                        "schema": {
+                            "$ref": "#/definitions/api.batchResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Some cars were not found or failed",
+                        "schema": {
+                            "$ref": "#/definitions/api.batchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/imports": {
+            "post": {
+                "description": "Queue a list of plates to be looked up and added in the background. Plates are normalized and validated\nup front; a request with an invalid plate is rejected. The job ID is returned at once; progress is\nreported by GET /api/imports/{id}",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "imports"
+                ],
+                "summary": "Start an import job",
+                "parameters": [
+                    {
+                        "description": "Plates to import",
+                        "name": "regNums",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.importRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/database.ImportJob"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/imports/{id}": {
+            "get": {
+                "description": "Get the state, progress and item counts of an import job with its first failed plates",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "imports"
+                ],
+                "summary": "Get an import job",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Import job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.importStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid import job ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Import job not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/imports/{id}/cancel": {
+            "post": {
+                "description": "Stop a queued or running import job. Processed plates keep their results, the rest stay pending until the job is resumed",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "imports"
+                ],
+                "summary": "Cancel an import job",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Import job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid import job ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Import job not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Import job is already finished",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/imports/{id}/items": {
+            "get": {
+                "description": "Get the per-plate status of an import job in submission order",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "imports"
+                ],
+                "summary": "List the plates of an import job",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Import job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only plates in these statuses, comma separated (pending, created, existing, not_found, failed)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit number of plates returned",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset where to start fetching plates",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/database.ImportItem"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Import job not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/imports/{id}/resume": {
+            "post": {
+                "description": "Restart a cancelled or failed import job from its pending plates",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "imports"
+                ],
+                "summary": "Resume an import job",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Import job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    },
+                    "400": {
+                        "description": "Invalid import job ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Import job not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Import job is not cancelled or failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/owners": {
+            "get": {
+                "description": "Get owners with optional filtering by name, surname and patronymic, with pagination",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "owners"
+                ],
+                "summary": "Get list of owners",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by owner name",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by owner surname",
+                        "name": "surname",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by owner patronymic",
+                        "name": "patronymic",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit number of owners returned",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset where to start fetching owners",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/database.Owner"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid pagination parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create an owner from name, surname and optional patronymic",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "owners"
+                ],
+                "summary": "Add a new owner",
+                "parameters": [
+                    {
+                        "description": "Owner data",
+                        "name": "owner",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/database.Owner"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Successfully created the owner",
+                        "schema": {
+                            "$ref": "#/definitions/database.Owner"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request due to malformed or incomplete input",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Owner already exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/owners/{id}": {
+            "get": {
+                "description": "Get details of an owner by ID together with the cars they own",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "owners"
+                ],
+                "summary": "Get an owner",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Owner ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully retrieved the owner",
+                        "schema": {
+                            "$ref": "#/definitions/database.OwnerDetails"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid owner ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Owner not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Update owner's information by ID, empty fields are left unchanged",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "owners"
+                ],
+                "summary": "Update an owner",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Owner ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Owner data",
+                        "name": "owner",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/database.Owner"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Owner not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Another owner with the same identity exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete an owner by ID. Owners who still have cars are rejected unless cascade is set, which soft-deletes their cars as well. Restoring one of those cars brings the owner back",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "owners"
+                ],
+                "summary": "Delete an owner",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Owner ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also soft-delete the owner's cars",
+                        "name": "cascade",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid owner ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Owner not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Owner still has cars or appears in ownership history",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Error while deleting the owner",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/owners/{id}/cars": {
+            "get": {
+                "description": "Get the cars an owner held at a given moment, now by default",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "owners"
+                ],
+                "summary": "Get cars of an owner",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Owner ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Moment to look at, RFC 3339 or YYYY-MM-DD",
+                        "name": "at",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/database.OwnedCar"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid owner ID or date",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Owner not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/search": {
+            "get": {
+                "description": "Fuzzy search over car plates, marks and models and owner names, surnames and patronymics.\nHits of both types are ranked by relevance; highlights mark the matched parts with \u003cem\u003e.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "search"
+                ],
+                "summary": "Search cars and owners",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search text, may be partial or misspelled",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of hits, 20 by default, at most 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/database.SearchHit"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Missing search text or invalid limit",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Report that the process is up and serving requests, whatever the state of its dependencies",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Check the database connection, the schema version and the third party APIs, reachability and\ncircuit breakers, with the details of every check. Failed checks listed in HEALTH_CRITICAL make\nthe service down and the response 503; other failed checks only make it degraded. The service\nis down as soon as it starts shutting down.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "Up or degraded",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    },
+                    "503": {
+                        "description": "Down or shutting down",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    }
+                }
+            }
+        }
     },
     "definitions": {
+        "api.batchRequest": {
+            "type": "object",
+            "properties": {
+                "atomic": {
+                    "type": "boolean"
+                },
+                "dryRun": {
+                    "type": "boolean"
+                },
+                "filter": {
+                    "type": "string"
+                },
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "api.batchResponse": {
+            "type": "object",
+            "properties": {
+                "atomic": {
+                    "type": "boolean"
+                },
+                "dryRun": {
+                    "type": "boolean"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/database.BatchItem"
+                    }
+                }
+            }
+        },
+        "api.batchUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "atomic": {
+                    "type": "boolean"
+                },
+                "dryRun": {
+                    "type": "boolean"
+                },
+                "filter": {
+                    "type": "string"
+                },
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "set": {
+                    "$ref": "#/definitions/database.CarPatch"
+                }
+            }
+        },
+        "api.importRequest": {
+            "type": "object",
+            "properties": {
+                "regNums": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.importStatus": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "type": "string"
+                },
+                "counts": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/database.ImportItem"
+                    }
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "processed": {
+                    "type": "integer"
+                },
+                "requestId": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
         "api.plateResult": {
             "type": "object",
             "properties": {
+                "duplicateOf": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "inputPlate": {
+                    "type": "string"
+                },
+                "regNum": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.syncStatus": {
+            "type": "object",
+            "properties": {
+                "current": {
+                    "$ref": "#/definitions/catalog.Run"
+                },
+                "last": {
+                    "$ref": "#/definitions/catalog.Run"
+                }
+            }
+        },
+        "api.transferRequest": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "ownerId": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.upstreamStatus": {
+            "type": "object",
+            "properties": {
+                "cache": {
+                    "$ref": "#/definitions/enrichment.CacheStats"
+                },
+                "providers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/enrichment.BreakerState"
+                    }
+                }
+            }
+        },
+        "catalog.Change": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "carId": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "regNum": {
+                    "type": "string"
+                }
+            }
+        },
+        "catalog.Diff": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/catalog.Change"
+                    }
+                },
+                "changesTruncated": {
+                    "type": "boolean"
+                },
+                "created": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "missing": {
+                    "type": "integer"
+                },
+                "notFound": {
+                    "type": "integer"
+                },
+                "reappeared": {
+                    "type": "integer"
+                },
+                "skipped": {
+                    "type": "integer"
+                },
+                "unchanged": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "catalog.Progress": {
+            "type": "object",
+            "properties": {
+                "listed": {
+                    "type": "integer"
+                },
+                "pages": {
+                    "type": "integer"
+                },
+                "processed": {
+                    "type": "integer"
+                }
+            }
+        },
+        "catalog.Run": {
+            "type": "object",
+            "properties": {
+                "diff": {
+                    "$ref": "#/definitions/catalog.Diff"
+                },
                 "error": {
                     "type": "string"
                 },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "flagMissing": {
+                    "type": "boolean"
+                },
                 "id": {
                     "type": "integer"
                 },
-                "inputPlate": {
+                "progress": {
+                    "$ref": "#/definitions/catalog.Progress"
+                },
+                "startedAt": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "trigger": {
+                    "type": "string"
+                }
+            }
+        },
+        "database.AuditEntry": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "type": "string"
+                },
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "entity": {
+                    "type": "string"
+                },
+                "entityId": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "occurredAt": {
+                    "type": "string"
+                },
+                "operation": {
+                    "type": "string"
+                },
+                "requestId": {
+                    "type": "string"
+                }
+            }
+        },
+        "database.BatchItem": {
+            "type": "object",
+            "properties": {
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "status": {
                     "type": "string"
                 }
             }
@@ -279,6 +1963,9 @@ const docTemplate = `{
         "database.Car": {
             "type": "object",
             "properties": {
+                "deletedAt": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
@@ -291,6 +1978,114 @@ const docTemplate = `{
                 "owner": {
                     "$ref": "#/definitions/database.Owner"
                 },
+                "provenance": {
+                    "$ref": "#/definitions/database.Provenance"
+                },
+                "regNum": {
+                    "type": "string"
+                },
+                "upstreamMissingSince": {
+                    "description": "UpstreamMissingSince is set by the catalog sync when the plate is no\nlonger listed by the upstream API.",
+                    "type": "string"
+                },
+                "year": {
+                    "type": "integer"
+                }
+            }
+        },
+        "database.CarPatch": {
+            "type": "object",
+            "properties": {
+                "mark": {
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "ownerId": {
+                    "type": "integer"
+                },
+                "year": {
+                    "type": "integer"
+                }
+            }
+        },
+        "database.ImportItem": {
+            "type": "object",
+            "properties": {
+                "carId": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "position": {
+                    "type": "integer"
+                },
+                "regNum": {
+                    "type": "string"
+                },
+                "spelled": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "database.ImportJob": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "type": "string"
+                },
+                "counts": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "processed": {
+                    "type": "integer"
+                },
+                "requestId": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "database.OwnedCar": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "mark": {
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                },
                 "regNum": {
                     "type": "string"
                 },
@@ -315,6 +2110,165 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "database.OwnerDetails": {
+            "type": "object",
+            "properties": {
+                "cars": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/database.OwnedCar"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "ownerId": {
+                    "type": "integer"
+                },
+                "patronymic": {
+                    "type": "string"
+                },
+                "surname": {
+                    "type": "string"
+                }
+            }
+        },
+        "database.OwnershipPeriod": {
+            "type": "object",
+            "properties": {
+                "carId": {
+                    "type": "integer"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "owner": {
+                    "$ref": "#/definitions/database.Owner"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "database.Provenance": {
+            "type": "object",
+            "additionalProperties": {
+                "type": "string"
+            }
+        },
+        "database.SearchHit": {
+            "type": "object",
+            "properties": {
+                "car": {
+                    "$ref": "#/definitions/database.Car"
+                },
+                "highlights": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "owner": {
+                    "$ref": "#/definitions/database.Owner"
+                },
+                "score": {
+                    "type": "number"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "enrichment.BreakerState": {
+            "type": "object",
+            "properties": {
+                "consecutiveFailures": {
+                    "type": "integer"
+                },
+                "openedAt": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "retryAt": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "enrichment.CacheStats": {
+            "type": "object",
+            "properties": {
+                "capacity": {
+                    "type": "integer"
+                },
+                "coalesced": {
+                    "type": "integer"
+                },
+                "evictions": {
+                    "type": "integer"
+                },
+                "hits": {
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                },
+                "negativeHits": {
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "health.Report": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/health.Result"
+                    }
+                },
+                "draining": {
+                    "type": "boolean"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "health.Result": {
+            "type": "object",
+            "properties": {
+                "critical": {
+                    "type": "boolean"
+                },
+                "detail": {},
+                "duration": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
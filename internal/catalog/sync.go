@@ -0,0 +1,464 @@
+// Package catalog keeps the stored cars in line with the catalog of the
+// upstream car info API.
+package catalog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/config"
+	"github.com/likimiad/car-management-api/internal/database"
+	"github.com/likimiad/car-management-api/internal/enrichment"
+	"github.com/likimiad/car-management-api/internal/logging"
+	"github.com/likimiad/car-management-api/internal/plates"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	TriggerManual   = "manual"
+	TriggerSchedule = "schedule"
+
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateFailed    = "failed"
+
+	ActionCreated    = "created"
+	ActionUpdated    = "updated"
+	ActionReappeared = "reappeared"
+	ActionMissing    = "missing"
+	ActionNotFound   = "not_found"
+	ActionSkipped    = "skipped"
+	ActionFailed     = "failed"
+)
+
+// maxChanges bounds the per-plate changes kept in a run; the counters in Diff
+// stay exact.
+const maxChanges = 100
+
+var (
+	ErrSyncRunning = errors.New("a catalog sync is already running")
+	ErrSyncStopped = errors.New("catalog sync is shutting down")
+)
+
+// Run is the progress and outcome of one sync.
+type Run struct {
+	ID          int        `json:"id"`
+	Trigger     string     `json:"trigger"`
+	FlagMissing bool       `json:"flagMissing"`
+	State       string     `json:"state"`
+	StartedAt   time.Time  `json:"startedAt"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	Progress    Progress   `json:"progress"`
+	Diff        Diff       `json:"diff"`
+}
+
+type Progress struct {
+	Pages     int `json:"pages"`
+	Listed    int `json:"listed"`
+	Processed int `json:"processed"`
+}
+
+// Diff counts what the sync did. Created, Updated and Reappeared cars were
+// written; Missing ones are stored but no longer listed upstream; NotFound
+// plates are listed but their details are not; Skipped plates belong to
+// deleted cars.
+type Diff struct {
+	Created          int      `json:"created"`
+	Updated          int      `json:"updated"`
+	Reappeared       int      `json:"reappeared"`
+	Unchanged        int      `json:"unchanged"`
+	Missing          int      `json:"missing"`
+	NotFound         int      `json:"notFound"`
+	Skipped          int      `json:"skipped"`
+	Failed           int      `json:"failed"`
+	Changes          []Change `json:"changes"`
+	ChangesTruncated bool     `json:"changesTruncated,omitempty"`
+}
+
+type Change struct {
+	RegNum string   `json:"regNum"`
+	CarID  int      `json:"carId,omitempty"`
+	Action string   `json:"action"`
+	Fields []string `json:"fields,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// Syncer pages through the upstream catalog, imports plates that are not
+// stored yet and updates cars whose mark, model, year or owner changed
+// upstream. With FlagMissing it also flags stored cars the catalog no longer
+// lists. At most one sync runs at a time.
+type Syncer struct {
+	store    database.Store
+	catalog  enrichment.Catalog
+	provider enrichment.CarInfoProvider
+	cfg      config.SyncConfig
+
+	mu        sync.Mutex
+	nextID    int
+	current   *Run
+	last      *Run
+	cancelRun context.CancelFunc
+	stopped   bool
+	runs      sync.WaitGroup
+}
+
+func NewSyncer(store database.Store, provider enrichment.CarInfoProvider, cfg config.SyncConfig) (*Syncer, error) {
+	catalog, ok := provider.(enrichment.Catalog)
+	if !ok {
+		return nil, enrichment.ErrNoCatalog
+	}
+	if cfg.PageSize <= 0 || cfg.Workers <= 0 {
+		return nil, fmt.Errorf("catalog sync page size and workers must be positive")
+	}
+	return &Syncer{store: store, catalog: catalog, provider: provider, cfg: cfg, nextID: 1}, nil
+}
+
+// Start begins a sync in the background and returns its initial state, or
+// ErrSyncRunning with the state of the sync in progress.
+func (s *Syncer) Start(ctx context.Context, trigger string, flagMissing bool) (Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return Run{}, ErrSyncStopped
+	}
+	if s.current != nil {
+		return s.snapshot(s.current), ErrSyncRunning
+	}
+	run := &Run{ID: s.nextID, Trigger: trigger, FlagMissing: flagMissing, State: StateRunning, StartedAt: time.Now()}
+	s.nextID++
+	s.current = run
+
+	ctx, s.cancelRun = context.WithCancel(database.WithAuditMeta(ctx, database.ActorSystem, fmt.Sprintf("sync-%d", run.ID)))
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		s.run(ctx, run)
+	}()
+	return s.snapshot(run), nil
+}
+
+// Shutdown stops the sync in progress, refuses new ones and waits for the
+// running one to wind down or for ctx to expire.
+func (s *Syncer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopped = true
+	if s.current != nil {
+		s.cancelRun()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("error stopping catalog sync: %w", ctx.Err())
+	}
+}
+
+// Status returns the sync in progress, if any, and the last finished one.
+func (s *Syncer) Status() (current, last *Run) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current != nil {
+		run := s.snapshot(s.current)
+		current = &run
+	}
+	if s.last != nil {
+		run := s.snapshot(s.last)
+		last = &run
+	}
+	return current, last
+}
+
+// RunScheduled starts a sync every interval until ctx is cancelled. A tick
+// that finds a sync still running is skipped.
+func (s *Syncer) RunScheduled(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Start(ctx, TriggerSchedule, s.cfg.FlagMissing); err != nil {
+				logger().Warn("scheduled sync skipped", logging.Err(err))
+			}
+		}
+	}
+}
+
+func (s *Syncer) run(ctx context.Context, run *Run) {
+	seen, err := s.syncCatalog(ctx, run)
+	if err == nil && run.FlagMissing {
+		err = s.flagMissing(ctx, run, seen)
+	}
+
+	s.mu.Lock()
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.State = StateCompleted
+	if err != nil {
+		run.State, run.Error = StateFailed, err.Error()
+	}
+	s.current, s.last = nil, run
+	s.cancelRun()
+	diff := run.Diff
+	s.mu.Unlock()
+
+	attrs := []any{"sync", run.ID, "state", run.State, "duration", finishedAt.Sub(run.StartedAt).Round(time.Millisecond),
+		"created", diff.Created, "updated", diff.Updated, "reappeared", diff.Reappeared, "unchanged", diff.Unchanged,
+		"missing", diff.Missing, "notFound", diff.NotFound, "skipped", diff.Skipped, "failed", diff.Failed}
+	if err != nil {
+		logger().Error("sync finished", append(attrs, logging.Err(err))...)
+	} else {
+		logger().Info("sync finished", attrs...)
+	}
+}
+
+// syncCatalog feeds every listed plate to the workers and returns the set of
+// plates seen, normalized. Listing stops at the first page shorter than the
+// page size, or one that lists nothing new, in case the API ignores paging.
+func (s *Syncer) syncCatalog(ctx context.Context, run *Run) (map[string]bool, error) {
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < s.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for listed := range queue {
+				s.record(run, s.syncPlate(ctx, listed))
+			}
+		}()
+	}
+
+	seen := map[string]bool{}
+	var err error
+	for offset := 0; ; {
+		var page []string
+		page, err = s.catalog.RegNums(ctx, offset, s.cfg.PageSize)
+		if err != nil {
+			err = fmt.Errorf("error listing upstream catalog at offset %d: %w", offset, err)
+			break
+		}
+
+		fresh := 0
+		for _, listed := range page {
+			regNum := plates.Normalize(listed)
+			if regNum == "" || seen[regNum] {
+				continue
+			}
+			seen[regNum] = true
+			fresh++
+			queue <- listed
+		}
+
+		s.mu.Lock()
+		run.Progress.Pages++
+		run.Progress.Listed += fresh
+		s.mu.Unlock()
+
+		if len(page) < s.cfg.PageSize || fresh == 0 || ctx.Err() != nil {
+			break
+		}
+		offset += len(page)
+	}
+	close(queue)
+	wg.Wait()
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	return seen, err
+}
+
+// syncPlate brings one listed plate in line with the upstream data. The
+// upstream is asked for the plate as it lists it; the car is stored and
+// matched under the normalized plate, like the cars added by clients.
+func (s *Syncer) syncPlate(ctx context.Context, listed string) Change {
+	regNum := plates.Normalize(listed)
+	change := Change{RegNum: regNum}
+	upstream, err := s.lookup(ctx, listed)
+	if errors.Is(err, enrichment.ErrNotFound) {
+		change.Action = ActionNotFound
+		return change
+	} else if err != nil {
+		return failed(change, err)
+	}
+
+	stored, err := s.store.GetCarByRegNum(ctx, regNum)
+	if errors.Is(err, sql.ErrNoRows) {
+		ownerId, err := s.store.GetOrCreateOwner(ctx, upstream.Owner)
+		if err != nil {
+			return failed(change, err)
+		}
+		id, err := s.store.AddNewCar(ctx, regNum, upstream.Mark, upstream.Model, upstream.Year, ownerId, upstream.Provenance)
+		if err != nil {
+			return failed(change, err)
+		}
+		change.CarID, change.Action = int(id), ActionCreated
+		return change
+	} else if err != nil {
+		return failed(change, err)
+	}
+
+	change.CarID = stored.ID
+	if stored.DeletedAt != nil {
+		change.Action = ActionSkipped
+		return change
+	}
+
+	change.Fields = changedFields(*stored, upstream)
+	if len(change.Fields) == 0 {
+		if stored.UpstreamMissingSince == nil {
+			return change
+		}
+		if err := s.store.SetUpstreamMissing(ctx, stored.ID, false); err != nil {
+			return failed(change, err)
+		}
+		change.Action = ActionReappeared
+		return change
+	}
+
+	ownerId := int64(stored.Owner.ID)
+	if !sameOwner(stored.Owner, upstream.Owner) {
+		if ownerId, err = s.store.GetOrCreateOwner(ctx, upstream.Owner); err != nil {
+			return failed(change, err)
+		}
+	}
+	if err := s.store.SyncCar(ctx, stored.ID, upstream.Mark, upstream.Model, upstream.Year, int(ownerId), upstream.Provenance); err != nil {
+		return failed(change, err)
+	}
+	change.Action = ActionUpdated
+	return change
+}
+
+// flagMissing flags the stored cars the catalog did not list, walking them
+// in id order a page at a time. Stored plates are normalized (migration
+// 0011 rewrote older rows), so they compare directly with the listed ones.
+func (s *Syncer) flagMissing(ctx context.Context, run *Run, seen map[string]bool) error {
+	filter := database.CarFilter{Limit: s.cfg.PageSize}
+	for {
+		cars, err := s.store.GridCarInfo(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, car := range cars {
+			if seen[car.RegNum] || car.UpstreamMissingSince != nil {
+				continue
+			}
+			change := Change{RegNum: car.RegNum, CarID: car.ID, Action: ActionMissing}
+			if err := s.store.SetUpstreamMissing(ctx, car.ID, true); err != nil {
+				change = failed(change, err)
+			}
+			s.record(run, change)
+		}
+		if len(cars) < filter.Limit {
+			return nil
+		}
+		filter.After = database.CarKey{cars[len(cars)-1].ID}
+	}
+}
+
+// lookup asks the upstream for fresh data, refreshing the lookup cache when
+// the provider has one.
+func (s *Syncer) lookup(ctx context.Context, regNum string) (database.Car, error) {
+	if refresher, ok := s.provider.(interface {
+		Refresh(ctx context.Context, regNum string) (database.Car, error)
+	}); ok {
+		return refresher.Refresh(ctx, regNum)
+	}
+	return s.provider.CarInfo(ctx, regNum)
+}
+
+func (s *Syncer) record(run *Run, change Change) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	diff := &run.Diff
+	switch change.Action {
+	case ActionCreated:
+		diff.Created++
+	case ActionUpdated:
+		diff.Updated++
+	case ActionReappeared:
+		diff.Reappeared++
+	case ActionMissing:
+		diff.Missing++
+	case ActionNotFound:
+		diff.NotFound++
+	case ActionSkipped:
+		diff.Skipped++
+	case ActionFailed:
+		diff.Failed++
+	default:
+		diff.Unchanged++
+	}
+	if change.Action != ActionMissing {
+		run.Progress.Processed++
+	}
+	if change.Action == "" || change.Action == ActionSkipped {
+		return
+	}
+	if len(diff.Changes) >= maxChanges {
+		diff.ChangesTruncated = true
+		return
+	}
+	diff.Changes = append(diff.Changes, change)
+}
+
+// snapshot copies a run so it can be read without s.mu; callers must hold s.mu.
+func (s *Syncer) snapshot(run *Run) Run {
+	copied := *run
+	copied.Diff.Changes = append([]Change{}, run.Diff.Changes...)
+	return copied
+}
+
+func failed(change Change, err error) Change {
+	change.Action, change.Error = ActionFailed, err.Error()
+	return change
+}
+
+// changedFields lists the fields of a stored car that differ upstream.
+func changedFields(stored, upstream database.Car) []string {
+	var fields []string
+	if stored.Mark != upstream.Mark {
+		fields = append(fields, "mark")
+	}
+	if stored.Model != upstream.Model {
+		fields = append(fields, "model")
+	}
+	if stored.Year != upstream.Year {
+		fields = append(fields, "year")
+	}
+	if !sameOwner(stored.Owner, upstream.Owner) {
+		fields = append(fields, "owner")
+	}
+	return fields
+}
+
+func sameOwner(a, b database.Owner) bool {
+	return a.Name == b.Name && a.Surname == b.Surname && patronymic(a) == patronymic(b)
+}
+
+func patronymic(owner database.Owner) string {
+	if owner.Patronymic == nil {
+		return ""
+	}
+	return *owner.Patronymic
+}
+
+func logger() *slog.Logger {
+	return slog.Default().With("component", "sync")
+}
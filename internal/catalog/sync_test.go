@@ -0,0 +1,366 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/config"
+	"github.com/likimiad/car-management-api/internal/database"
+	"github.com/likimiad/car-management-api/internal/enrichment"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCatalog lists plates a page at a time and answers the details of the
+// ones found in cars, keyed by the plate as listed. With ignorePaging it
+// lists the whole catalog on every call; with block, lookups wait until it is
+// closed or the context is done.
+type fakeCatalog struct {
+	mu           sync.Mutex
+	listed       []string
+	cars         map[string]database.Car
+	listErr      error
+	ignorePaging bool
+	block        chan struct{}
+	offsets      []int
+}
+
+func (c *fakeCatalog) Name() string { return "fake" }
+
+func (c *fakeCatalog) CarInfo(ctx context.Context, regNum string) (database.Car, error) {
+	if c.block != nil {
+		select {
+		case <-c.block:
+		case <-ctx.Done():
+			return database.Car{}, ctx.Err()
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	car, ok := c.cars[regNum]
+	if !ok {
+		return database.Car{}, &enrichment.Error{Provider: c.Name(), RegNum: regNum, Kind: enrichment.ErrNotFound}
+	}
+	return car, nil
+}
+
+func (c *fakeCatalog) RegNums(ctx context.Context, offset, limit int) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offsets = append(c.offsets, offset)
+	if c.listErr != nil {
+		return nil, c.listErr
+	}
+	if c.ignorePaging {
+		return slices.Clone(c.listed), nil
+	}
+	end := min(offset+limit, len(c.listed))
+	if offset >= end {
+		return nil, nil
+	}
+	return slices.Clone(c.listed[offset:end]), nil
+}
+
+func lada(model string, owner database.Owner) database.Car {
+	return database.Car{Mark: "Lada", Model: model, Year: 2020, Owner: owner}
+}
+
+var (
+	petrov  = database.Owner{Name: "Ivan", Surname: "Petrov"}
+	sidorov = database.Owner{Name: "Oleg", Surname: "Sidorov"}
+)
+
+// seed stores the cars of plates, all Lada Vesta 2020 of petrov, and soft-
+// deletes the ones listed in deleted. It returns the car IDs by plate.
+func seed(t *testing.T, store *database.MemoryStore, regNums []string, deleted ...string) map[string]int {
+	t.Helper()
+	ctx := context.Background()
+	ownerID, err := store.GetOrCreateOwner(ctx, petrov)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]int{}
+	for _, regNum := range regNums {
+		id, err := store.AddNewCar(ctx, regNum, "Lada", "Vesta", 2020, ownerID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[regNum] = int(id)
+	}
+	for _, regNum := range deleted {
+		if err := store.DeleteCar(ctx, ids[regNum]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ids
+}
+
+// runSync starts a sync and waits for it to finish.
+func runSync(t *testing.T, syncer *Syncer, flagMissing bool) Run {
+	t.Helper()
+	started, err := syncer.Start(context.Background(), TriggerManual, flagMissing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		current, last := syncer.Status()
+		if current == nil && last != nil && last.ID == started.ID {
+			return *last
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sync %d did not finish", started.ID)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newSyncer(t *testing.T, store database.Store, catalog *fakeCatalog, cfg config.SyncConfig) *Syncer {
+	t.Helper()
+	syncer, err := NewSyncer(store, catalog, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return syncer
+}
+
+func actions(changes []Change) map[string]string {
+	got := map[string]string{}
+	for _, change := range changes {
+		got[change.RegNum] = change.Action
+	}
+	return got
+}
+
+func TestSyncDiff(t *testing.T) {
+	store := database.NewMemoryStore()
+	ids := seed(t, store, []string{"A111AA150", "B222BB150", "C333CC150", "E444EE150", "K555KK150"}, "C333CC150")
+	catalog := &fakeCatalog{
+		// The listing spells plates the way clients do; a second spelling of
+		// A111AA150 is not a new plate.
+		listed: []string{"A111AA150", "в222вв150", "C333CC150", "x666xx150", "а111аа150", "H777HH150", "K555KK150"},
+		cars: map[string]database.Car{
+			"A111AA150": lada("Vesta", petrov),
+			"в222вв150": lada("Granta", sidorov),
+			"C333CC150": lada("Niva", petrov),
+			"x666xx150": lada("Largus", sidorov),
+			"K555KK150": lada("Vesta", petrov),
+		},
+	}
+	syncer := newSyncer(t, store, catalog, config.SyncConfig{PageSize: 2, Workers: 3})
+
+	run := runSync(t, syncer, true)
+	if run.State != StateCompleted || run.Error != "" {
+		t.Fatalf("run = %s %q, want completed", run.State, run.Error)
+	}
+	if want := (Progress{Pages: 4, Listed: 6, Processed: 6}); run.Progress != want {
+		t.Errorf("progress = %+v, want %+v", run.Progress, want)
+	}
+	diff := run.Diff
+	diff.Changes = nil
+	if want := (Diff{Created: 1, Updated: 1, Unchanged: 2, Missing: 1, NotFound: 1, Skipped: 1}); !reflect.DeepEqual(diff, want) {
+		t.Errorf("diff = %+v, want %+v", diff, want)
+	}
+	wantActions := map[string]string{
+		"B222BB150": ActionUpdated,
+		"X666XX150": ActionCreated,
+		"H777HH150": ActionNotFound,
+		"E444EE150": ActionMissing,
+	}
+	if got := actions(run.Diff.Changes); !maps.Equal(got, wantActions) {
+		t.Errorf("changes = %v, want %v", got, wantActions)
+	}
+
+	ctx := context.Background()
+	created, err := store.GetCarByRegNum(ctx, "X666XX150")
+	if err != nil {
+		t.Fatalf("listed plate not stored under its normalized spelling: %v", err)
+	}
+	if created.Model != "Largus" || created.Owner.Surname != "Sidorov" {
+		t.Errorf("created car = %+v", created)
+	}
+	updated, err := store.GetCar(ctx, ids["B222BB150"], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Model != "Granta" || updated.Owner.Surname != "Sidorov" {
+		t.Errorf("updated car = %+v", updated)
+	}
+	skipped, err := store.GetCar(ctx, ids["C333CC150"], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped.Model != "Vesta" {
+		t.Errorf("deleted car was synced: %+v", skipped)
+	}
+	missing, err := store.GetCar(ctx, ids["E444EE150"], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing.UpstreamMissingSince == nil {
+		t.Error("unlisted car is not flagged missing")
+	}
+	if listed, _ := store.GetCar(ctx, ids["A111AA150"], false); listed.UpstreamMissingSince != nil {
+		t.Error("listed car is flagged missing")
+	}
+}
+
+func TestSyncReappeared(t *testing.T) {
+	store := database.NewMemoryStore()
+	ids := seed(t, store, []string{"A111AA150", "B222BB150"})
+	catalog := &fakeCatalog{
+		listed: []string{"A111AA150"},
+		cars:   map[string]database.Car{"A111AA150": lada("Vesta", petrov), "B222BB150": lada("Vesta", petrov)},
+	}
+	syncer := newSyncer(t, store, catalog, config.SyncConfig{PageSize: 10, Workers: 1})
+
+	if run := runSync(t, syncer, true); run.Diff.Missing != 1 {
+		t.Fatalf("first run diff = %+v, want one missing car", run.Diff)
+	}
+	// Without FlagMissing a car stays flagged until it is listed again.
+	if run := runSync(t, syncer, false); run.Diff.Missing != 0 || run.Diff.Reappeared != 0 {
+		t.Fatalf("second run diff = %+v, want nothing", run.Diff)
+	}
+
+	catalog.mu.Lock()
+	catalog.listed = append(catalog.listed, "B222BB150")
+	catalog.mu.Unlock()
+	run := runSync(t, syncer, true)
+	if run.Diff.Reappeared != 1 || run.Diff.Missing != 0 {
+		t.Errorf("third run diff = %+v, want one reappeared car", run.Diff)
+	}
+	car, err := store.GetCar(context.Background(), ids["B222BB150"], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if car.UpstreamMissingSince != nil {
+		t.Error("reappeared car is still flagged missing")
+	}
+	if run.ID != 3 {
+		t.Errorf("run id = %d, want 3", run.ID)
+	}
+}
+
+func TestSyncStopsWhenPagingIsIgnored(t *testing.T) {
+	store := database.NewMemoryStore()
+	catalog := &fakeCatalog{
+		listed:       []string{"A111AA150", "B222BB150", "C333CC150"},
+		cars:         map[string]database.Car{},
+		ignorePaging: true,
+	}
+	syncer := newSyncer(t, store, catalog, config.SyncConfig{PageSize: 2, Workers: 2})
+
+	run := runSync(t, syncer, false)
+	if run.State != StateCompleted {
+		t.Fatalf("run = %s %q, want completed", run.State, run.Error)
+	}
+	if want := (Progress{Pages: 2, Listed: 3, Processed: 3}); run.Progress != want {
+		t.Errorf("progress = %+v, want %+v", run.Progress, want)
+	}
+	if want := []int{0, 3}; !slices.Equal(catalog.offsets, want) {
+		t.Errorf("offsets = %v, want %v", catalog.offsets, want)
+	}
+	if run.Diff.NotFound != 3 {
+		t.Errorf("diff = %+v, want three plates not found", run.Diff)
+	}
+}
+
+func TestSyncListingFails(t *testing.T) {
+	store := database.NewMemoryStore()
+	ids := seed(t, store, []string{"A111AA150"})
+	catalog := &fakeCatalog{listErr: errors.New("boom")}
+	syncer := newSyncer(t, store, catalog, config.SyncConfig{PageSize: 10, Workers: 1})
+
+	run := runSync(t, syncer, true)
+	if run.State != StateFailed || run.Error != "error listing upstream catalog at offset 0: boom" {
+		t.Errorf("run = %s %q, want failed listing", run.State, run.Error)
+	}
+	car, err := store.GetCar(context.Background(), ids["A111AA150"], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if car.UpstreamMissingSince != nil || run.Diff.Missing != 0 {
+		t.Error("a failed listing flagged cars missing")
+	}
+}
+
+func TestSyncKeepsBoundedChanges(t *testing.T) {
+	store := database.NewMemoryStore()
+	catalog := &fakeCatalog{cars: map[string]database.Car{}}
+	for i := 0; i < maxChanges+5; i++ {
+		catalog.listed = append(catalog.listed, fmt.Sprintf("P%03d", i))
+	}
+	syncer := newSyncer(t, store, catalog, config.SyncConfig{PageSize: 50, Workers: 4})
+
+	run := runSync(t, syncer, false)
+	if run.Diff.NotFound != maxChanges+5 {
+		t.Errorf("not found = %d, want %d", run.Diff.NotFound, maxChanges+5)
+	}
+	if len(run.Diff.Changes) != maxChanges || !run.Diff.ChangesTruncated {
+		t.Errorf("changes = %d, truncated %v, want %d, true", len(run.Diff.Changes), run.Diff.ChangesTruncated, maxChanges)
+	}
+}
+
+func TestSyncOneAtATimeAndShutdown(t *testing.T) {
+	store := database.NewMemoryStore()
+	catalog := &fakeCatalog{
+		listed: []string{"A111AA150", "B222BB150"},
+		cars:   map[string]database.Car{"A111AA150": lada("Vesta", petrov), "B222BB150": lada("Vesta", petrov)},
+		block:  make(chan struct{}),
+	}
+	syncer := newSyncer(t, store, catalog, config.SyncConfig{PageSize: 10, Workers: 1})
+	ctx := context.Background()
+
+	first, err := syncer.Start(ctx, TriggerManual, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	running, err := syncer.Start(ctx, TriggerSchedule, true)
+	if !errors.Is(err, ErrSyncRunning) || running.ID != first.ID {
+		t.Fatalf("second start = %d, %v, want %d, %v", running.ID, err, first.ID, ErrSyncRunning)
+	}
+	if current, _ := syncer.Status(); current == nil || current.State != StateRunning {
+		t.Fatalf("current = %+v, want a running sync", current)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := syncer.Shutdown(shutdownCtx); err != nil {
+		t.Fatal(err)
+	}
+	current, last := syncer.Status()
+	if current != nil || last == nil || last.ID != first.ID || last.State != StateFailed {
+		t.Fatalf("status after shutdown = %+v, %+v, want the first run failed", current, last)
+	}
+	if last.FinishedAt == nil || last.Error == "" {
+		t.Errorf("stopped run = %+v, want a finish time and an error", last)
+	}
+	if _, err := syncer.Start(ctx, TriggerManual, false); !errors.Is(err, ErrSyncStopped) {
+		t.Errorf("start after shutdown = %v, want %v", err, ErrSyncStopped)
+	}
+}
+
+func TestNewSyncer(t *testing.T) {
+	store := database.NewMemoryStore()
+	if _, err := NewSyncer(store, plainProvider{}, config.SyncConfig{PageSize: 10, Workers: 1}); !errors.Is(err, enrichment.ErrNoCatalog) {
+		t.Errorf("provider without a catalog: error = %v, want %v", err, enrichment.ErrNoCatalog)
+	}
+	for _, cfg := range []config.SyncConfig{{PageSize: 0, Workers: 1}, {PageSize: 10, Workers: 0}} {
+		if _, err := NewSyncer(store, &fakeCatalog{}, cfg); err == nil {
+			t.Errorf("config %+v accepted", cfg)
+		}
+	}
+}
+
+// plainProvider answers lookups but does not list its plates.
+type plainProvider struct{}
+
+func (plainProvider) Name() string { return "plain" }
+
+func (plainProvider) CarInfo(ctx context.Context, regNum string) (database.Car, error) {
+	return database.Car{}, nil
+}
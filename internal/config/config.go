@@ -4,37 +4,156 @@ import (
 	"fmt"
 	"github.com/ilyakaznacheev/cleanenv"
 	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+// DatabaseConfig selects the storage backend. The connection settings are
+// only required by the postgres driver.
 type DatabaseConfig struct {
-	Name     string `env:"DB_NAME"     env-required:"true"`
-	User     string `env:"DB_USER"     env-required:"true"`
-	Password string `env:"DB_PASSWORD" env-required:"true"`
-	Port     string `env:"DB_PORT"     env-required:"true"`
-	Host     string `env:"DB_HOST"     env-required:"true"`
+	Name           string        `env:"DB_NAME"`
+	User           string        `env:"DB_USER"`
+	Password       string        `env:"DB_PASSWORD"`
+	Port           string        `env:"DB_PORT"`
+	Host           string        `env:"DB_HOST"`
+	Driver         string        `env:"DB_DRIVER"          env-default:"postgres"`
+	MigrateMode    string        `env:"DB_MIGRATE_MODE"    env-default:"up"`
+	PurgeRetention time.Duration `env:"DB_PURGE_RETENTION" env-default:"720h"`
+	PurgeInterval  time.Duration `env:"DB_PURGE_INTERVAL"  env-default:"1h"`
+}
+
+// Validate checks that the connection settings the driver needs are set and
+// that the purge retention is not negative.
+func (c DatabaseConfig) Validate() error {
+	if c.PurgeRetention < 0 {
+		return fmt.Errorf("DB_PURGE_RETENTION cannot be negative, got %s", c.PurgeRetention)
+	}
+	if c.Driver != "postgres" {
+		return nil
+	}
+	var missing []string
+	for _, setting := range []struct{ name, value string }{
+		{"DB_NAME", c.Name}, {"DB_USER", c.User}, {"DB_PASSWORD", c.Password}, {"DB_PORT", c.Port}, {"DB_HOST", c.Host},
+	} {
+		if setting.value == "" {
+			missing = append(missing, setting.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the postgres driver requires %s", strings.Join(missing, ", "))
+	}
+	return nil
 }
 
 type HTTPServer struct {
-	Address          string        `env:"HTTP_ADDRESS"             env-default:"0.0.0.0:8080"`
-	Timeout          time.Duration `env:"HTTP_TIMEOUT"             env-default:"5s"`
-	IdleTimeout      time.Duration `env:"HTTP_IDLE_TIMEOUT"        env-default:"30s"`
-	MaxWorkers       int           `env:"HTTP_MAX_WORKERS"         env-default:"10"`
-	ThirdPartyAPIURL string        `env:"HTTP_THIRD_PARTY_API_URL" env-required:"true"`
-	DebugMode        bool          `env:"HTTP_DEBUG_MODE"          env-default:"true"`
+	Address            string        `env:"HTTP_ADDRESS"               env-default:"0.0.0.0:8080"`
+	Timeout            time.Duration `env:"HTTP_TIMEOUT"               env-default:"5s"`
+	ReadTimeout        time.Duration `env:"HTTP_READ_TIMEOUT"          env-default:"15s"`
+	IdleTimeout        time.Duration `env:"HTTP_IDLE_TIMEOUT"          env-default:"30s"`
+	ReadHeaderTimeout  time.Duration `env:"HTTP_READ_HEADER_TIMEOUT"   env-default:"5s"`
+	WriteTimeout       time.Duration `env:"HTTP_WRITE_TIMEOUT"         env-default:"60s"`
+	MaxHeaderBytes     int           `env:"HTTP_MAX_HEADER_BYTES"      env-default:"1048576"`
+	ShutdownTimeout    time.Duration `env:"HTTP_SHUTDOWN_TIMEOUT"      env-default:"30s"`
+	ShutdownDelay      time.Duration `env:"HTTP_SHUTDOWN_DELAY"        env-default:"0s"`
+	MaxWorkers         int           `env:"HTTP_MAX_WORKERS"           env-default:"10"`
+	ThirdPartyAPIURL   string        `env:"HTTP_THIRD_PARTY_API_URL"`
+	ThirdPartyAPIs     []string      `env:"HTTP_THIRD_PARTY_APIS"`
+	EnrichStrategy     string        `env:"HTTP_ENRICH_STRATEGY"       env-default:"fallback"`
+	UpstreamRetries    int           `env:"HTTP_UPSTREAM_RETRIES"      env-default:"3"`
+	UpstreamBackoff    time.Duration `env:"HTTP_UPSTREAM_BACKOFF"      env-default:"100ms"`
+	UpstreamMaxBackoff time.Duration `env:"HTTP_UPSTREAM_MAX_BACKOFF"  env-default:"2s"`
+	BreakerThreshold   int           `env:"HTTP_BREAKER_THRESHOLD"     env-default:"5"`
+	BreakerCooldown    time.Duration `env:"HTTP_BREAKER_COOLDOWN"      env-default:"30s"`
+}
+
+type EnrichmentConfig struct {
+	Timeout          time.Duration     `env:"ENRICH_TIMEOUT"            env-default:"10s"`
+	MaxIdleConns     int               `env:"ENRICH_MAX_IDLE_CONNS"     env-default:"100"`
+	MaxConnsPerHost  int               `env:"ENRICH_MAX_CONNS_PER_HOST" env-default:"10"`
+	IdleConnTimeout  time.Duration     `env:"ENRICH_IDLE_CONN_TIMEOUT"  env-default:"90s"`
+	AuthToken        string            `env:"ENRICH_AUTH_TOKEN"`
+	Headers          map[string]string `env:"ENRICH_HEADERS"`
+	CacheSize        int               `env:"ENRICH_CACHE_SIZE"         env-default:"10000"`
+	CacheTTL         time.Duration     `env:"ENRICH_CACHE_TTL"          env-default:"1h"`
+	CacheNegativeTTL time.Duration     `env:"ENRICH_CACHE_NEGATIVE_TTL" env-default:"5m"`
+}
+
+// SyncConfig controls the upstream catalog sync. A zero Interval disables the
+// schedule; a sync can still be started by hand.
+type SyncConfig struct {
+	Interval    time.Duration `env:"SYNC_INTERVAL"     env-default:"0"`
+	PageSize    int           `env:"SYNC_PAGE_SIZE"    env-default:"100"`
+	Workers     int           `env:"SYNC_WORKERS"      env-default:"4"`
+	FlagMissing bool          `env:"SYNC_FLAG_MISSING" env-default:"false"`
+}
+
+type ImportConfig struct {
+	Workers   int `env:"IMPORT_WORKERS"    env-default:"10"`
+	BatchSize int `env:"IMPORT_BATCH_SIZE" env-default:"500"`
+	MaxPlates int `env:"IMPORT_MAX_PLATES" env-default:"100000"`
+}
+
+// PlateConfig selects the accepted plate formats: the built-in ones of
+// Countries plus the regular expressions of Formats, given as
+// "BY:^[0-9]{4}[A-Z]{2}[0-9]$;KZ:..." and matched against normalized plates.
+type PlateConfig struct {
+	Countries []string          `env:"PLATE_COUNTRIES" env-default:"RU"`
+	Formats   map[string]string `env:"PLATE_FORMATS"   env-separator:";"`
+}
+
+// LogConfig selects the log format, text or json, and the lowest level
+// logged: debug, info, warn or error.
+type LogConfig struct {
+	Format string `env:"LOG_FORMAT" env-default:"text"`
+	Level  string `env:"LOG_LEVEL"  env-default:"info"`
+}
+
+// TracingConfig selects where spans are exported: none, stdout or otlp. The
+// OTLP exporter sends them over HTTP to Endpoint, or to the endpoint given by
+// the standard OTEL_EXPORTER_OTLP_* variables when it is empty.
+type TracingConfig struct {
+	Exporter    string  `env:"TRACE_EXPORTER"      env-default:"none"`
+	Endpoint    string  `env:"TRACE_OTLP_ENDPOINT"`
+	ServiceName string  `env:"TRACE_SERVICE_NAME"  env-default:"car-management-api"`
+	SampleRatio float64 `env:"TRACE_SAMPLE_RATIO"  env-default:"1"`
+}
+
+// HealthConfig sets the timeout of every readiness check, how long the outcome
+// of probing the upstream is reused, and the checks, among database,
+// migrations and upstream, whose failure makes the service unready; the
+// others only mark it degraded.
+type HealthConfig struct {
+	Timeout  time.Duration `env:"HEALTH_TIMEOUT"   env-default:"2s"`
+	ProbeTTL time.Duration `env:"HEALTH_PROBE_TTL" env-default:"10s"`
+	Critical []string      `env:"HEALTH_CRITICAL"  env-default:"database,migrations"`
+}
+
+// AdminConfig lists the actors, as sent in X-Actor, allowed to use the admin
+// and audit routes and to see soft-deleted cars once they present their token.
+type AdminConfig struct {
+	Actors []string `env:"ADMIN_ACTORS"`
 }
 
 type Config struct {
-	SecretKey      string `env:"SECRET_KEY"`
-	HTTPServer     `env:"http_server"`
-	DatabaseConfig `env:"database"`
+	SecretKey        string `env:"SECRET_KEY"`
+	HTTPServer       `env:"http_server"`
+	DatabaseConfig   `env:"database"`
+	EnrichmentConfig `env:"enrichment"`
+	SyncConfig       `env:"sync"`
+	ImportConfig     `env:"import"`
+	PlateConfig      `env:"plate"`
+	LogConfig        `env:"log"`
+	TracingConfig    `env:"tracing"`
+	HealthConfig     `env:"health"`
+	AdminConfig      `env:"admin"`
 }
 
 func GetConfig() *Config {
 	defer func(start time.Time) {
-		fmt.Printf("%s [%s] %s %s\n", time.Now().Format("2006-01-02 15:04:05"), "START", "load config", time.Since(start))
+		slog.Info("load config", "duration", time.Since(start))
 	}(time.Now())
 	return loadConfig()
 }
@@ -58,6 +177,9 @@ func loadConfig() *Config {
 	if err != nil {
 		log.Fatalf("error reading config file: %s", err)
 	}
+	if err = cfg.DatabaseConfig.Validate(); err != nil {
+		log.Fatalf("error reading config file: %s", err)
+	}
 
 	return &cfg
 }
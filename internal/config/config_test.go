@@ -0,0 +1,32 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDatabaseConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  DatabaseConfig
+		want string
+	}{
+		{"memory needs no connection", DatabaseConfig{Driver: "memory"}, ""},
+		{"postgres complete", DatabaseConfig{Driver: "postgres", Name: "cars", User: "u", Password: "p", Port: "5432", Host: "db"}, ""},
+		{"postgres without host", DatabaseConfig{Driver: "postgres", Name: "cars", User: "u", Password: "p", Port: "5432"}, "DB_HOST"},
+		{"postgres without anything", DatabaseConfig{Driver: "postgres"}, "DB_NAME, DB_USER, DB_PASSWORD, DB_PORT, DB_HOST"},
+		{"negative retention", DatabaseConfig{Driver: "memory", PurgeRetention: -time.Hour}, "DB_PURGE_RETENTION cannot be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	EntityCar   = "car"
+	EntityOwner = "owner"
+
+	OpCarCreate   = "car.create"
+	OpCarUpdate   = "car.update"
+	OpCarDelete   = "car.delete"
+	OpCarTransfer = "car.transfer"
+	OpCarRestore  = "car.restore"
+	OpCarPurge    = "car.purge"
+	OpCarSync     = "car.sync"
+
+	OpOwnerCreate  = "owner.create"
+	OpOwnerUpdate  = "owner.update"
+	OpOwnerDelete  = "owner.delete"
+	OpOwnerRestore = "owner.restore"
+	OpOwnerPurge   = "owner.purge"
+)
+
+const (
+	ActorAnonymous = "anonymous"
+	ActorSystem    = "system"
+)
+
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Actor      string          `json:"actor"`
+	RequestID  string          `json:"requestId"`
+	Operation  string          `json:"operation"`
+	Entity     string          `json:"entity"`
+	EntityID   int             `json:"entityId"`
+	Before     json.RawMessage `json:"before" swaggertype:"object"`
+	After      json.RawMessage `json:"after" swaggertype:"object"`
+}
+
+// AuditFilter narrows GridAuditLog; zero values match everything.
+type AuditFilter struct {
+	Entity    string
+	EntityID  int
+	Operation string
+	Actor     string
+	RequestID string
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+	Offset    int
+}
+
+// carSnapshot is the audited state of a cars row.
+type carSnapshot struct {
+	ID                   int        `json:"id"`
+	RegNum               string     `json:"regNum"`
+	Mark                 string     `json:"mark"`
+	Model                string     `json:"model"`
+	Year                 int        `json:"year"`
+	OwnerID              int        `json:"ownerId"`
+	DeletedAt            *time.Time `json:"deletedAt,omitempty"`
+	Provenance           Provenance `json:"provenance,omitempty"`
+	UpstreamMissingSince *time.Time `json:"upstreamMissingSince,omitempty"`
+}
+
+type auditContextKey struct{}
+
+type auditMeta struct {
+	actor     string
+	requestID string
+}
+
+// WithAuditMeta attaches the actor and request id recorded by every write made
+// with the returned context.
+func WithAuditMeta(ctx context.Context, actor, requestID string) context.Context {
+	if actor == "" {
+		actor = ActorAnonymous
+	}
+	return context.WithValue(ctx, auditContextKey{}, auditMeta{actor: actor, requestID: requestID})
+}
+
+func auditMetaFrom(ctx context.Context) auditMeta {
+	if meta, ok := ctx.Value(auditContextKey{}).(auditMeta); ok {
+		return meta
+	}
+	return auditMeta{actor: ActorAnonymous}
+}
+
+// writeAudit appends an audit entry inside the transaction of the change it
+// describes. A nil before or after snapshot is stored as NULL.
+func writeAudit(ctx context.Context, tx *sql.Tx, operation, entity string, entityId int, before, after any) error {
+	beforeJSON, err := snapshotJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := snapshotJSON(after)
+	if err != nil {
+		return err
+	}
+
+	meta := auditMetaFrom(ctx)
+	if _, err = tx.ExecContext(ctx, InsertAuditEntry, meta.actor, meta.requestID, operation, entity, entityId, beforeJSON, afterJSON); err != nil {
+		return fmt.Errorf("error writing audit entry: %w", err)
+	}
+	return nil
+}
+
+func snapshotJSON(snapshot any) ([]byte, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding audit snapshot: %w", err)
+	}
+	return data, nil
+}
+
+func (db *Database) GridAuditLog(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	rows, err := db.QueryContext(ctx, GridAuditLog, filter.Entity, filter.EntityID, filter.Operation, filter.Actor,
+		filter.RequestID, filter.From, filter.To, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("error querying audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.OccurredAt, &e.Actor, &e.RequestID, &e.Operation, &e.Entity, &e.EntityID, &before, &after); err != nil {
+			return nil, fmt.Errorf("error scanning audit entry: %w", err)
+		}
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return entries, nil
+}
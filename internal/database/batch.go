@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	BatchDeleted  = "deleted"
+	BatchUpdated  = "updated"
+	BatchNotFound = "not_found"
+	BatchFailed   = "failed"
+	// BatchRolledBack is reported for cars of an atomic batch that would have
+	// been changed had another car of the batch not failed.
+	BatchRolledBack = "rolled_back"
+)
+
+// BatchOptions controls a batch change. Atomic batches apply to every car or
+// to none; otherwise every car is changed on its own. A dry run reports what
+// would change and leaves the cars alone.
+type BatchOptions struct {
+	Atomic bool
+	DryRun bool
+}
+
+// CarPatch is the change a batch update makes to every car; zero values keep
+// what is stored.
+type CarPatch struct {
+	Mark    string `json:"mark,omitempty"`
+	Model   string `json:"model,omitempty"`
+	Year    int    `json:"year,omitempty"`
+	OwnerID int    `json:"ownerId,omitempty"`
+}
+
+// BatchItem is the outcome of a batch change for one car, with the car
+// before and after the change in the format of the audit log.
+type BatchItem struct {
+	ID     int             `json:"id"`
+	Status string          `json:"status"`
+	Error  string          `json:"error,omitempty"`
+	Before json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After  json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+}
+
+// carTxChange changes one car inside a transaction.
+type carTxChange func(ctx context.Context, tx *sql.Tx, id int) (*carSnapshot, *carSnapshot, error)
+
+// BatchDeleteCars soft-deletes the given cars.
+func (db *Database) BatchDeleteCars(ctx context.Context, ids []int, opts BatchOptions) ([]BatchItem, error) {
+	return db.batch(ctx, ids, opts, BatchDeleted, deleteCarTx)
+}
+
+// BatchUpdateCars applies the patch to the given cars.
+func (db *Database) BatchUpdateCars(ctx context.Context, ids []int, patch CarPatch, opts BatchOptions) ([]BatchItem, error) {
+	return db.batch(ctx, ids, opts, BatchUpdated, func(ctx context.Context, tx *sql.Tx, id int) (*carSnapshot, *carSnapshot, error) {
+		return updateCarTx(ctx, tx, id, patch.Mark, patch.Model, patch.Year, patch.OwnerID)
+	})
+}
+
+// batch runs change for every car, in one transaction when the batch is
+// atomic and in one per car otherwise. Dry runs are always rolled back.
+func (db *Database) batch(ctx context.Context, ids []int, opts BatchOptions, status string, change carTxChange) ([]BatchItem, error) {
+	items := make([]BatchItem, len(ids))
+	if !opts.Atomic {
+		for i, id := range ids {
+			item, err := db.batchTx(ctx, []int{id}, opts.DryRun, status, change)
+			if err != nil {
+				// Only this car's transaction failed; the others stand alone.
+				items[i] = BatchItem{ID: id, Status: BatchFailed, Error: err.Error()}
+				continue
+			}
+			items[i] = item[0]
+		}
+		return items, nil
+	}
+	return db.batchTx(ctx, ids, opts.DryRun, status, change)
+}
+
+// batchTx changes the cars in a single transaction, committing it only if
+// every car succeeded and this is not a dry run.
+func (db *Database) batchTx(ctx context.Context, ids []int, dryRun bool, status string, change carTxChange) ([]BatchItem, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	items := make([]BatchItem, len(ids))
+	failed := false
+	for i, id := range ids {
+		if failed {
+			// The transaction is aborted; the remaining cars are rolled back
+			// with the rest.
+			items[i] = BatchItem{ID: id, Status: BatchRolledBack}
+			continue
+		}
+		before, after, err := change(ctx, tx, id)
+		items[i] = batchItem(id, status, before, after, err)
+		failed = err != nil
+	}
+
+	if failed {
+		for i := range items {
+			if items[i].Status == status {
+				items[i].Status = BatchRolledBack
+			}
+		}
+		return items, nil
+	}
+	if dryRun {
+		return items, nil
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	committed = true
+	return items, nil
+}
+
+func batchItem(id int, status string, before, after *carSnapshot, err error) BatchItem {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return BatchItem{ID: id, Status: BatchNotFound}
+	case err != nil:
+		return BatchItem{ID: id, Status: BatchFailed, Error: err.Error()}
+	}
+	item := BatchItem{ID: id, Status: status}
+	item.Before, _ = snapshotJSON(before)
+	item.After, _ = snapshotJSON(after)
+	return item
+}
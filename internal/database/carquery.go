@@ -0,0 +1,276 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"math"
+	"strings"
+)
+
+// CarFilter narrows GridCarInfo; zero values match everything. Text filters
+// match case-insensitively, any of the listed values being enough: marks,
+// models and owner names as substrings, plates as prefixes. After and Before
+// select a keyset page: the cars sorted strictly after or before a position,
+// still returned in listing order.
+type CarFilter struct {
+	Marks          []string
+	Models         []string
+	Years          []int
+	YearFrom       int
+	YearTo         int
+	RegNumPrefixes []string
+	OwnerNames     []string
+	OwnerSurnames  []string
+	IncludeDeleted bool
+	Sort           []CarSort
+	After          CarKey
+	Before         CarKey
+	Limit          int
+	Offset         int
+}
+
+// CarSort orders a car listing by one field.
+type CarSort struct {
+	Field string
+	Desc  bool
+}
+
+// CarKey is the position of a car in a listing: the values of the sort fields
+// of CarFilter.OrderBy for that car.
+type CarKey []any
+
+var (
+	ErrUnknownSortField = errors.New("unknown sort field")
+	ErrInvalidCarKey    = errors.New("car key does not match the sort of the listing")
+)
+
+// carSortColumns whitelists the fields a car listing can be sorted by.
+var carSortColumns = map[string]string{
+	"id":           "cars.id",
+	"regNum":       "cars.reg_num",
+	"mark":         "cars.mark",
+	"model":        "cars.model",
+	"year":         "cars.year",
+	"ownerName":    "peoples.name",
+	"ownerSurname": "peoples.surname",
+}
+
+func IsCarSortField(field string) bool {
+	_, ok := carSortColumns[field]
+	return ok
+}
+
+// OrderBy returns the sort of the listing, made total by the id as the last
+// tie-breaker.
+func (f CarFilter) OrderBy() []CarSort {
+	order := make([]CarSort, 0, len(f.Sort)+1)
+	for _, s := range f.Sort {
+		order = append(order, s)
+		if s.Field == "id" {
+			return order
+		}
+	}
+	return append(order, CarSort{Field: "id"})
+}
+
+// KeyOf returns the position of a car in the listing.
+func (f CarFilter) KeyOf(car Car) CarKey {
+	order := f.OrderBy()
+	key := make(CarKey, len(order))
+	for i, s := range order {
+		key[i] = carSortValue(car, s.Field)
+	}
+	return key
+}
+
+// NormalizeKey checks a key decoded from JSON against the sort of the listing
+// and restores the Go types of its values.
+func (f CarFilter) NormalizeKey(key CarKey) (CarKey, error) {
+	order := f.OrderBy()
+	if len(key) != len(order) {
+		return nil, ErrInvalidCarKey
+	}
+	normalized := make(CarKey, len(key))
+	for i, s := range order {
+		switch v := key[i].(type) {
+		case float64:
+			if !isNumericSortField(s.Field) || v != math.Trunc(v) {
+				return nil, ErrInvalidCarKey
+			}
+			normalized[i] = int(v)
+		case int:
+			if !isNumericSortField(s.Field) {
+				return nil, ErrInvalidCarKey
+			}
+			normalized[i] = v
+		case string:
+			if isNumericSortField(s.Field) {
+				return nil, ErrInvalidCarKey
+			}
+			normalized[i] = v
+		default:
+			return nil, ErrInvalidCarKey
+		}
+	}
+	return normalized, nil
+}
+
+func (f CarFilter) validate() error {
+	for _, s := range f.Sort {
+		if !IsCarSortField(s.Field) {
+			return fmt.Errorf("%w: %s", ErrUnknownSortField, s.Field)
+		}
+	}
+	return nil
+}
+
+func carSortValue(car Car, field string) any {
+	switch field {
+	case "regNum":
+		return car.RegNum
+	case "mark":
+		return car.Mark
+	case "model":
+		return car.Model
+	case "year":
+		return car.Year
+	case "ownerName":
+		return car.Owner.Name
+	case "ownerSurname":
+		return car.Owner.Surname
+	default:
+		return car.ID
+	}
+}
+
+func isNumericSortField(field string) bool {
+	return field == "id" || field == "year"
+}
+
+// compareCarKeys compares two positions under the given sort, returning a
+// negative number when a comes first.
+func compareCarKeys(order []CarSort, a, b CarKey) int {
+	for i, s := range order {
+		var c int
+		switch av := a[i].(type) {
+		case int:
+			c = av - b[i].(int)
+		case string:
+			c = strings.Compare(av, b[i].(string))
+		}
+		if s.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// queryArgs collects the parameters of a query built at run time.
+type queryArgs []any
+
+func (a *queryArgs) add(value any) string {
+	*a = append(*a, value)
+	return fmt.Sprintf("$%d", len(*a))
+}
+
+// carWhere translates the filter into a parameterized WHERE clause, page
+// bounds included.
+func carWhere(f CarFilter, args *queryArgs) string {
+	var conds []string
+	anyLike := func(column string, patterns []string) {
+		if len(patterns) > 0 {
+			conds = append(conds, fmt.Sprintf("%s ILIKE ANY(%s)", column, args.add(pq.Array(patterns))))
+		}
+	}
+
+	anyLike("cars.mark", containsPatterns(f.Marks))
+	anyLike("cars.model", containsPatterns(f.Models))
+	anyLike("cars.reg_num", prefixPatterns(f.RegNumPrefixes))
+	anyLike("peoples.name", containsPatterns(f.OwnerNames))
+	anyLike("peoples.surname", containsPatterns(f.OwnerSurnames))
+	if len(f.Years) > 0 {
+		years := make([]int64, len(f.Years))
+		for i, year := range f.Years {
+			years[i] = int64(year)
+		}
+		conds = append(conds, fmt.Sprintf("cars.year = ANY(%s)", args.add(pq.Array(years))))
+	}
+	if f.YearFrom != 0 {
+		conds = append(conds, "cars.year >= "+args.add(f.YearFrom))
+	}
+	if f.YearTo != 0 {
+		conds = append(conds, "cars.year <= "+args.add(f.YearTo))
+	}
+	if !f.IncludeDeleted {
+		conds = append(conds, "cars.deleted_at IS NULL")
+	}
+
+	order := f.OrderBy()
+	if f.After != nil {
+		conds = append(conds, carKeyset(order, f.After, true, args))
+	}
+	if f.Before != nil {
+		conds = append(conds, carKeyset(order, f.Before, false, args))
+	}
+	if len(conds) == 0 {
+		return ""
+	}
+	return "\n\t\tWHERE " + strings.Join(conds, " AND ")
+}
+
+// carKeyset matches the cars sorted after (or before) the key. Sort
+// directions may be mixed, so it is spelled out instead of using a row
+// comparison.
+func carKeyset(order []CarSort, key CarKey, after bool, args *queryArgs) string {
+	var alternatives []string
+	for i, s := range order {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, fmt.Sprintf("%s = %s", carSortColumns[order[j].Field], args.add(key[j])))
+		}
+		op := ">"
+		if s.Desc == after {
+			op = "<"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s %s", carSortColumns[s.Field], op, args.add(key[i])))
+		alternatives = append(alternatives, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")"
+}
+
+// carOrder builds the ORDER BY clause, reversed when reading a page backwards.
+func carOrder(order []CarSort, reverse bool) string {
+	columns := make([]string, len(order))
+	for i, s := range order {
+		columns[i] = carSortColumns[s.Field]
+		if s.Desc != reverse {
+			columns[i] += " DESC"
+		}
+	}
+	return "\n\t\tORDER BY " + strings.Join(columns, ", ")
+}
+
+func containsPatterns(values []string) []string {
+	patterns := make([]string, len(values))
+	for i, value := range values {
+		patterns[i] = "%" + escapeLike(value) + "%"
+	}
+	return patterns
+}
+
+func prefixPatterns(values []string) []string {
+	patterns := make([]string, len(values))
+	for i, value := range values {
+		patterns[i] = escapeLike(value) + "%"
+	}
+	return patterns
+}
+
+// escapeLike makes user input match literally inside a LIKE pattern.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"github.com/lib/pq"
+	"reflect"
+	"testing"
+)
+
+func TestCarWhere(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter CarFilter
+		sql    string
+		args   queryArgs
+	}{
+		{
+			name:   "with deleted, no bounds",
+			filter: CarFilter{IncludeDeleted: true},
+			sql:    "",
+		},
+		{
+			name:   "active cars",
+			filter: CarFilter{},
+			sql:    "\n\t\tWHERE cars.deleted_at IS NULL",
+		},
+		{
+			name:   "text filters escape LIKE",
+			filter: CarFilter{Marks: []string{"to%yo"}, RegNumPrefixes: []string{"A1_"}, OwnerSurnames: []string{`pe\t`}},
+			sql:    "\n\t\tWHERE cars.mark ILIKE ANY($1) AND cars.reg_num ILIKE ANY($2) AND peoples.surname ILIKE ANY($3) AND cars.deleted_at IS NULL",
+			args:   queryArgs{pq.Array([]string{`%to\%yo%`}), pq.Array([]string{`A1\_%`}), pq.Array([]string{`%pe\\t%`})},
+		},
+		{
+			name:   "years",
+			filter: CarFilter{Years: []int{2005, 2010}, YearFrom: 2000, YearTo: 2020, IncludeDeleted: true},
+			sql:    "\n\t\tWHERE cars.year = ANY($1) AND cars.year >= $2 AND cars.year <= $3",
+			args:   queryArgs{pq.Array([]int64{2005, 2010}), 2000, 2020},
+		},
+		{
+			name:   "after an id",
+			filter: CarFilter{After: CarKey{7}, IncludeDeleted: true},
+			sql:    "\n\t\tWHERE ((cars.id > $1))",
+			args:   queryArgs{7},
+		},
+		{
+			name:   "before in a descending sort",
+			filter: CarFilter{Sort: []CarSort{{Field: "year", Desc: true}}, Before: CarKey{2010, 7}, IncludeDeleted: true},
+			sql:    "\n\t\tWHERE ((cars.year > $1) OR (cars.year = $2 AND cars.id < $3))",
+			args:   queryArgs{2010, 2010, 7},
+		},
+		{
+			name: "after in a mixed sort",
+			filter: CarFilter{
+				Marks: []string{"ford"},
+				Sort:  []CarSort{{Field: "mark"}, {Field: "year", Desc: true}},
+				After: CarKey{"Ford", 2010, 3},
+			},
+			sql: "\n\t\tWHERE cars.mark ILIKE ANY($1) AND cars.deleted_at IS NULL AND " +
+				"((cars.mark > $2) OR (cars.mark = $3 AND cars.year < $4) OR (cars.mark = $5 AND cars.year = $6 AND cars.id > $7))",
+			args: queryArgs{pq.Array([]string{"%ford%"}), "Ford", "Ford", 2010, "Ford", 2010, 3},
+		},
+		{
+			name: "between two keys",
+			filter: CarFilter{
+				Sort:           []CarSort{{Field: "ownerSurname", Desc: true}},
+				After:          CarKey{"Sidorova", 2},
+				Before:         CarKey{"Petrov", 5},
+				IncludeDeleted: true,
+			},
+			sql: "\n\t\tWHERE ((peoples.surname < $1) OR (peoples.surname = $2 AND cars.id > $3)) AND " +
+				"((peoples.surname > $4) OR (peoples.surname = $5 AND cars.id < $6))",
+			args: queryArgs{"Sidorova", "Sidorova", 2, "Petrov", "Petrov", 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args queryArgs
+			if got := carWhere(tt.filter, &args); got != tt.sql {
+				t.Errorf("sql:\n got %q\nwant %q", got, tt.sql)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %#v, want %#v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestCarOrder(t *testing.T) {
+	order := CarFilter{Sort: []CarSort{{Field: "mark"}, {Field: "year", Desc: true}}}.OrderBy()
+	if got, want := carOrder(order, false), "\n\t\tORDER BY cars.mark, cars.year DESC, cars.id"; got != want {
+		t.Errorf("forward order %q, want %q", got, want)
+	}
+	if got, want := carOrder(order, true), "\n\t\tORDER BY cars.mark DESC, cars.year, cars.id DESC"; got != want {
+		t.Errorf("reversed order %q, want %q", got, want)
+	}
+}
+
+func TestNormalizeKey(t *testing.T) {
+	filter := CarFilter{Sort: []CarSort{{Field: "mark"}, {Field: "year"}}}
+	tests := []struct {
+		name string
+		key  CarKey
+		want CarKey
+		ok   bool
+	}{
+		{"decoded from JSON", CarKey{"Ford", 2010.0, 3.0}, CarKey{"Ford", 2010, 3}, true},
+		{"ints", CarKey{"Ford", 2010, 3}, CarKey{"Ford", 2010, 3}, true},
+		{"fractional number", CarKey{"Ford", 2010.5, 3.0}, nil, false},
+		{"number for a text field", CarKey{1.0, 2010.0, 3.0}, nil, false},
+		{"text for a numeric field", CarKey{"Ford", "2010", 3.0}, nil, false},
+		{"too short", CarKey{"Ford", 2010.0}, nil, false},
+		{"null", CarKey{"Ford", nil, 3.0}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filter.NormalizeKey(tt.key)
+			if (err == nil) != tt.ok {
+				t.Fatalf("error = %v, want ok %t", err, tt.ok)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("key = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,18 +3,52 @@ package database
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"github.com/likimiad/car-management-api/internal/logging"
+	"slices"
+	"time"
 )
 
 type Car struct {
-	ID     int    `json:"id"`
-	RegNum string `json:"regNum"`
-	Mark   string `json:"mark"`
-	Model  string `json:"model"`
-	Year   int    `json:"year"`
-	Owner  Owner  `json:"owner"`
+	ID         int        `json:"id"`
+	RegNum     string     `json:"regNum"`
+	Mark       string     `json:"mark"`
+	Model      string     `json:"model"`
+	Year       int        `json:"year"`
+	Owner      Owner      `json:"owner"`
+	DeletedAt  *time.Time `json:"deletedAt,omitempty"`
+	Provenance Provenance `json:"provenance,omitempty"`
+	// UpstreamMissingSince is set by the catalog sync when the plate is no
+	// longer listed by the upstream API.
+	UpstreamMissingSince *time.Time `json:"upstreamMissingSince,omitempty"`
+}
+
+// Provenance records which car info provider supplied each field of a car,
+// keyed by the JSON name of the field, owner fields prefixed with "owner.".
+type Provenance map[string]string
+
+func (p Provenance) Value() (driver.Value, error) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(p)
+}
+
+func (p *Provenance) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("cannot scan %T into Provenance", src)
+	}
 }
 
 type Owner struct {
@@ -24,53 +58,70 @@ type Owner struct {
 	Patronymic *string `json:"patronymic"`
 }
 
-var ErrCarExists = errors.New("a car with that plate is already in the database")
+var (
+	ErrCarExists     = errors.New("a car with that plate is already in the database")
+	ErrCarDeleted    = errors.New("a car with that plate is in the database but deleted")
+	ErrCarNotDeleted = errors.New("car is not deleted")
+)
 
-func (db *Database) GridCarInfo(ctx context.Context, mark, model string, year, limit, offset int) ([]Car, error) {
-	markParam := "%" + mark + "%"
-	modelParam := "%" + model + "%"
-	if mark == "" {
-		markParam = "%"
-	}
-	if model == "" {
-		modelParam = "%"
-	}
-	yearParam := year
-	if year == 0 {
-		yearParam = 0
+// GridCarInfo lists the cars matching the filter. The SQL is assembled from
+// the whitelisted fields of the filter; every value is passed as a parameter.
+func (db *Database) GridCarInfo(ctx context.Context, filter CarFilter) ([]Car, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
 	}
 
-	rows, err := db.QueryContext(ctx, GridCarInfo, markParam, modelParam, yearParam, limit, offset)
+	var args queryArgs
+	backward := filter.Before != nil
+	query := GridCarInfo + carWhere(filter, &args) + carOrder(filter.OrderBy(), backward) +
+		"\n\t\tLIMIT " + args.add(filter.Limit) + " OFFSET " + args.add(filter.Offset) + ";"
+
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("error querying cars: %v", err)
+		return nil, fmt.Errorf("error querying cars: %w", err)
 	}
 	defer rows.Close()
 
 	var cars []Car
 	for rows.Next() {
 		var car Car
-		if err := rows.Scan(&car.ID, &car.RegNum, &car.Mark, &car.Model, &car.Year, &car.Owner.ID, &car.Owner.Name, &car.Owner.Surname, &car.Owner.Patronymic); err != nil {
+		if err := rows.Scan(&car.ID, &car.RegNum, &car.Mark, &car.Model, &car.Year, &car.DeletedAt, &car.Provenance, &car.UpstreamMissingSince, &car.Owner.ID, &car.Owner.Name, &car.Owner.Surname, &car.Owner.Patronymic); err != nil {
 			continue
 		}
 		cars = append(cars, car)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error during rows iteration: %v", err)
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	if backward {
+		slices.Reverse(cars)
 	}
 	return cars, nil
 }
 
-func (db *Database) GetCar(ctx context.Context, id int) (*Car, error) {
+// CountCars counts the cars matching the filter, ignoring its page bounds.
+func (db *Database) CountCars(ctx context.Context, filter CarFilter) (int, error) {
+	filter.After, filter.Before = nil, nil
+
+	var args queryArgs
+	var total int
+	err := db.QueryRowContext(ctx, CountCars+carWhere(filter, &args)+";", args...).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("error counting cars: %w", err)
+	}
+	return total, nil
+}
+
+func (db *Database) GetCar(ctx context.Context, id int, includeDeleted bool) (*Car, error) {
 	var car Car
 	var owner Owner
 	car.Owner = owner
-	fmt.Println(id)
-	err := db.QueryRowContext(ctx, GridOneCarInfo, id).Scan(&car.ID, &car.RegNum, &car.Mark, &car.Model, &car.Year, &car.Owner.ID, &car.Owner.Name, &car.Owner.Surname, &car.Owner.Patronymic)
+	err := db.QueryRowContext(ctx, GridOneCarInfo, id, includeDeleted).Scan(&car.ID, &car.RegNum, &car.Mark, &car.Model, &car.Year, &car.DeletedAt, &car.Provenance, &car.UpstreamMissingSince, &car.Owner.ID, &car.Owner.Name, &car.Owner.Surname, &car.Owner.Patronymic)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, err
 		}
-		return nil, fmt.Errorf("Error querying GetCar: %v", err)
+		return nil, fmt.Errorf("Error querying GetCar: %w", err)
 	}
 	return &car, nil
 }
@@ -78,7 +129,7 @@ func (db *Database) GetCar(ctx context.Context, id int) (*Car, error) {
 func (db *Database) DeleteCar(ctx context.Context, id int) error {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
+		return fmt.Errorf("error starting transaction: %w", err)
 	}
 	committed := false
 	defer func() {
@@ -87,13 +138,62 @@ func (db *Database) DeleteCar(ctx context.Context, id int) error {
 		}
 	}()
 
-	_, err = tx.ExecContext(ctx, DeleteCar, id)
+	if _, _, err = deleteCarTx(ctx, tx, id); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+// RestoreCar brings back a soft-deleted car.
+func (db *Database) RestoreCar(ctx context.Context, id int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	before, err := lockCar(ctx, tx, id, true)
 	if err != nil {
-		return fmt.Errorf("error deleting car: %v", err)
+		return err
+	}
+	if before.DeletedAt == nil {
+		return ErrCarNotDeleted
+	}
+
+	if _, err = tx.ExecContext(ctx, RestoreCar, id); err != nil {
+		return fmt.Errorf("error restoring car: %w", err)
+	}
+
+	after := *before
+	after.DeletedAt = nil
+	if err = writeAudit(ctx, tx, OpCarRestore, EntityCar, id, before, after); err != nil {
+		return err
+	}
+
+	// The owner may have been deleted along with the car; it comes back too.
+	var owner Owner
+	err = tx.QueryRowContext(ctx, RestoreOwner, before.OwnerID).Scan(&owner.ID, &owner.Name, &owner.Surname, &owner.Patronymic)
+	switch {
+	case err == nil:
+		if err = writeAudit(ctx, tx, OpOwnerRestore, EntityOwner, owner.ID, nil, owner); err != nil {
+			return err
+		}
+	case err != sql.ErrNoRows:
+		return fmt.Errorf("error restoring owner: %w", err)
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
+		return fmt.Errorf("error committing transaction: %w", err)
 	}
 	committed = true
 	return nil
@@ -102,7 +202,7 @@ func (db *Database) DeleteCar(ctx context.Context, id int) error {
 func (db *Database) UpdateCarInfo(ctx context.Context, id int, mark, model string, year, ownerId int) error {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
+		return fmt.Errorf("error starting transaction: %w", err)
 	}
 	committed := false
 	defer func() {
@@ -111,13 +211,12 @@ func (db *Database) UpdateCarInfo(ctx context.Context, id int, mark, model strin
 		}
 	}()
 
-	_, err = tx.ExecContext(ctx, UpdateCarInfo, mark, model, year, id, ownerId)
-	if err != nil {
-		return fmt.Errorf("error updating car info: %v", err)
+	if _, _, err = updateCarTx(ctx, tx, id, mark, model, year, ownerId); err != nil {
+		return err
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
+		return fmt.Errorf("error committing transaction: %w", err)
 	}
 	committed = true
 	return nil
@@ -127,16 +226,16 @@ func (db *Database) OwnerExists(ctx context.Context, ownerId int) bool {
 	var exists bool
 	err := db.QueryRowContext(ctx, OwnerExists, ownerId).Scan(&exists)
 	if err != nil {
-		log.Printf("error checking if owner exists: %v", err)
+		logging.FromContext(ctx).Error("error checking if owner exists", "ownerId", ownerId, logging.Err(err))
 		return false
 	}
 	return exists
 }
 
-func (db *Database) AddNewCar(ctx context.Context, regNum, mark, model string, year int, ownerId int64) (int64, error) {
+func (db *Database) AddNewCar(ctx context.Context, regNum, mark, model string, year int, ownerId int64, provenance Provenance) (int64, error) {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, fmt.Errorf("error starting transaction: %v", err)
+		return 0, fmt.Errorf("error starting transaction: %w", err)
 	}
 	committed := false
 	defer func() {
@@ -145,53 +244,144 @@ func (db *Database) AddNewCar(ctx context.Context, regNum, mark, model string, y
 		}
 	}()
 
-	var existingId int64
-	err = tx.QueryRowContext(ctx, CheckCarExists, regNum).Scan(&existingId)
-	if err == nil {
-		return existingId, ErrCarExists
-	} else if err != sql.ErrNoRows {
-		return 0, fmt.Errorf("error checking car existence: %v", err)
+	if existingId, err := existingCar(ctx, tx, regNum); err != sql.ErrNoRows {
+		return existingId, err
 	}
 
 	var newId int64
-	err = tx.QueryRowContext(ctx, AddNewCar, regNum, mark, model, year, ownerId).Scan(&newId)
+	err = tx.QueryRowContext(ctx, AddNewCar, regNum, mark, model, year, ownerId, provenance).Scan(&newId)
+	if isUniqueViolation(err) {
+		// A concurrent insert of the plate committed first; report its car.
+		if existingId, err := existingCar(ctx, db, regNum); err != sql.ErrNoRows {
+			return existingId, err
+		}
+	}
 	if err != nil {
-		return 0, fmt.Errorf("error adding new car: %v", err)
+		return 0, fmt.Errorf("error adding new car: %w", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, OpenOwnershipPeriod, newId, ownerId, time.Now(), ReasonRegistered); err != nil {
+		return 0, fmt.Errorf("error recording ownership: %w", err)
+	}
+
+	after := carSnapshot{ID: int(newId), RegNum: regNum, Mark: mark, Model: model, Year: year, OwnerID: int(ownerId), Provenance: provenance}
+	if err = writeAudit(ctx, tx, OpCarCreate, EntityCar, int(newId), nil, after); err != nil {
+		return 0, err
 	}
 
 	if err = tx.Commit(); err != nil {
-		return 0, fmt.Errorf("error committing transaction: %v", err)
+		return 0, fmt.Errorf("error committing transaction: %w", err)
 	}
 	committed = true
 	return newId, nil
 }
 
+// GetOrCreateOwner resolves an owner by name, surname and patronymic, creating
+// it when missing. Concurrent callers with the same identity get the same row:
+// the insert yields to the unique index and the winner is read back.
 func (db *Database) GetOrCreateOwner(ctx context.Context, owner Owner) (int64, error) {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return 0, fmt.Errorf("error starting transaction: %v", err)
-	}
-	committed := false
-	defer func() {
-		if !committed {
-			_ = tx.Rollback()
-		}
-	}()
+	owner = normalizeOwner(owner)
 
 	var ownerId int64
-	err = tx.QueryRowContext(ctx, СheckPerson, owner.Name, owner.Surname).Scan(&ownerId)
-	if err == sql.ErrNoRows {
-		err = tx.QueryRowContext(ctx, AddPerson, owner.Name, owner.Surname).Scan(&ownerId)
+	for attempt := 0; attempt < 3; attempt++ {
+		err := db.QueryRowContext(ctx, СheckPerson, owner.Name, owner.Surname, owner.Patronymic).Scan(&ownerId)
+		if err == nil {
+			return ownerId, nil
+		} else if err != sql.ErrNoRows {
+			return 0, fmt.Errorf("error checking owner existence: %w", err)
+		}
+
+		newId, inserted, err := db.insertOwner(ctx, owner)
 		if err != nil {
 			return 0, err
+		} else if inserted {
+			return newId, nil
 		}
+		// Lost the race to a concurrent insert; read the committed row.
+	}
+	return 0, fmt.Errorf("error resolving owner %s %s: row vanished between insert and lookup", owner.Name, owner.Surname)
+}
+
+// existingCar returns the car stored under the plate with ErrCarExists, or
+// ErrCarDeleted if it is deleted, and sql.ErrNoRows if there is none.
+func existingCar(ctx context.Context, q execQueryer, regNum string) (int64, error) {
+	var id int64
+	var deletedAt *time.Time
+	err := q.QueryRowContext(ctx, CheckCarExists, regNum).Scan(&id, &deletedAt)
+	switch {
+	case err == sql.ErrNoRows:
+		return 0, err
+	case err != nil:
+		return 0, fmt.Errorf("error checking car existence: %w", err)
+	case deletedAt != nil:
+		return id, ErrCarDeleted
+	default:
+		return id, ErrCarExists
+	}
+}
+
+// lockCar reads a car row for update, returning sql.ErrNoRows if it is missing
+// or, unless withDeleted is set, soft-deleted.
+func lockCar(ctx context.Context, tx *sql.Tx, id int, withDeleted bool) (*carSnapshot, error) {
+	var car carSnapshot
+	err := tx.QueryRowContext(ctx, GetCarForUpdate, id).Scan(&car.ID, &car.RegNum, &car.Mark, &car.Model, &car.Year, &car.OwnerID, &car.DeletedAt, &car.Provenance, &car.UpstreamMissingSince)
+	if err == sql.ErrNoRows {
+		return nil, err
 	} else if err != nil {
-		return 0, fmt.Errorf("error checking owner existence: %v", err)
+		return nil, fmt.Errorf("error locking car: %w", err)
 	}
+	if car.DeletedAt != nil && !withDeleted {
+		return nil, sql.ErrNoRows
+	}
+	return &car, nil
+}
 
-	if err = tx.Commit(); err != nil {
-		return 0, fmt.Errorf("error committing transaction: %v", err)
+// deleteCarTx soft-deletes a car and audits it inside tx, returning the car
+// before and after the change.
+func deleteCarTx(ctx context.Context, tx *sql.Tx, id int) (*carSnapshot, *carSnapshot, error) {
+	before, err := lockCar(ctx, tx, id, false)
+	if err != nil {
+		return nil, nil, err
 	}
-	committed = true
-	return ownerId, nil
+
+	after := *before
+	if err = tx.QueryRowContext(ctx, DeleteCar, id).Scan(&after.DeletedAt); err != nil {
+		return nil, nil, fmt.Errorf("error deleting car: %w", err)
+	}
+
+	if err = writeAudit(ctx, tx, OpCarDelete, EntityCar, id, before, after); err != nil {
+		return nil, nil, err
+	}
+	return before, &after, nil
+}
+
+// updateCarTx updates a car, records a change of owner and audits it inside
+// tx, returning the car before and after the change. Empty values, owner
+// included, keep what is stored.
+func updateCarTx(ctx context.Context, tx *sql.Tx, id int, mark, model string, year, ownerId int) (*carSnapshot, *carSnapshot, error) {
+	before, err := lockCar(ctx, tx, id, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ownerId == 0 {
+		ownerId = before.OwnerID
+	}
+
+	var after carSnapshot
+	err = tx.QueryRowContext(ctx, UpdateCarInfo, mark, model, year, id, ownerId).
+		Scan(&after.ID, &after.RegNum, &after.Mark, &after.Model, &after.Year, &after.OwnerID, &after.Provenance, &after.UpstreamMissingSince)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error updating car info: %w", err)
+	}
+
+	if before.OwnerID != ownerId {
+		if err = recordOwnershipChange(ctx, tx, id, ownerId, time.Now(), ReasonUpdated); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err = writeAudit(ctx, tx, OpCarUpdate, EntityCar, id, before, after); err != nil {
+		return nil, nil, err
+	}
+	return before, &after, nil
 }
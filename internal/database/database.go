@@ -1,11 +1,15 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"github.com/XSAM/otelsql"
 	_ "github.com/lib/pq"
 	"github.com/likimiad/car-management-api/internal/config"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"log"
+	"log/slog"
 	"time"
 )
 
@@ -15,11 +19,11 @@ type Database struct {
 
 func InitDatabase(cfg config.DatabaseConfig) *Database {
 	defer func(start time.Time) {
-		fmt.Printf("%s [%s] %s %s\n", time.Now().Format("2006-01-02 15:04:05"), "START", "make connection with database", time.Since(start))
+		slog.Info("make connection with database", "duration", time.Since(start))
 	}(time.Now())
 
 	db := makeConnection(cfg)
-	db.initTables()
+	db.migrate(cfg.MigrateMode)
 	return db
 }
 
@@ -27,7 +31,16 @@ func makeConnection(cfg config.DatabaseConfig) *Database {
 	dbLink := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name)
 
-	db, err := sql.Open("postgres", dbLink)
+	// Every query gets a span, a child of the span of the request or job
+	// running it.
+	db, err := otelsql.Open("postgres", dbLink,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitConnectorConnect: true,
+			OmitRows:             true,
+		}))
 	if err != nil {
 		log.Fatalf("error connection to database: %s", err.Error())
 	}
@@ -39,20 +52,76 @@ func makeConnection(cfg config.DatabaseConfig) *Database {
 	return &Database{db}
 }
 
-func (db *Database) initTables() {
+func (db *Database) migrate(mode string) {
 	defer func(start time.Time) {
-		fmt.Printf("%s [%s] %s %s\n", time.Now().Format("2006-01-02 15:04:05"), "START", "checking the availability of the database", time.Since(start))
+		slog.Info("checking database schema version", "duration", time.Since(start))
 	}(time.Now())
-	db.checkTables()
+
+	migrator, err := NewMigrator(db.DB)
+	if err != nil {
+		log.Fatalf("error loading migrations: %s", err.Error())
+	}
+
+	switch mode {
+	case MigrateModeUp:
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			log.Fatalf("error migrating database: %s", err.Error())
+		}
+		if applied > 0 {
+			slog.Info("applied migrations", "applied", applied)
+		}
+	case MigrateModeVerify:
+		if err := migrator.Verify(context.Background()); err != nil {
+			log.Fatalf("error verifying database schema: %s", err.Error())
+		}
+	default:
+		log.Fatalf("unknown migrate mode %q", mode)
+	}
 }
 
-func (db *Database) checkTables() {
-	_, err := db.Exec(CreateСheckTablePeoples)
+// MigrationStatus reports the schema version of the database against the
+// migrations embedded in the binary.
+func (db *Database) MigrationStatus(ctx context.Context) (MigrationStatus, error) {
+	migrator, err := NewMigrator(db.DB)
 	if err != nil {
-		log.Fatalf("error database during people table initialization: %s", err.Error())
+		return MigrationStatus{}, err
 	}
-	_, err = db.Exec(CreateCheckTableCars)
+	return migrator.Status(ctx)
+}
+
+// RunMigrationCommand executes a one-off migration command ("up", "down" or
+// "status") against the configured database.
+func RunMigrationCommand(cfg config.DatabaseConfig, command string) error {
+	db := makeConnection(cfg)
+	defer db.Close()
+
+	migrator, err := NewMigrator(db.DB)
 	if err != nil {
-		log.Fatalf("error database during cars table initialization: %s", err.Error())
+		return err
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migration(s)\n", applied)
+	case "down":
+		if err := migrator.Down(ctx); err != nil {
+			return err
+		}
+		fmt.Println("rolled back one migration")
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version: %d, latest: %d, dirty: %t\n", status.Current, status.Latest, status.Dirty)
+	default:
+		return fmt.Errorf("unknown migration command %q", command)
 	}
+	return nil
 }
@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportCanceled  = "canceled"
+	ImportFailed    = "failed"
+
+	ItemPending  = "pending"
+	ItemCreated  = "created"
+	ItemExisting = "existing"
+	ItemNotFound = "not_found"
+	ItemFailed   = "failed"
+	// ItemInvalid is reported for plates rejected before any lookup.
+	ItemInvalid = "invalid"
+)
+
+var ErrImportJobState = errors.New("import job is not in a state that allows this")
+
+// ImportJob is a bulk import of plates processed in the background. Counts
+// holds the number of items in each status.
+type ImportJob struct {
+	ID         int            `json:"id"`
+	State      string         `json:"state"`
+	Actor      string         `json:"actor"`
+	RequestID  string         `json:"requestId"`
+	Total      int            `json:"total"`
+	Processed  int            `json:"processed"`
+	Counts     map[string]int `json:"counts"`
+	Error      string         `json:"error,omitempty"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+	FinishedAt *time.Time     `json:"finishedAt,omitempty"`
+}
+
+// ImportItem is one plate of an import job, Position being its index in the
+// submitted list. RegNum is the normalized plate and Spelled the plate as
+// submitted.
+type ImportItem struct {
+	Position int    `json:"position"`
+	RegNum   string `json:"regNum"`
+	Spelled  string `json:"spelled,omitempty"`
+	Status   string `json:"status"`
+	CarID    *int64 `json:"carId,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ImportItemFilter narrows GridImportItems to the given statuses, if any, and
+// to positions from From on.
+type ImportItemFilter struct {
+	Statuses []string
+	From     int
+	Limit    int
+	Offset   int
+}
+
+// CreateImportJob stores a queued job with a pending item for every plate,
+// spelled[i] being how regNums[i] was submitted. The job is attributed to the
+// actor and request of ctx.
+func (db *Database) CreateImportJob(ctx context.Context, regNums, spelled []string) (*ImportJob, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	meta := auditMetaFrom(ctx)
+	job := ImportJob{State: ImportQueued, Actor: meta.actor, RequestID: meta.requestID, Total: len(regNums),
+		Counts: map[string]int{ItemPending: len(regNums)}}
+	err = tx.QueryRowContext(ctx, CreateImportJob, job.State, job.Actor, job.RequestID, job.Total).Scan(&job.ID, &job.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error creating import job: %w", err)
+	}
+	job.UpdatedAt = job.CreatedAt
+
+	if _, err = tx.ExecContext(ctx, AddImportItems, job.ID, pq.Array(regNums), pq.Array(spelled)); err != nil {
+		return nil, fmt.Errorf("error adding import items: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	committed = true
+	return &job, nil
+}
+
+func (db *Database) GetImportJob(ctx context.Context, id int) (*ImportJob, error) {
+	var job ImportJob
+	err := db.QueryRowContext(ctx, GetImportJob, id).Scan(&job.ID, &job.State, &job.Actor, &job.RequestID, &job.Total,
+		&job.Error, &job.CreatedAt, &job.UpdatedAt, &job.FinishedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error querying import job: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, CountImportItems, id)
+	if err != nil {
+		return nil, fmt.Errorf("error counting import items: %w", err)
+	}
+	defer rows.Close()
+
+	job.Counts = map[string]int{}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("error scanning import item count: %w", err)
+		}
+		job.Counts[status] = count
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	job.Processed = job.Total - job.Counts[ItemPending]
+	return &job, nil
+}
+
+func (db *Database) GridImportItems(ctx context.Context, jobID int, filter ImportItemFilter) ([]ImportItem, error) {
+	rows, err := db.QueryContext(ctx, GridImportItems, jobID, pq.Array(filter.Statuses), filter.From, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("error querying import items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []ImportItem{}
+	for rows.Next() {
+		var item ImportItem
+		if err := rows.Scan(&item.Position, &item.RegNum, &item.Spelled, &item.Status, &item.CarID, &item.Error); err != nil {
+			return nil, fmt.Errorf("error scanning import item: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return items, nil
+}
+
+func (db *Database) SetImportItemResult(ctx context.Context, jobID int, item ImportItem) error {
+	_, err := db.ExecContext(ctx, SetImportItemResult, jobID, item.Position, item.Status, item.CarID, item.Error)
+	if err != nil {
+		return fmt.Errorf("error saving import item result: %w", err)
+	}
+	return nil
+}
+
+// SetImportJobState moves a job to state if it is currently in one of from,
+// returning ErrImportJobState otherwise. Terminal states set FinishedAt.
+func (db *Database) SetImportJobState(ctx context.Context, id int, from []string, state, errMsg string) error {
+	var updated int
+	err := db.QueryRowContext(ctx, SetImportJobState, id, state, errMsg, pq.Array(from)).Scan(&updated)
+	if err == sql.ErrNoRows {
+		if _, err = db.GetImportJob(ctx, id); err != nil {
+			return err
+		}
+		return ErrImportJobState
+	} else if err != nil {
+		return fmt.Errorf("error updating import job: %w", err)
+	}
+	return nil
+}
+
+// UnfinishedImportJobs returns the queued and running jobs, oldest first.
+func (db *Database) UnfinishedImportJobs(ctx context.Context) ([]ImportJob, error) {
+	rows, err := db.QueryContext(ctx, UnfinishedImportJobs)
+	if err != nil {
+		return nil, fmt.Errorf("error querying import jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []ImportJob
+	for rows.Next() {
+		var job ImportJob
+		if err := rows.Scan(&job.ID, &job.State, &job.Actor, &job.RequestID); err != nil {
+			return nil, fmt.Errorf("error scanning import job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return jobs, nil
+}
@@ -0,0 +1,1179 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type carRow struct {
+	id         int
+	regNum     string
+	mark       string
+	model      string
+	year       int
+	ownerID    int
+	deletedAt  *time.Time
+	provenance Provenance
+	missingAt  *time.Time
+}
+
+type periodRow struct {
+	id      int
+	carID   int
+	ownerID int
+	from    time.Time
+	to      *time.Time
+	reason  string
+}
+
+// MemoryStore keeps cars and owners in process memory. It mirrors the
+// behaviour of the Postgres queries so the service can run without a database.
+type MemoryStore struct {
+	mu           sync.RWMutex
+	cars         map[int]*carRow
+	carsByPlate  map[string]int
+	owners       map[int]*Owner
+	periods      map[int][]*periodRow
+	audit        []AuditEntry
+	imports      map[int]*importRow
+	nextCarID    int
+	nextOwnerID  int
+	nextPeriodID int
+	nextImportID int
+
+	// deletedOwners marks the owners deleted while soft-deleted cars still
+	// point at them.
+	deletedOwners map[int]time.Time
+}
+
+type importRow struct {
+	job   ImportJob
+	items []ImportItem
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		cars:          make(map[int]*carRow),
+		carsByPlate:   make(map[string]int),
+		owners:        make(map[int]*Owner),
+		deletedOwners: make(map[int]time.Time),
+		periods:       make(map[int][]*periodRow),
+		imports:       make(map[int]*importRow),
+		nextCarID:     1,
+		nextOwnerID:   1,
+		nextPeriodID:  1,
+		nextImportID:  1,
+	}
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+func (m *MemoryStore) GridCarInfo(ctx context.Context, filter CarFilter) ([]Car, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error querying cars: %w", err)
+	}
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	order := filter.OrderBy()
+	var cars []Car
+	for _, car := range m.filterCars(filter) {
+		key := filter.KeyOf(car)
+		if filter.After != nil && compareCarKeys(order, key, filter.After) <= 0 {
+			continue
+		}
+		if filter.Before != nil && compareCarKeys(order, key, filter.Before) >= 0 {
+			continue
+		}
+		cars = append(cars, car)
+	}
+	if filter.Before != nil {
+		slices.Reverse(cars)
+		cars = paginate(cars, filter.Limit, filter.Offset)
+		slices.Reverse(cars)
+		return cars, nil
+	}
+	return paginate(cars, filter.Limit, filter.Offset), nil
+}
+
+func (m *MemoryStore) CountCars(ctx context.Context, filter CarFilter) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("error counting cars: %w", err)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.filterCars(filter)), nil
+}
+
+// filterCars returns the cars matching a CarFilter in listing order, ignoring
+// its page bounds; callers must hold m.mu.
+func (m *MemoryStore) filterCars(filter CarFilter) []Car {
+	marks := ilikePatterns(containsPatterns(filter.Marks))
+	models := ilikePatterns(containsPatterns(filter.Models))
+	regNums := ilikePatterns(prefixPatterns(filter.RegNumPrefixes))
+	ownerNames := ilikePatterns(containsPatterns(filter.OwnerNames))
+	ownerSurnames := ilikePatterns(containsPatterns(filter.OwnerSurnames))
+
+	var cars []Car
+	for _, row := range m.cars {
+		if row.deletedAt != nil && !filter.IncludeDeleted {
+			continue
+		}
+		if len(filter.Years) > 0 && !slices.Contains(filter.Years, row.year) {
+			continue
+		}
+		if (filter.YearFrom != 0 && row.year < filter.YearFrom) || (filter.YearTo != 0 && row.year > filter.YearTo) {
+			continue
+		}
+		car := m.joinOwner(row)
+		if !matchAny(marks, car.Mark) || !matchAny(models, car.Model) || !matchAny(regNums, car.RegNum) ||
+			!matchAny(ownerNames, car.Owner.Name) || !matchAny(ownerSurnames, car.Owner.Surname) {
+			continue
+		}
+		cars = append(cars, car)
+	}
+
+	order := filter.OrderBy()
+	sort.Slice(cars, func(i, j int) bool {
+		return compareCarKeys(order, filter.KeyOf(cars[i]), filter.KeyOf(cars[j])) < 0
+	})
+	return cars
+}
+
+func (m *MemoryStore) GetCar(ctx context.Context, id int, includeDeleted bool) (*Car, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("Error querying GetCar: %w", err)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	row, ok := m.cars[id]
+	if !ok || (row.deletedAt != nil && !includeDeleted) {
+		return nil, sql.ErrNoRows
+	}
+	car := m.joinOwner(row)
+	return &car, nil
+}
+
+func (m *MemoryStore) DeleteCar(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error deleting car: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, ok := m.liveCar(id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	before := row.snapshot()
+	deletedAt := time.Now()
+	row.deletedAt = &deletedAt
+	return m.writeAudit(ctx, OpCarDelete, EntityCar, id, before, row.snapshot())
+}
+
+func (m *MemoryStore) RestoreCar(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error restoring car: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, ok := m.cars[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if row.deletedAt == nil {
+		return ErrCarNotDeleted
+	}
+	before := row.snapshot()
+	row.deletedAt = nil
+	if err := m.writeAudit(ctx, OpCarRestore, EntityCar, id, before, row.snapshot()); err != nil {
+		return err
+	}
+	// The owner may have been deleted along with the car; it comes back too.
+	if _, deleted := m.deletedOwners[row.ownerID]; deleted {
+		delete(m.deletedOwners, row.ownerID)
+		return m.writeAudit(ctx, OpOwnerRestore, EntityOwner, row.ownerID, nil, copyOwner(*m.owners[row.ownerID]))
+	}
+	return nil
+}
+
+func (m *MemoryStore) PurgeDeletedCars(ctx context.Context, deletedBefore time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("error purging cars: %w", err)
+	}
+	ctx = WithAuditMeta(ctx, ActorSystem, "")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	purged := 0
+	for _, row := range m.sortedCars() {
+		if row.deletedAt == nil || !row.deletedAt.Before(deletedBefore) {
+			continue
+		}
+		m.removeCar(row)
+		if err := m.writeAudit(ctx, OpCarPurge, EntityCar, row.id, row.snapshot(), nil); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, m.purgeOwners(ctx)
+}
+
+// purgeOwners removes the deleted owners no car or ownership period points
+// at any more; callers must hold m.mu.
+func (m *MemoryStore) purgeOwners(ctx context.Context) error {
+	referenced := make(map[int]bool)
+	for _, row := range m.cars {
+		referenced[row.ownerID] = true
+	}
+	for _, periods := range m.periods {
+		for _, p := range periods {
+			referenced[p.ownerID] = true
+		}
+	}
+	for _, owner := range m.sortedOwners() {
+		if _, deleted := m.deletedOwners[owner.ID]; !deleted || referenced[owner.ID] {
+			continue
+		}
+		delete(m.owners, owner.ID)
+		delete(m.deletedOwners, owner.ID)
+		if err := m.writeAudit(ctx, OpOwnerPurge, EntityOwner, owner.ID, copyOwner(*owner), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) UpdateCarInfo(ctx context.Context, id int, mark, model string, year, ownerId int) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error updating car info: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, ok := m.liveCar(id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if ownerId == 0 {
+		ownerId = row.ownerID
+	}
+	if _, ok := m.liveOwner(ownerId); !ok {
+		return fmt.Errorf("error updating car info: owner %d does not exist", ownerId)
+	}
+	before := row.snapshot()
+	if mark != "" {
+		row.mark = mark
+	}
+	if model != "" {
+		row.model = model
+	}
+	if year != 0 {
+		row.year = year
+	}
+	if row.ownerID != ownerId {
+		m.recordOwnershipChange(row, ownerId, time.Now(), ReasonUpdated)
+	}
+	return m.writeAudit(ctx, OpCarUpdate, EntityCar, id, before, row.snapshot())
+}
+
+func (m *MemoryStore) GetCarByRegNum(ctx context.Context, regNum string) (*Car, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error querying car by plate: %w", err)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.carsByPlate[regNum]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	car := m.joinOwner(m.cars[id])
+	return &car, nil
+}
+
+func (m *MemoryStore) SyncCar(ctx context.Context, id int, mark, model string, year, ownerId int, provenance Provenance) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error syncing car: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, ok := m.liveCar(id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if _, ok := m.liveOwner(ownerId); !ok {
+		return fmt.Errorf("error syncing car: owner %d does not exist", ownerId)
+	}
+	before := row.snapshot()
+	row.mark, row.model, row.year = mark, model, year
+	row.provenance = maps.Clone(provenance)
+	row.missingAt = nil
+	if row.ownerID != ownerId {
+		m.recordOwnershipChange(row, ownerId, time.Now(), ReasonSynced)
+	}
+	return m.writeAudit(ctx, OpCarSync, EntityCar, id, before, row.snapshot())
+}
+
+func (m *MemoryStore) SetUpstreamMissing(ctx context.Context, id int, missing bool) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error flagging car: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, ok := m.liveCar(id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if (row.missingAt != nil) == missing {
+		return nil
+	}
+	before := row.snapshot()
+	row.missingAt = nil
+	if missing {
+		missingAt := time.Now()
+		row.missingAt = &missingAt
+	}
+	return m.writeAudit(ctx, OpCarSync, EntityCar, id, before, row.snapshot())
+}
+
+func (m *MemoryStore) OwnerExists(ctx context.Context, ownerId int) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.liveOwner(ownerId)
+	return ok
+}
+
+func (m *MemoryStore) AddNewCar(ctx context.Context, regNum, mark, model string, year int, ownerId int64, provenance Provenance) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existingId, ok := m.carsByPlate[regNum]; ok {
+		if m.cars[existingId].deletedAt != nil {
+			return int64(existingId), ErrCarDeleted
+		}
+		return int64(existingId), ErrCarExists
+	}
+	if _, ok := m.liveOwner(int(ownerId)); !ok {
+		return 0, fmt.Errorf("error adding new car: owner %d does not exist", ownerId)
+	}
+
+	id := m.nextCarID
+	m.nextCarID++
+	row := &carRow{id: id, regNum: regNum, mark: mark, model: model, year: year, ownerID: int(ownerId), provenance: maps.Clone(provenance)}
+	m.cars[id] = row
+	m.carsByPlate[regNum] = id
+	m.openPeriod(id, int(ownerId), time.Now(), ReasonRegistered)
+	if err := m.writeAudit(ctx, OpCarCreate, EntityCar, id, nil, row.snapshot()); err != nil {
+		return 0, err
+	}
+	return int64(id), nil
+}
+
+func (m *MemoryStore) GetOrCreateOwner(ctx context.Context, owner Owner) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	owner = normalizeOwner(owner)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing := m.findOwner(owner); existing != nil {
+		if _, deleted := m.deletedOwners[existing.ID]; deleted {
+			return m.reviveOwner(ctx, existing)
+		}
+		return int64(existing.ID), nil
+	}
+	return m.insertOwner(ctx, owner)
+}
+
+func (m *MemoryStore) GridOwnerInfo(ctx context.Context, name, surname, patronymic string, limit, offset int) ([]Owner, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error querying owners: %w", err)
+	}
+	nameParam := likePattern(likeParam(name))
+	surnameParam := likePattern(likeParam(surname))
+	patronymicParam := likePattern(likeParam(patronymic))
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var owners []Owner
+	for _, owner := range m.sortedOwners() {
+		if _, deleted := m.deletedOwners[owner.ID]; deleted {
+			continue
+		}
+		if name != "" && !nameParam.MatchString(owner.Name) {
+			continue
+		}
+		if surname != "" && !surnameParam.MatchString(owner.Surname) {
+			continue
+		}
+		if patronymic != "" && (owner.Patronymic == nil || !patronymicParam.MatchString(*owner.Patronymic)) {
+			continue
+		}
+		owners = append(owners, copyOwner(*owner))
+	}
+	return paginate(owners, limit, offset), nil
+}
+
+func (m *MemoryStore) GetOwner(ctx context.Context, id int) (*OwnerDetails, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error querying owner: %w", err)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	owner, ok := m.liveOwner(id)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	details := OwnerDetails{Owner: copyOwner(*owner), Cars: []OwnedCar{}}
+	for _, row := range m.sortedCars() {
+		if row.ownerID == id && row.deletedAt == nil {
+			details.Cars = append(details.Cars, OwnedCar{ID: row.id, RegNum: row.regNum, Mark: row.mark, Model: row.model, Year: row.year})
+		}
+	}
+	return &details, nil
+}
+
+func (m *MemoryStore) AddOwner(ctx context.Context, owner Owner) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("error adding owner: %w", err)
+	}
+	owner = normalizeOwner(owner)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing := m.findOwner(owner); existing != nil {
+		if _, deleted := m.deletedOwners[existing.ID]; deleted {
+			return m.reviveOwner(ctx, existing)
+		}
+		return int64(existing.ID), ErrOwnerExists
+	}
+	return m.insertOwner(ctx, owner)
+}
+
+func (m *MemoryStore) UpdateOwner(ctx context.Context, id int, owner Owner) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error updating owner: %w", err)
+	}
+	owner = normalizeOwner(owner)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.liveOwner(id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	updated := copyOwner(*existing)
+	if owner.Name != "" {
+		updated.Name = owner.Name
+	}
+	if owner.Surname != "" {
+		updated.Surname = owner.Surname
+	}
+	if owner.Patronymic != nil {
+		updated.Patronymic = owner.Patronymic
+	}
+	if other := m.findOwner(updated); other != nil && other.ID != id {
+		return ErrOwnerExists
+	}
+	before := copyOwner(*existing)
+	*existing = updated
+	return m.writeAudit(ctx, OpOwnerUpdate, EntityOwner, id, before, copyOwner(updated))
+}
+
+func (m *MemoryStore) DeleteOwner(ctx context.Context, id int, cascade bool) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owner, ok := m.liveOwner(id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	referenced := make(map[int]bool)
+	var owned []*carRow
+	for _, row := range m.sortedCars() {
+		if row.ownerID != id {
+			continue
+		}
+		referenced[row.id] = true
+		if row.deletedAt == nil {
+			owned = append(owned, row)
+		}
+	}
+	if len(owned) > 0 && !cascade {
+		return ErrOwnerHasCars
+	}
+	for carID, periods := range m.periods {
+		if referenced[carID] {
+			continue
+		}
+		for _, p := range periods {
+			if p.ownerID == id {
+				return ErrOwnerHasHistory
+			}
+		}
+	}
+	for _, row := range owned {
+		before := row.snapshot()
+		deletedAt := time.Now()
+		row.deletedAt = &deletedAt
+		if err := m.writeAudit(ctx, OpCarDelete, EntityCar, row.id, before, row.snapshot()); err != nil {
+			return err
+		}
+	}
+	if len(referenced) > 0 {
+		m.deletedOwners[id] = time.Now()
+	} else {
+		delete(m.owners, id)
+	}
+	return m.writeAudit(ctx, OpOwnerDelete, EntityOwner, id, copyOwner(*owner), nil)
+}
+
+func (m *MemoryStore) TransferCar(ctx context.Context, id, ownerId int, at time.Time, reason string) error {
+	if at.After(time.Now()) {
+		return ErrTransferInFuture
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, ok := m.liveCar(id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if row.ownerID == ownerId {
+		return ErrSameOwner
+	}
+	if _, ok := m.liveOwner(ownerId); !ok {
+		return fmt.Errorf("error changing car owner: owner %d does not exist", ownerId)
+	}
+	if open := m.openPeriodOf(id); open != nil && at.Before(open.from) {
+		return ErrTransferBeforeCurrent
+	}
+	before := row.snapshot()
+	m.recordOwnershipChange(row, ownerId, at, reason)
+	return m.writeAudit(ctx, OpCarTransfer, EntityCar, id, before, row.snapshot())
+}
+
+func (m *MemoryStore) CarOwnershipHistory(ctx context.Context, id int) ([]OwnershipPeriod, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error querying ownership history: %w", err)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rows := append([]*periodRow(nil), m.periods[id]...)
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].from.Equal(rows[j].from) {
+			return rows[i].from.Before(rows[j].from)
+		}
+		return rows[i].id < rows[j].id
+	})
+
+	periods := []OwnershipPeriod{}
+	for _, p := range rows {
+		period := OwnershipPeriod{ID: p.id, CarID: p.carID, From: p.from, Reason: p.reason}
+		if owner, ok := m.owners[p.ownerID]; ok {
+			period.Owner = copyOwner(*owner)
+		}
+		if p.to != nil {
+			to := *p.to
+			period.To = &to
+		}
+		periods = append(periods, period)
+	}
+	return periods, nil
+}
+
+func (m *MemoryStore) OwnerCarsAt(ctx context.Context, ownerId int, at time.Time) ([]OwnedCar, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error querying owner cars: %w", err)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cars := []OwnedCar{}
+	for _, row := range m.sortedCars() {
+		if row.deletedAt != nil {
+			continue
+		}
+		for _, p := range m.periods[row.id] {
+			if p.ownerID == ownerId && !p.from.After(at) && (p.to == nil || p.to.After(at)) {
+				cars = append(cars, OwnedCar{ID: row.id, RegNum: row.regNum, Mark: row.mark, Model: row.model, Year: row.year})
+				break
+			}
+		}
+	}
+	return cars, nil
+}
+
+func (m *MemoryStore) GridAuditLog(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error querying audit log: %w", err)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := []AuditEntry{}
+	for i := len(m.audit) - 1; i >= 0; i-- {
+		e := m.audit[i]
+		if (filter.Entity != "" && e.Entity != filter.Entity) ||
+			(filter.EntityID != 0 && e.EntityID != filter.EntityID) ||
+			(filter.Operation != "" && e.Operation != filter.Operation) ||
+			(filter.Actor != "" && e.Actor != filter.Actor) ||
+			(filter.RequestID != "" && e.RequestID != filter.RequestID) ||
+			(filter.From != nil && e.OccurredAt.Before(*filter.From)) ||
+			(filter.To != nil && !e.OccurredAt.Before(*filter.To)) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if page := paginate(entries, filter.Limit, filter.Offset); page != nil {
+		return page, nil
+	}
+	return []AuditEntry{}, nil
+}
+
+// writeAudit appends an audit entry; callers must hold m.mu for writing.
+func (m *MemoryStore) writeAudit(ctx context.Context, operation, entity string, entityId int, before, after any) error {
+	beforeJSON, err := snapshotJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := snapshotJSON(after)
+	if err != nil {
+		return err
+	}
+	meta := auditMetaFrom(ctx)
+	m.audit = append(m.audit, AuditEntry{
+		ID:         int64(len(m.audit) + 1),
+		OccurredAt: time.Now(),
+		Actor:      meta.actor,
+		RequestID:  meta.requestID,
+		Operation:  operation,
+		Entity:     entity,
+		EntityID:   entityId,
+		Before:     beforeJSON,
+		After:      afterJSON,
+	})
+	return nil
+}
+
+func (m *MemoryStore) CreateImportJob(ctx context.Context, regNums, spelled []string) (*ImportJob, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error creating import job: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	meta := auditMetaFrom(ctx)
+	now := time.Now()
+	row := &importRow{
+		job: ImportJob{ID: m.nextImportID, State: ImportQueued, Actor: meta.actor, RequestID: meta.requestID,
+			Total: len(regNums), CreatedAt: now, UpdatedAt: now},
+		items: make([]ImportItem, len(regNums)),
+	}
+	m.nextImportID++
+	for i, regNum := range regNums {
+		row.items[i] = ImportItem{Position: i, RegNum: regNum, Spelled: spelled[i], Status: ItemPending}
+	}
+	m.imports[row.job.ID] = row
+	return row.view(), nil
+}
+
+func (m *MemoryStore) GetImportJob(ctx context.Context, id int) (*ImportJob, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error querying import job: %w", err)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	row, ok := m.imports[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return row.view(), nil
+}
+
+func (m *MemoryStore) GridImportItems(ctx context.Context, jobID int, filter ImportItemFilter) ([]ImportItem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error querying import items: %w", err)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	items := []ImportItem{}
+	row, ok := m.imports[jobID]
+	if !ok {
+		return items, nil
+	}
+	for _, item := range row.items[min(max(filter.From, 0), len(row.items)):] {
+		if len(filter.Statuses) == 0 || slices.Contains(filter.Statuses, item.Status) {
+			items = append(items, copyImportItem(item))
+		}
+	}
+	if items = paginate(items, filter.Limit, filter.Offset); items == nil {
+		items = []ImportItem{}
+	}
+	return items, nil
+}
+
+func (m *MemoryStore) SetImportItemResult(ctx context.Context, jobID int, item ImportItem) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error saving import item result: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, ok := m.imports[jobID]
+	if !ok || item.Position < 0 || item.Position >= len(row.items) {
+		return nil
+	}
+	stored := &row.items[item.Position]
+	stored.Status, stored.Error = item.Status, item.Error
+	stored.CarID = copyImportItem(item).CarID
+	return nil
+}
+
+func (m *MemoryStore) SetImportJobState(ctx context.Context, id int, from []string, state, errMsg string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error updating import job: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, ok := m.imports[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if !slices.Contains(from, row.job.State) {
+		return ErrImportJobState
+	}
+	now := time.Now()
+	row.job.State, row.job.Error, row.job.UpdatedAt, row.job.FinishedAt = state, errMsg, now, nil
+	if state == ImportCompleted || state == ImportCanceled || state == ImportFailed {
+		row.job.FinishedAt = &now
+	}
+	return nil
+}
+
+func (m *MemoryStore) UnfinishedImportJobs(ctx context.Context) ([]ImportJob, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error querying import jobs: %w", err)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var jobs []ImportJob
+	for _, row := range m.imports {
+		if row.job.State == ImportQueued || row.job.State == ImportRunning {
+			jobs = append(jobs, *row.view())
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs, nil
+}
+
+// view returns a copy of the job with its item counts; callers must hold m.mu.
+func (row *importRow) view() *ImportJob {
+	job := row.job
+	if job.FinishedAt != nil {
+		finishedAt := *job.FinishedAt
+		job.FinishedAt = &finishedAt
+	}
+	job.Counts = map[string]int{}
+	for _, item := range row.items {
+		job.Counts[item.Status]++
+	}
+	job.Processed = job.Total - job.Counts[ItemPending]
+	return &job
+}
+
+func copyImportItem(item ImportItem) ImportItem {
+	if item.CarID != nil {
+		carID := *item.CarID
+		item.CarID = &carID
+	}
+	return item
+}
+
+func (row *carRow) snapshot() carSnapshot {
+	snapshot := carSnapshot{ID: row.id, RegNum: row.regNum, Mark: row.mark, Model: row.model, Year: row.year, OwnerID: row.ownerID, Provenance: maps.Clone(row.provenance)}
+	if row.deletedAt != nil {
+		deletedAt := *row.deletedAt
+		snapshot.DeletedAt = &deletedAt
+	}
+	if row.missingAt != nil {
+		missingAt := *row.missingAt
+		snapshot.UpstreamMissingSince = &missingAt
+	}
+	return snapshot
+}
+
+// liveCar returns a car unless it is missing or soft-deleted; callers must
+// hold m.mu.
+func (m *MemoryStore) liveCar(id int) (*carRow, bool) {
+	row, ok := m.cars[id]
+	if !ok || row.deletedAt != nil {
+		return nil, false
+	}
+	return row, true
+}
+
+// removeCar drops a car and its history; callers must hold m.mu.
+func (m *MemoryStore) removeCar(row *carRow) {
+	delete(m.carsByPlate, row.regNum)
+	delete(m.cars, row.id)
+	delete(m.periods, row.id)
+}
+
+// openPeriodOf returns the current ownership period of a car; callers must hold m.mu.
+func (m *MemoryStore) openPeriodOf(carID int) *periodRow {
+	for _, p := range m.periods[carID] {
+		if p.to == nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// openPeriod starts a new ownership period; callers must hold m.mu.
+func (m *MemoryStore) openPeriod(carID, ownerID int, at time.Time, reason string) {
+	m.periods[carID] = append(m.periods[carID], &periodRow{
+		id: m.nextPeriodID, carID: carID, ownerID: ownerID, from: at, reason: reason,
+	})
+	m.nextPeriodID++
+}
+
+// recordOwnershipChange closes the current period and hands the car over;
+// callers must hold m.mu.
+func (m *MemoryStore) recordOwnershipChange(row *carRow, ownerID int, at time.Time, reason string) {
+	if open := m.openPeriodOf(row.id); open != nil {
+		closedAt := at
+		open.to = &closedAt
+	}
+	row.ownerID = ownerID
+	m.openPeriod(row.id, ownerID, at, reason)
+}
+
+// liveOwner returns an owner unless it is missing or deleted; callers must
+// hold m.mu.
+func (m *MemoryStore) liveOwner(id int) (*Owner, bool) {
+	owner, ok := m.owners[id]
+	if !ok {
+		return nil, false
+	}
+	if _, deleted := m.deletedOwners[id]; deleted {
+		return nil, false
+	}
+	return owner, true
+}
+
+// reviveOwner clears the deletion mark of an owner added again, auditing it
+// as created like the upsert of the Postgres store; callers must hold m.mu.
+func (m *MemoryStore) reviveOwner(ctx context.Context, owner *Owner) (int64, error) {
+	delete(m.deletedOwners, owner.ID)
+	if err := m.writeAudit(ctx, OpOwnerCreate, EntityOwner, owner.ID, nil, copyOwner(*owner)); err != nil {
+		return 0, err
+	}
+	return int64(owner.ID), nil
+}
+
+// findOwner looks an owner up by identity, deleted owners included; callers
+// must hold m.mu.
+func (m *MemoryStore) findOwner(owner Owner) *Owner {
+	for _, existing := range m.sortedOwners() {
+		if existing.Name == owner.Name && existing.Surname == owner.Surname &&
+			patronymicKey(existing.Patronymic) == patronymicKey(owner.Patronymic) {
+			return existing
+		}
+	}
+	return nil
+}
+
+// insertOwner stores a normalized owner under a fresh id; callers must hold m.mu.
+func (m *MemoryStore) insertOwner(ctx context.Context, owner Owner) (int64, error) {
+	id := m.nextOwnerID
+	m.nextOwnerID++
+	owner = copyOwner(owner)
+	owner.ID = id
+	m.owners[id] = &owner
+	if err := m.writeAudit(ctx, OpOwnerCreate, EntityOwner, id, nil, owner); err != nil {
+		return 0, err
+	}
+	return int64(id), nil
+}
+
+func patronymicKey(patronymic *string) string {
+	if patronymic == nil {
+		return ""
+	}
+	return *patronymic
+}
+
+// sortedCars returns rows in primary key order; callers must hold m.mu.
+func (m *MemoryStore) sortedCars() []*carRow {
+	rows := make([]*carRow, 0, len(m.cars))
+	for _, row := range m.cars {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].id < rows[j].id })
+	return rows
+}
+
+// sortedOwners returns owners in primary key order; callers must hold m.mu.
+func (m *MemoryStore) sortedOwners() []*Owner {
+	owners := make([]*Owner, 0, len(m.owners))
+	for _, owner := range m.owners {
+		owners = append(owners, owner)
+	}
+	sort.Slice(owners, func(i, j int) bool { return owners[i].ID < owners[j].ID })
+	return owners
+}
+
+// joinOwner builds the API view of a row; callers must hold m.mu.
+func (m *MemoryStore) joinOwner(row *carRow) Car {
+	car := Car{ID: row.id, RegNum: row.regNum, Mark: row.mark, Model: row.model, Year: row.year, Provenance: maps.Clone(row.provenance)}
+	if row.deletedAt != nil {
+		deletedAt := *row.deletedAt
+		car.DeletedAt = &deletedAt
+	}
+	if row.missingAt != nil {
+		missingAt := *row.missingAt
+		car.UpstreamMissingSince = &missingAt
+	}
+	if owner, ok := m.owners[row.ownerID]; ok {
+		car.Owner = copyOwner(*owner)
+	}
+	return car
+}
+
+func copyOwner(owner Owner) Owner {
+	if owner.Patronymic != nil {
+		patronymic := *owner.Patronymic
+		owner.Patronymic = &patronymic
+	}
+	return owner
+}
+
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+// likePattern compiles a SQL LIKE pattern into an anchored regular expression.
+func likePattern(pattern string) *regexp.Regexp {
+	return compileLike(pattern, "(?s)")
+}
+
+func ilikePatterns(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		compiled[i] = compileLike(pattern, "(?is)")
+	}
+	return compiled
+}
+
+// matchAny reports whether the value matches one of the patterns; no patterns
+// match everything.
+func matchAny(patterns []*regexp.Regexp, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func compileLike(pattern, flags string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	escaped := false
+	for _, r := range pattern {
+		if escaped {
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+			continue
+		}
+		switch r {
+		case '\\':
+			escaped = true
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(flags + b.String())
+}
+
+func (m *MemoryStore) Search(ctx context.Context, query string, limit int) ([]SearchHit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error searching: %w", err)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var hits []SearchHit
+	for _, row := range m.cars {
+		if row.deletedAt != nil {
+			continue
+		}
+		car := m.joinOwner(row)
+		if score := wordSimilarity(query, carSearchDocument(car)); score >= searchThreshold {
+			hits = append(hits, SearchHit{Type: HitCar, Score: score, Car: &car})
+		}
+	}
+	for _, owner := range m.owners {
+		if _, deleted := m.deletedOwners[owner.ID]; deleted {
+			continue
+		}
+		owner := copyOwner(*owner)
+		if score := wordSimilarity(query, ownerSearchDocument(owner)); score >= searchThreshold {
+			hits = append(hits, SearchHit{Type: HitOwner, Score: score, Owner: &owner})
+		}
+	}
+	return rankHits(hits, query, limit), nil
+}
+
+func (m *MemoryStore) BatchDeleteCars(ctx context.Context, ids []int, opts BatchOptions) ([]BatchItem, error) {
+	return m.batch(ctx, ids, opts, BatchDeleted, OpCarDelete, func(row *carRow) error {
+		deletedAt := time.Now()
+		row.deletedAt = &deletedAt
+		return nil
+	})
+}
+
+func (m *MemoryStore) BatchUpdateCars(ctx context.Context, ids []int, patch CarPatch, opts BatchOptions) ([]BatchItem, error) {
+	return m.batch(ctx, ids, opts, BatchUpdated, OpCarUpdate, func(row *carRow) error {
+		if patch.OwnerID != 0 {
+			if _, ok := m.liveOwner(patch.OwnerID); !ok {
+				return fmt.Errorf("error updating car info: owner %d does not exist", patch.OwnerID)
+			}
+			row.ownerID = patch.OwnerID
+		}
+		if patch.Mark != "" {
+			row.mark = patch.Mark
+		}
+		if patch.Model != "" {
+			row.model = patch.Model
+		}
+		if patch.Year != 0 {
+			row.year = patch.Year
+		}
+		return nil
+	})
+}
+
+// batch applies change to a copy of every car first, so an atomic batch or a
+// dry run can be abandoned without touching the stored rows.
+func (m *MemoryStore) batch(ctx context.Context, ids []int, opts BatchOptions, status, operation string, change func(row *carRow) error) ([]BatchItem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error running batch: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	items := make([]BatchItem, len(ids))
+	changed := make([]*carRow, len(ids))
+	failed := false
+	for i, id := range ids {
+		if failed && opts.Atomic {
+			// Postgres aborts the transaction at the first failure; the
+			// remaining cars are rolled back without being looked at.
+			items[i] = BatchItem{ID: id, Status: BatchRolledBack}
+			continue
+		}
+		row, ok := m.liveCar(id)
+		if !ok {
+			items[i] = batchItem(id, status, nil, nil, sql.ErrNoRows)
+			failed = true
+			continue
+		}
+		next := *row
+		if err := change(&next); err != nil {
+			items[i] = batchItem(id, status, nil, nil, err)
+			failed = true
+			continue
+		}
+		before, after := row.snapshot(), next.snapshot()
+		items[i] = batchItem(id, status, &before, &after, nil)
+		changed[i] = &next
+	}
+
+	if failed && opts.Atomic {
+		for i := range items {
+			if items[i].Status == status {
+				items[i].Status = BatchRolledBack
+			}
+		}
+		return items, nil
+	}
+	if opts.DryRun {
+		return items, nil
+	}
+
+	for i, next := range changed {
+		if next == nil {
+			continue
+		}
+		row := m.cars[ids[i]]
+		before := row.snapshot()
+		ownerID := next.ownerID
+		next.ownerID = row.ownerID
+		*row = *next
+		if row.ownerID != ownerID {
+			m.recordOwnershipChange(row, ownerID, time.Now(), ReasonUpdated)
+		}
+		if err := m.writeAudit(ctx, operation, EntityCar, row.id, before, row.snapshot()); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,296 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationLockKey identifies the advisory lock held while migrations run, so
+// several instances booting at once apply the schema only once.
+const migrationLockKey int64 = 7_344_129_801
+
+const (
+	MigrateModeUp     = "up"
+	MigrateModeVerify = "verify"
+)
+
+var (
+	ErrDirtySchema       = errors.New("database schema is dirty, a previous migration failed half-way")
+	ErrSchemaAhead       = errors.New("database schema is newer than the migrations known to this binary")
+	ErrPendingMigrations = errors.New("database schema has pending migrations")
+	ErrNoMigration       = errors.New("no migration to apply")
+)
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+type MigrationStatus struct {
+	Current int  `json:"current"`
+	Latest  int  `json:"latest"`
+	Dirty   bool `json:"dirty"`
+}
+
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationsFS)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file name: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		body, err := fs.ReadFile(fsys, "migrations/"+entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func (m *Migrator) latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status reports the applied version of the schema compared to the newest
+// migration embedded in the binary.
+func (m *Migrator) Status(ctx context.Context) (MigrationStatus, error) {
+	status := MigrationStatus{Latest: m.latest()}
+	if err := m.ensureTable(ctx, m.db); err != nil {
+		return status, err
+	}
+	current, dirty, err := m.version(ctx, m.db)
+	if err != nil {
+		return status, err
+	}
+	status.Current, status.Dirty = current, dirty
+	return status, nil
+}
+
+// Verify fails if the schema is dirty, ahead of the binary or has pending
+// migrations.
+func (m *Migrator) Verify(ctx context.Context) error {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	return status.Err()
+}
+
+// Err tells why the binary cannot run against the schema: it is dirty,
+// ahead of the binary or has pending migrations.
+func (s MigrationStatus) Err() error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	if s.Current < s.Latest {
+		return fmt.Errorf("%w: at version %d, binary expects %d", ErrPendingMigrations, s.Current, s.Latest)
+	}
+	return nil
+}
+
+func (s MigrationStatus) check() error {
+	if s.Dirty {
+		return fmt.Errorf("%w: version %d", ErrDirtySchema, s.Current)
+	}
+	if s.Current > s.Latest {
+		return fmt.Errorf("%w: at version %d, binary knows up to %d", ErrSchemaAhead, s.Current, s.Latest)
+	}
+	return nil
+}
+
+// Up applies every pending migration and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		current, dirty, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := (MigrationStatus{Current: current, Latest: m.latest(), Dirty: dirty}).check(); err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if migration.Version <= current {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration.Version, migration.Up, true); err != nil {
+				return fmt.Errorf("error applying migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		current, dirty, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := (MigrationStatus{Current: current, Latest: m.latest(), Dirty: dirty}).check(); err != nil {
+			return err
+		}
+		if current == 0 {
+			return ErrNoMigration
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version != current {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s cannot be rolled back", migration.Version, migration.Name)
+			}
+			if err := m.apply(ctx, conn, migration.Version, migration.Down, false); err != nil {
+				return fmt.Errorf("error rolling back migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			return nil
+		}
+		return fmt.Errorf("applied migration %d is unknown to this binary", current)
+	})
+}
+
+// apply marks the version dirty, then runs the script and clears the mark in a
+// single transaction. A failure in between leaves the dirty mark behind for an
+// operator to inspect.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, version int, script string, up bool) error {
+	if _, err := conn.ExecContext(ctx, MarkMigrationDirty, version); err != nil {
+		return fmt.Errorf("error marking migration dirty: %w", err)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, MarkMigrationApplied, version)
+	} else {
+		_, err = tx.ExecContext(ctx, DeleteMigration, version)
+	}
+	if err != nil {
+		return fmt.Errorf("error recording migration: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory
+// lock; the lock is session scoped, so it has to stay on one connection.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, AcquireMigrationLock, migrationLockKey); err != nil {
+		return fmt.Errorf("error acquiring migration lock: %w", err)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), ReleaseMigrationLock, migrationLockKey)
+	}()
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+type execQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, q execQueryer) error {
+	if _, err := q.ExecContext(ctx, CreateSchemaMigrations); err != nil {
+		return fmt.Errorf("error creating schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) version(ctx context.Context, q execQueryer) (int, bool, error) {
+	var version int
+	var dirty bool
+	err := q.QueryRowContext(ctx, CurrentMigration).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, fmt.Errorf("error reading schema version: %w", err)
+	}
+	return version, dirty, nil
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+)
+
+// openTestDB connects to the database of TEST_POSTGRES_DSN, which the tests
+// may wipe, and migrates it, skipping the test when it is not set.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("opening test database: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	migrator, err := NewMigrator(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrating test database: %v", err)
+	}
+	return conn
+}
+
+func TestMigrationsLoad(t *testing.T) {
+	migrations, err := loadMigrations(migrationsFS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d_%s follows version %d", m.Version, m.Name, i)
+		}
+		if m.Down == "" {
+			t.Errorf("migration %d_%s has no down script", m.Version, m.Name)
+		}
+	}
+}
+
+func TestNormalizeRegNumsMigration(t *testing.T) {
+	conn := openTestDB(t)
+	ctx := context.Background()
+	migrator, err := NewMigrator(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := conn.ExecContext(ctx, query, args...); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	addLegacyCars := func(plates ...string) {
+		t.Helper()
+		exec(`TRUNCATE peoples, cars, ownership_periods, audit_log, import_jobs, import_items RESTART IDENTITY CASCADE`)
+		exec(`INSERT INTO peoples (name, surname) VALUES ('Ivan', 'Petrov')`)
+		for _, plate := range plates {
+			exec(`INSERT INTO cars (reg_num, mark, model, year, owner_id) VALUES ($1, 'Lada', 'Vesta', 2020, 1)`, plate)
+		}
+	}
+	// rollBack returns to the schema before plates were normalized.
+	rollBack := func() {
+		t.Helper()
+		for {
+			status, err := migrator.Status(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Current <= 10 {
+				return
+			}
+			if err := migrator.Down(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	rollBack()
+	addLegacyCars("а 123-вс 150", "x999xx150", "B222BB150")
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrating legacy plates: %v", err)
+	}
+	rows, err := conn.QueryContext(ctx, `SELECT reg_num FROM cars ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for rows.Next() {
+		var regNum string
+		if err := rows.Scan(&regNum); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, regNum)
+	}
+	_ = rows.Close()
+	if want := "A123BC150,X999XX150,B222BB150"; strings.Join(got, ",") != want {
+		t.Errorf("plates migrated to %v, want %s", got, want)
+	}
+	if _, err := conn.ExecContext(ctx, `INSERT INTO cars (reg_num, mark, model, year, owner_id) VALUES ('b222bb150', 'Lada', 'Vesta', 2020, 1)`); !isUniqueViolation(err) {
+		t.Errorf("a differently spelled plate was stored next to its twin: %v", err)
+	}
+
+	rollBack()
+	addLegacyCars("А123ВС150", "a123bc150")
+	_, err = migrator.Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "collide") || !strings.Contains(err.Error(), "A123BC150") {
+		t.Fatalf("migrating colliding plates: %v, want a collision report", err)
+	}
+	exec(`DELETE FROM cars WHERE id = 2`)
+	exec(`DELETE FROM schema_migrations WHERE version = 11`)
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrating after resolving the collision: %v", err)
+	}
+}
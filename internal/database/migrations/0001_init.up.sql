@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS peoples (
+    id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    surname VARCHAR(255) NOT NULL,
+    patronymic VARCHAR(255)
+);
+
+CREATE TABLE IF NOT EXISTS cars (
+    id INT GENERATED ALWAYS AS IDENTITY,
+    reg_num VARCHAR(255) NOT NULL UNIQUE,
+    mark VARCHAR(255) NOT NULL,
+    model VARCHAR(255) NOT NULL,
+    year INT,
+    owner_id INT NOT NULL,
+    FOREIGN KEY (owner_id) REFERENCES peoples(id)
+);
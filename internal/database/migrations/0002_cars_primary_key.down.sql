@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS cars_owner_id_idx;
+ALTER TABLE cars DROP CONSTRAINT IF EXISTS cars_pkey;
@@ -0,0 +1,11 @@
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'cars'::regclass AND contype = 'p'
+    ) THEN
+        ALTER TABLE cars ADD PRIMARY KEY (id);
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS cars_owner_id_idx ON cars (owner_id);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS peoples_identity_key;
@@ -0,0 +1,21 @@
+UPDATE peoples SET patronymic = NULL WHERE patronymic = '';
+
+-- Owners used to be matched on name and surname only, so duplicates may
+-- already exist. Repoint their cars to the oldest row before deduplicating.
+WITH ranked AS (
+    SELECT id, MIN(id) OVER (PARTITION BY name, surname, COALESCE(patronymic, '')) AS keep_id
+    FROM peoples
+)
+UPDATE cars
+SET owner_id = ranked.keep_id
+FROM ranked
+WHERE cars.owner_id = ranked.id AND ranked.id <> ranked.keep_id;
+
+DELETE FROM peoples p
+USING peoples keep
+WHERE keep.name = p.name
+  AND keep.surname = p.surname
+  AND COALESCE(keep.patronymic, '') = COALESCE(p.patronymic, '')
+  AND keep.id < p.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS peoples_identity_key ON peoples (name, surname, (COALESCE(patronymic, '')));
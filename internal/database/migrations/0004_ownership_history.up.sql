@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS ownership_periods (
+    id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    car_id INT NOT NULL REFERENCES cars(id) ON DELETE CASCADE,
+    owner_id INT NOT NULL REFERENCES peoples(id),
+    owned_from TIMESTAMPTZ NOT NULL,
+    owned_to TIMESTAMPTZ,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    CHECK (owned_to IS NULL OR owned_to >= owned_from)
+);
+
+CREATE INDEX IF NOT EXISTS ownership_periods_car_idx ON ownership_periods (car_id, owned_from);
+CREATE INDEX IF NOT EXISTS ownership_periods_owner_idx ON ownership_periods (owner_id, owned_from);
+CREATE UNIQUE INDEX IF NOT EXISTS ownership_periods_open_idx ON ownership_periods (car_id) WHERE owned_to IS NULL;
+
+-- Earlier owners were never recorded, so history starts with the current one.
+INSERT INTO ownership_periods (car_id, owner_id, owned_from, reason)
+SELECT c.id, c.owner_id, now(), 'initial'
+FROM cars c
+WHERE NOT EXISTS (SELECT 1 FROM ownership_periods p WHERE p.car_id = c.id);
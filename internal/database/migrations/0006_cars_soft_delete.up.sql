@@ -0,0 +1,3 @@
+ALTER TABLE cars ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS cars_deleted_at_idx ON cars (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS peoples_search_trgm_idx;
+DROP INDEX IF EXISTS cars_search_trgm_idx;
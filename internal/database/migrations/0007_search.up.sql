@@ -0,0 +1,8 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS cars_search_trgm_idx ON cars
+    USING gin ((reg_num || ' ' || mark || ' ' || model) gin_trgm_ops)
+    WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS peoples_search_trgm_idx ON peoples
+    USING gin ((name || ' ' || surname || ' ' || COALESCE(patronymic, '')) gin_trgm_ops);
@@ -0,0 +1 @@
+ALTER TABLE cars ADD COLUMN IF NOT EXISTS provenance JSONB;
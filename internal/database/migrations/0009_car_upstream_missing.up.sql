@@ -0,0 +1 @@
+ALTER TABLE cars ADD COLUMN IF NOT EXISTS upstream_missing_since TIMESTAMPTZ;
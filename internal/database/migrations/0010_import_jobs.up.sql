@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    state VARCHAR(16) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    request_id VARCHAR(255) NOT NULL DEFAULT '',
+    total INT NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS import_jobs_unfinished_idx ON import_jobs (id) WHERE state IN ('queued', 'running');
+
+CREATE TABLE IF NOT EXISTS import_items (
+    job_id INT NOT NULL REFERENCES import_jobs(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    reg_num VARCHAR(255) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    car_id INT,
+    error TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (job_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS import_items_status_idx ON import_items (job_id, status, position);
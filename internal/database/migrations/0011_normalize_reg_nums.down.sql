@@ -0,0 +1,3 @@
+-- The original spellings are gone; the plates stay normalized.
+DROP INDEX IF EXISTS cars_reg_num_normalized_key;
+DROP FUNCTION IF EXISTS normalize_reg_num(TEXT);
@@ -0,0 +1,41 @@
+-- normalize_reg_num mirrors plates.Normalize: whitespace and dashes dropped,
+-- Cyrillic look-alike letters replaced with Latin ones, upper case. Both
+-- cases of the look-alikes are listed so the result does not depend on the
+-- locale of the database.
+CREATE OR REPLACE FUNCTION normalize_reg_num(reg_num TEXT) RETURNS TEXT
+    LANGUAGE SQL IMMUTABLE STRICT PARALLEL SAFE
+AS $$
+    SELECT upper(translate(
+        regexp_replace(reg_num, '[[:space:]-]+', '', 'g'),
+        'АВЕКМНОРСТУХавекмнорстух',
+        'ABEKMHOPCTYXABEKMHOPCTYX'
+    ))
+$$;
+
+-- Plates stored before normalization may spell one car several ways. Such
+-- rows cannot be merged automatically, so stop and list them for an operator
+-- to resolve before running the migration again.
+DO $$
+DECLARE
+    report TEXT;
+BEGIN
+    SELECT string_agg(format('%s: %s', normalized, cars), '; ' ORDER BY normalized)
+    INTO report
+    FROM (
+        SELECT normalize_reg_num(reg_num) AS normalized,
+               string_agg(format('car %s (%L)', id, reg_num), ', ' ORDER BY id) AS cars
+        FROM cars
+        GROUP BY normalize_reg_num(reg_num)
+        HAVING count(*) > 1
+    ) collisions;
+
+    IF report IS NOT NULL THEN
+        RAISE EXCEPTION 'registration numbers collide once normalized: %', report
+            USING HINT = 'Delete or renumber all but one car of every group, then clear the dirty mark of migration 11.';
+    END IF;
+END
+$$;
+
+UPDATE cars SET reg_num = normalize_reg_num(reg_num) WHERE reg_num <> normalize_reg_num(reg_num);
+
+CREATE UNIQUE INDEX IF NOT EXISTS cars_reg_num_normalized_key ON cars (normalize_reg_num(reg_num));
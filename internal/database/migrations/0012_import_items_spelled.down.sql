@@ -0,0 +1 @@
+ALTER TABLE import_items DROP COLUMN IF EXISTS spelled;
@@ -0,0 +1,3 @@
+-- The plate as submitted, asked of the upstream when it does not know the
+-- normalized spelling. Items queued before keep an empty spelling.
+ALTER TABLE import_items ADD COLUMN IF NOT EXISTS spelled VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE peoples DROP COLUMN IF EXISTS deleted_at;
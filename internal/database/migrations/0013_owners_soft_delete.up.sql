@@ -0,0 +1,3 @@
+-- Soft-deleted cars keep pointing at their owner, so an owner deleted while
+-- such cars remain is only marked and removed by the purge job with them.
+ALTER TABLE peoples ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
@@ -0,0 +1,308 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"strings"
+)
+
+type OwnedCar struct {
+	ID     int    `json:"id"`
+	RegNum string `json:"regNum"`
+	Mark   string `json:"mark"`
+	Model  string `json:"model"`
+	Year   int    `json:"year"`
+}
+
+type OwnerDetails struct {
+	Owner
+	Cars []OwnedCar `json:"cars"`
+}
+
+var (
+	ErrOwnerHasCars    = errors.New("owner still has cars")
+	ErrOwnerExists     = errors.New("an owner with that name, surname and patronymic is already in the database")
+	ErrOwnerHasHistory = errors.New("owner appears in the ownership history of other cars")
+)
+
+func (db *Database) GridOwnerInfo(ctx context.Context, name, surname, patronymic string, limit, offset int) ([]Owner, error) {
+	rows, err := db.QueryContext(ctx, GridOwnerInfo, likeParam(name), likeParam(surname), likeParam(patronymic), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error querying owners: %w", err)
+	}
+	defer rows.Close()
+
+	var owners []Owner
+	for rows.Next() {
+		var owner Owner
+		if err := rows.Scan(&owner.ID, &owner.Name, &owner.Surname, &owner.Patronymic); err != nil {
+			return nil, fmt.Errorf("error scanning owner: %w", err)
+		}
+		owners = append(owners, owner)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return owners, nil
+}
+
+func (db *Database) GetOwner(ctx context.Context, id int) (*OwnerDetails, error) {
+	var details OwnerDetails
+	err := db.QueryRowContext(ctx, GetOwner, id).Scan(&details.ID, &details.Name, &details.Surname, &details.Patronymic)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error querying owner: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, OwnerCars, id)
+	if err != nil {
+		return nil, fmt.Errorf("error querying owner cars: %w", err)
+	}
+	defer rows.Close()
+
+	details.Cars = []OwnedCar{}
+	for rows.Next() {
+		var car OwnedCar
+		if err := rows.Scan(&car.ID, &car.RegNum, &car.Mark, &car.Model, &car.Year); err != nil {
+			return nil, fmt.Errorf("error scanning owner car: %w", err)
+		}
+		details.Cars = append(details.Cars, car)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return &details, nil
+}
+
+// AddOwner inserts a new owner. If the identity is taken it returns the id of
+// the existing owner together with ErrOwnerExists.
+func (db *Database) AddOwner(ctx context.Context, owner Owner) (int64, error) {
+	owner = normalizeOwner(owner)
+
+	id, inserted, err := db.insertOwner(ctx, owner)
+	if err != nil {
+		return 0, err
+	} else if inserted {
+		return id, nil
+	}
+
+	if err = db.QueryRowContext(ctx, СheckPerson, owner.Name, owner.Surname, owner.Patronymic).Scan(&id); err != nil {
+		return 0, fmt.Errorf("error checking owner existence: %w", err)
+	}
+	return id, ErrOwnerExists
+}
+
+// insertOwner adds a normalized owner unless its identity is already taken,
+// reporting whether a row was created.
+func (db *Database) insertOwner(ctx context.Context, owner Owner) (int64, bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("error starting transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, AddPerson, owner.Name, owner.Surname, owner.Patronymic).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, fmt.Errorf("error adding owner: %w", err)
+	}
+
+	owner.ID = int(id)
+	if err = writeAudit(ctx, tx, OpOwnerCreate, EntityOwner, owner.ID, nil, owner); err != nil {
+		return 0, false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, false, fmt.Errorf("error committing transaction: %w", err)
+	}
+	committed = true
+	return id, true, nil
+}
+
+func (db *Database) UpdateOwner(ctx context.Context, id int, owner Owner) error {
+	owner = normalizeOwner(owner)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	before, err := lockOwner(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	var after Owner
+	err = tx.QueryRowContext(ctx, UpdateOwner, id, owner.Name, owner.Surname, owner.Patronymic).
+		Scan(&after.ID, &after.Name, &after.Surname, &after.Patronymic)
+	if isUniqueViolation(err) {
+		return ErrOwnerExists
+	} else if err != nil {
+		return fmt.Errorf("error updating owner: %w", err)
+	}
+
+	if err = writeAudit(ctx, tx, OpOwnerUpdate, EntityOwner, id, before, after); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+// DeleteOwner removes an owner. Owners that still have cars are rejected with
+// ErrOwnerHasCars unless cascade is set, in which case their cars are
+// soft-deleted too. Former owners of other cars are kept for the history:
+// ErrOwnerHasHistory. An owner whose soft-deleted cars remain is only marked
+// deleted; the purge job removes it once the cars are gone.
+func (db *Database) DeleteOwner(ctx context.Context, id int, cascade bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	before, err := lockOwner(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	cars, err := lockOwnerCars(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if len(cars) > 0 && !cascade {
+		return ErrOwnerHasCars
+	}
+
+	var hasHistory bool
+	if err = tx.QueryRowContext(ctx, OwnerHasHistory, id).Scan(&hasHistory); err != nil {
+		return fmt.Errorf("error checking owner history: %w", err)
+	} else if hasHistory {
+		return ErrOwnerHasHistory
+	}
+
+	for _, car := range cars {
+		if _, _, err = deleteCarTx(ctx, tx, car.ID); err != nil {
+			return err
+		}
+	}
+
+	var hasCars bool
+	if err = tx.QueryRowContext(ctx, OwnerHasCars, id).Scan(&hasCars); err != nil {
+		return fmt.Errorf("error checking owner cars: %w", err)
+	}
+	if hasCars {
+		_, err = tx.ExecContext(ctx, MarkOwnerDeleted, id)
+	} else {
+		_, err = tx.ExecContext(ctx, DeleteOwner, id)
+	}
+	if isForeignKeyViolation(err) {
+		return ErrOwnerHasHistory
+	} else if err != nil {
+		return fmt.Errorf("error deleting owner: %w", err)
+	}
+
+	if err = writeAudit(ctx, tx, OpOwnerDelete, EntityOwner, id, before, nil); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+// lockOwner reads an owner row for update, returning sql.ErrNoRows if it is
+// missing.
+func lockOwner(ctx context.Context, tx *sql.Tx, id int) (*Owner, error) {
+	var owner Owner
+	err := tx.QueryRowContext(ctx, GetOwnerForUpdate, id).Scan(&owner.ID, &owner.Name, &owner.Surname, &owner.Patronymic)
+	if err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("error locking owner: %w", err)
+	}
+	return &owner, nil
+}
+
+// lockOwnerCars locks the cars an owner still has; soft-deleted cars are left
+// out.
+func lockOwnerCars(ctx context.Context, tx *sql.Tx, ownerId int) ([]carSnapshot, error) {
+	rows, err := tx.QueryContext(ctx, GetOwnerCarsForUpdate, ownerId)
+	if err != nil {
+		return nil, fmt.Errorf("error locking owner cars: %w", err)
+	}
+	defer rows.Close()
+
+	var cars []carSnapshot
+	for rows.Next() {
+		var car carSnapshot
+		if err := rows.Scan(&car.ID, &car.RegNum, &car.Mark, &car.Model, &car.Year, &car.OwnerID, &car.DeletedAt); err != nil {
+			return nil, fmt.Errorf("error scanning owner car: %w", err)
+		}
+		cars = append(cars, car)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return cars, nil
+}
+
+// normalizeOwner trims the identity fields and stores an empty patronymic as
+// NULL, matching how the unique index compares them.
+func normalizeOwner(owner Owner) Owner {
+	owner.Name = strings.TrimSpace(owner.Name)
+	owner.Surname = strings.TrimSpace(owner.Surname)
+	if owner.Patronymic != nil {
+		patronymic := strings.TrimSpace(*owner.Patronymic)
+		owner.Patronymic = &patronymic
+		if patronymic == "" {
+			owner.Patronymic = nil
+		}
+	}
+	return owner
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+func likeParam(value string) string {
+	if value == "" {
+		return "%"
+	}
+	return "%" + value + "%"
+}
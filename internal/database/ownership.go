@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	ReasonInitial    = "initial"
+	ReasonRegistered = "registered"
+	ReasonUpdated    = "updated"
+	ReasonTransfer   = "transfer"
+	ReasonSynced     = "synced"
+)
+
+type OwnershipPeriod struct {
+	ID     int        `json:"id"`
+	CarID  int        `json:"carId"`
+	Owner  Owner      `json:"owner"`
+	From   time.Time  `json:"from"`
+	To     *time.Time `json:"to"`
+	Reason string     `json:"reason"`
+}
+
+var (
+	ErrSameOwner             = errors.New("car already belongs to that owner")
+	ErrTransferBeforeCurrent = errors.New("transfer date is before the start of the current ownership")
+	ErrTransferInFuture      = errors.New("transfer date is in the future")
+)
+
+// TransferCar hands a car over to another owner at the given moment, closing
+// the current ownership period and opening a new one in one transaction. The
+// moment cannot lie in the future: ErrTransferInFuture.
+func (db *Database) TransferCar(ctx context.Context, id, ownerId int, at time.Time, reason string) error {
+	if at.After(time.Now()) {
+		return ErrTransferInFuture
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	before, err := lockCar(ctx, tx, id, false)
+	if err != nil {
+		return err
+	}
+	if before.OwnerID == ownerId {
+		return ErrSameOwner
+	}
+
+	var ownedFrom time.Time
+	err = tx.QueryRowContext(ctx, GetOpenOwnershipStart, id).Scan(&ownedFrom)
+	if err == nil && at.Before(ownedFrom) {
+		return ErrTransferBeforeCurrent
+	} else if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error reading current ownership: %w", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, SetCarOwner, id, ownerId); err != nil {
+		return fmt.Errorf("error changing car owner: %w", err)
+	}
+	if err = recordOwnershipChange(ctx, tx, id, ownerId, at, reason); err != nil {
+		return err
+	}
+
+	after := *before
+	after.OwnerID = ownerId
+	if err = writeAudit(ctx, tx, OpCarTransfer, EntityCar, id, before, after); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+func recordOwnershipChange(ctx context.Context, tx *sql.Tx, carId, ownerId int, at time.Time, reason string) error {
+	if _, err := tx.ExecContext(ctx, CloseOwnershipPeriod, carId, at); err != nil {
+		return fmt.Errorf("error closing ownership period: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, OpenOwnershipPeriod, carId, ownerId, at, reason); err != nil {
+		return fmt.Errorf("error opening ownership period: %w", err)
+	}
+	return nil
+}
+
+func (db *Database) CarOwnershipHistory(ctx context.Context, id int) ([]OwnershipPeriod, error) {
+	rows, err := db.QueryContext(ctx, CarOwnershipHistory, id)
+	if err != nil {
+		return nil, fmt.Errorf("error querying ownership history: %w", err)
+	}
+	defer rows.Close()
+
+	periods := []OwnershipPeriod{}
+	for rows.Next() {
+		var p OwnershipPeriod
+		if err := rows.Scan(&p.ID, &p.CarID, &p.Owner.ID, &p.Owner.Name, &p.Owner.Surname, &p.Owner.Patronymic, &p.From, &p.To, &p.Reason); err != nil {
+			return nil, fmt.Errorf("error scanning ownership period: %w", err)
+		}
+		periods = append(periods, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return periods, nil
+}
+
+// OwnerCarsAt lists the cars an owner held at the given moment.
+func (db *Database) OwnerCarsAt(ctx context.Context, ownerId int, at time.Time) ([]OwnedCar, error) {
+	rows, err := db.QueryContext(ctx, OwnerCarsAt, ownerId, at)
+	if err != nil {
+		return nil, fmt.Errorf("error querying owner cars: %w", err)
+	}
+	defer rows.Close()
+
+	cars := []OwnedCar{}
+	for rows.Next() {
+		var car OwnedCar
+		if err := rows.Scan(&car.ID, &car.RegNum, &car.Mark, &car.Model, &car.Year); err != nil {
+			return nil, fmt.Errorf("error scanning owner car: %w", err)
+		}
+		cars = append(cars, car)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return cars, nil
+}
@@ -0,0 +1,467 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The parity tests run every case against the memory store and, when
+// TEST_POSTGRES_DSN points at a database they may wipe, against Postgres, so
+// that the two backends cannot drift apart.
+
+func storeBackends(t *testing.T) map[string]func(t *testing.T) Store {
+	t.Helper()
+	backends := map[string]func(t *testing.T) Store{
+		DriverMemory: func(t *testing.T) Store { return NewMemoryStore() },
+	}
+
+	if os.Getenv("TEST_POSTGRES_DSN") == "" {
+		return backends
+	}
+	conn := openTestDB(t)
+	backends[DriverPostgres] = func(t *testing.T) Store {
+		_, err := conn.Exec(`TRUNCATE peoples, cars, ownership_periods, audit_log, import_jobs, import_items RESTART IDENTITY CASCADE`)
+		if err != nil {
+			t.Fatalf("wiping test database: %v", err)
+		}
+		return &Database{conn}
+	}
+	return backends
+}
+
+// forEachStore runs test against a fresh, seeded store of every backend.
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	for name, open := range storeBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			seedCars(t, store)
+			test(t, store)
+		})
+	}
+}
+
+var seededCars = []struct {
+	regNum, mark, model string
+	year                int
+	owner               Owner
+	deleted             bool
+}{
+	{"A111AA150", "Toyota", "Corolla", 2005, Owner{Name: "Ivan", Surname: "Petrov"}, false},
+	{"B222BB150", "Toyota", "Camry", 2015, Owner{Name: "Anna", Surname: "Sidorova"}, false},
+	{"C333CC150", "Ford", "Focus", 2010, Owner{Name: "Ivan", Surname: "Petrov"}, false},
+	{"A444AA150", "Honda", "Civic", 2010, Owner{Name: "Anna", Surname: "Sidorova"}, false},
+	{"E555EE150", "Ford", "Mondeo", 2001, Owner{Name: "Ivan", Surname: "Petrov"}, true},
+}
+
+func seedCars(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+	for _, car := range seededCars {
+		ownerID, err := store.GetOrCreateOwner(ctx, car.owner)
+		if err != nil {
+			t.Fatalf("creating owner: %v", err)
+		}
+		id, err := store.AddNewCar(ctx, car.regNum, car.mark, car.model, car.year, ownerID, nil)
+		if err != nil {
+			t.Fatalf("adding %s: %v", car.regNum, err)
+		}
+		if car.deleted {
+			if err := store.DeleteCar(ctx, int(id)); err != nil {
+				t.Fatalf("deleting %s: %v", car.regNum, err)
+			}
+		}
+	}
+}
+
+func regNums(cars []Car) []string {
+	plates := make([]string, len(cars))
+	for i, car := range cars {
+		plates[i] = car.RegNum
+	}
+	return plates
+}
+
+func TestStoreFilterParity(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter CarFilter
+		want   []string
+	}{
+		{"everything", CarFilter{}, []string{"A111AA150", "B222BB150", "C333CC150", "A444AA150"}},
+		{"with deleted", CarFilter{IncludeDeleted: true}, []string{"A111AA150", "B222BB150", "C333CC150", "A444AA150", "E555EE150"}},
+		{"mark substring", CarFilter{Marks: []string{"toyo"}}, []string{"A111AA150", "B222BB150"}},
+		{"any of marks", CarFilter{Marks: []string{"toyota", "FORD"}}, []string{"A111AA150", "B222BB150", "C333CC150"}},
+		{"model", CarFilter{Models: []string{"civic"}}, []string{"A444AA150"}},
+		{"years", CarFilter{Years: []int{2010}}, []string{"C333CC150", "A444AA150"}},
+		{"year range", CarFilter{YearFrom: 2006, YearTo: 2012}, []string{"C333CC150", "A444AA150"}},
+		{"plate prefix", CarFilter{RegNumPrefixes: []string{"a"}}, []string{"A111AA150", "A444AA150"}},
+		{"plate prefix only", CarFilter{RegNumPrefixes: []string{"AA150"}}, nil},
+		{"owner surname", CarFilter{OwnerSurnames: []string{"sidor"}}, []string{"B222BB150", "A444AA150"}},
+		{"combined", CarFilter{Marks: []string{"ford"}, OwnerNames: []string{"ivan"}, IncludeDeleted: true}, []string{"C333CC150", "E555EE150"}},
+		{"sort desc", CarFilter{Sort: []CarSort{{Field: "year", Desc: true}}}, []string{"B222BB150", "C333CC150", "A444AA150", "A111AA150"}},
+		{"sort mixed", CarFilter{Sort: []CarSort{{Field: "mark"}, {Field: "year", Desc: true}}}, []string{"C333CC150", "A444AA150", "B222BB150", "A111AA150"}},
+		{"sort by owner", CarFilter{Sort: []CarSort{{Field: "ownerSurname", Desc: true}, {Field: "regNum"}}}, []string{"A444AA150", "B222BB150", "A111AA150", "C333CC150"}},
+		{"limit and offset", CarFilter{Limit: 2, Offset: 1}, []string{"B222BB150", "C333CC150"}},
+		{"offset past the end", CarFilter{Limit: 2, Offset: 10}, nil},
+	}
+	forEachStore(t, func(t *testing.T, store Store) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				filter := tt.filter
+				if filter.Limit == 0 {
+					filter.Limit = 100
+				}
+				cars, err := store.GridCarInfo(context.Background(), filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := regNums(cars); !slices.Equal(got, tt.want) {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+
+				total, err := store.CountCars(context.Background(), filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				filter.Limit, filter.Offset = 100, 0
+				all, _ := store.GridCarInfo(context.Background(), filter)
+				if total != len(all) {
+					t.Errorf("count = %d, listing has %d", total, len(all))
+				}
+			})
+		}
+	})
+}
+
+func TestStoreUnknownSortParity(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		_, err := store.GridCarInfo(context.Background(), CarFilter{Sort: []CarSort{{Field: "owner_id"}}, Limit: 10})
+		if !errors.Is(err, ErrUnknownSortField) {
+			t.Errorf("error = %v, want ErrUnknownSortField", err)
+		}
+	})
+}
+
+func TestStoreKeysetParity(t *testing.T) {
+	sorts := map[string][]CarSort{
+		"id":    nil,
+		"mixed": {{Field: "mark"}, {Field: "year", Desc: true}},
+		"owner": {{Field: "ownerName", Desc: true}, {Field: "model"}},
+	}
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		for name, sort := range sorts {
+			t.Run(name, func(t *testing.T) {
+				all, err := store.GridCarInfo(ctx, CarFilter{Sort: sort, Limit: 100})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var forward []Car
+				filter := CarFilter{Sort: sort, Limit: 2}
+				for {
+					page, err := store.GridCarInfo(ctx, filter)
+					if err != nil {
+						t.Fatal(err)
+					}
+					forward = append(forward, page...)
+					if len(page) < filter.Limit {
+						break
+					}
+					filter.After = filter.KeyOf(page[len(page)-1])
+				}
+				if !slices.Equal(regNums(forward), regNums(all)) {
+					t.Errorf("paging forward got %v, want %v", regNums(forward), regNums(all))
+				}
+
+				var backward []Car
+				filter = CarFilter{Sort: sort, Limit: 2, Before: CarFilter{Sort: sort}.KeyOf(all[len(all)-1])}
+				backward = append(backward, all[len(all)-1])
+				for {
+					page, err := store.GridCarInfo(ctx, filter)
+					if err != nil {
+						t.Fatal(err)
+					}
+					backward = append(slices.Clone(page), backward...)
+					if len(page) < filter.Limit {
+						break
+					}
+					filter.Before = filter.KeyOf(page[0])
+				}
+				if !slices.Equal(regNums(backward), regNums(all)) {
+					t.Errorf("paging backward got %v, want %v", regNums(backward), regNums(all))
+				}
+			})
+		}
+	})
+}
+
+func TestStoreAddExistingCarParity(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		ownerID, err := store.GetOrCreateOwner(ctx, Owner{Name: "Oleg", Surname: "Ivanov"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		existing, err := store.AddNewCar(ctx, "A111AA150", "Lada", "Vesta", 2020, ownerID, nil)
+		if !errors.Is(err, ErrCarExists) || existing != 1 {
+			t.Errorf("adding a stored plate: id %d, error %v, want 1 and ErrCarExists", existing, err)
+		}
+		deleted, err := store.AddNewCar(ctx, "E555EE150", "Lada", "Vesta", 2020, ownerID, nil)
+		if !errors.Is(err, ErrCarDeleted) || deleted != 5 {
+			t.Errorf("adding a deleted plate: id %d, error %v, want 5 and ErrCarDeleted", deleted, err)
+		}
+
+		car, err := store.GetCar(ctx, 1, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if car.Mark != "Toyota" || car.Owner.Surname != "Petrov" {
+			t.Errorf("stored car changed to %+v", car)
+		}
+	})
+}
+
+func TestStoreConcurrentAddParity(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		ownerID, err := store.GetOrCreateOwner(ctx, Owner{Name: "Oleg", Surname: "Ivanov"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		const callers = 8
+		ids := make([]int64, callers)
+		errs := make([]error, callers)
+		var wg sync.WaitGroup
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ids[i], errs[i] = store.AddNewCar(ctx, "K777KK777", "Lada", "Vesta", 2020, ownerID, nil)
+			}(i)
+		}
+		wg.Wait()
+
+		created := 0
+		for i, err := range errs {
+			switch {
+			case err == nil:
+				created++
+			case !errors.Is(err, ErrCarExists):
+				t.Errorf("caller %d: %v, want ErrCarExists", i, err)
+			}
+			if ids[i] != ids[0] {
+				t.Errorf("caller %d got car %d, caller 0 got %d", i, ids[i], ids[0])
+			}
+		}
+		if created != 1 {
+			t.Errorf("%d callers created the car, want 1", created)
+		}
+
+		cars, err := store.GridCarInfo(ctx, CarFilter{RegNumPrefixes: []string{"K777KK777"}, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cars) != 1 {
+			t.Errorf("%d cars stored under the plate, want 1", len(cars))
+		}
+	})
+}
+
+func TestStoreAtomicBatchParity(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  []int
+		opts BatchOptions
+		want []string
+	}{
+		{"stops at the first failure", []int{1, 999, 998}, BatchOptions{Atomic: true}, []string{BatchRolledBack, BatchNotFound, BatchRolledBack}},
+		{"rolls back the cars before it", []int{1, 2, 5}, BatchOptions{Atomic: true}, []string{BatchRolledBack, BatchRolledBack, BatchNotFound}},
+		{"not atomic", []int{1, 999, 2}, BatchOptions{}, []string{BatchDeleted, BatchNotFound, BatchDeleted}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStore(t, func(t *testing.T, store Store) {
+				items, err := store.BatchDeleteCars(context.Background(), tt.ids, tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := make([]string, len(items))
+				for i, item := range items {
+					got[i] = item.Status
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("statuses %v, want %v", got, tt.want)
+				}
+			})
+		})
+	}
+}
+
+func TestStoreUpdateKeepsOwnerParity(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		if err := store.UpdateCarInfo(ctx, 1, "Lada", "", 0, 0); err != nil {
+			t.Fatalf("updating without an owner: %v", err)
+		}
+		car, err := store.GetCar(ctx, 1, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if car.Mark != "Lada" || car.Model != "Corolla" || car.Owner.Surname != "Petrov" {
+			t.Errorf("car after update %+v, want a Lada Corolla still owned by Petrov", car)
+		}
+
+		history, err := store.CarOwnershipHistory(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 1 {
+			t.Errorf("%d ownership periods, want 1", len(history))
+		}
+	})
+}
+
+func auditOperations(t *testing.T, store Store, entity string, id int) []string {
+	t.Helper()
+	entries, err := store.GridAuditLog(context.Background(), AuditFilter{Entity: entity, EntityID: id, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	operations := make([]string, len(entries))
+	for i, entry := range entries {
+		operations[i] = entry.Operation
+	}
+	return operations
+}
+
+func TestStoreDeleteOwnerParity(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		// Sidorova (2) owns cars 2 and 4.
+		if err := store.DeleteOwner(ctx, 2, false); !errors.Is(err, ErrOwnerHasCars) {
+			t.Fatalf("deleting an owner with cars: %v, want ErrOwnerHasCars", err)
+		}
+		if err := store.DeleteOwner(ctx, 2, true); err != nil {
+			t.Fatalf("cascading: %v", err)
+		}
+		for _, id := range []int{2, 4} {
+			car, err := store.GetCar(ctx, id, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if car.DeletedAt == nil {
+				t.Errorf("car %d is not soft-deleted", id)
+			}
+			if got := auditOperations(t, store, EntityCar, id); len(got) == 0 || got[0] != OpCarDelete {
+				t.Errorf("car %d audit %v, want %s first", id, got, OpCarDelete)
+			}
+		}
+		if _, err := store.GetOwner(ctx, 2); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("getting the deleted owner: %v, want sql.ErrNoRows", err)
+		}
+		if store.OwnerExists(ctx, 2) {
+			t.Error("the deleted owner still exists")
+		}
+
+		if err := store.RestoreCar(ctx, 4); err != nil {
+			t.Fatal(err)
+		}
+		owner, err := store.GetOwner(ctx, 2)
+		if err != nil {
+			t.Fatalf("owner not restored with the car: %v", err)
+		}
+		if len(owner.Cars) != 1 || owner.Cars[0].ID != 4 {
+			t.Errorf("restored owner has cars %+v, want car 4", owner.Cars)
+		}
+		if got := auditOperations(t, store, EntityOwner, 2); !slices.Equal(got, []string{OpOwnerRestore, OpOwnerDelete, OpOwnerCreate}) {
+			t.Errorf("owner audit %v", got)
+		}
+	})
+}
+
+func TestStoreDeleteOwnerWithDeletedCarsParity(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		ownerID, err := store.GetOrCreateOwner(ctx, Owner{Name: "Oleg", Surname: "Ivanov"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		carID, err := store.AddNewCar(ctx, "K777KK777", "Lada", "Vesta", 2020, ownerID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.DeleteCar(ctx, int(carID)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.DeleteOwner(ctx, int(ownerID), false); err != nil {
+			t.Fatalf("deleting an owner with only deleted cars: %v", err)
+		}
+		if store.OwnerExists(ctx, int(ownerID)) {
+			t.Fatal("the deleted owner still exists")
+		}
+
+		if _, err := store.PurgeDeletedCars(ctx, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if got := auditOperations(t, store, EntityOwner, int(ownerID)); len(got) == 0 || got[0] != OpOwnerPurge {
+			t.Errorf("owner audit %v, want %s first", got, OpOwnerPurge)
+		}
+		recreated, err := store.GetOrCreateOwner(ctx, Owner{Name: "Oleg", Surname: "Ivanov"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recreated == ownerID {
+			t.Errorf("the purged owner %d came back", ownerID)
+		}
+	})
+}
+
+func TestStoreReviveDeletedOwnerParity(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		if err := store.DeleteOwner(ctx, 2, true); err != nil {
+			t.Fatal(err)
+		}
+		id, err := store.GetOrCreateOwner(ctx, Owner{Name: "Anna", Surname: "Sidorova"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != 2 {
+			t.Errorf("adding the deleted owner again got id %d, want 2", id)
+		}
+		owner, err := store.GetOwner(ctx, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(owner.Cars) != 0 {
+			t.Errorf("revived owner has cars %+v, want none", owner.Cars)
+		}
+	})
+}
+
+func TestStoreTransferInFutureParity(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		err := store.TransferCar(ctx, 1, 2, time.Now().Add(time.Hour), ReasonTransfer)
+		if !errors.Is(err, ErrTransferInFuture) {
+			t.Fatalf("transferring in the future: %v, want ErrTransferInFuture", err)
+		}
+		history, err := store.CarOwnershipHistory(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 1 {
+			t.Errorf("%d ownership periods, want 1", len(history))
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/logging"
+	"log/slog"
+	"time"
+)
+
+const purgeBatchSize = 500
+
+// PurgeDeletedCars permanently removes cars soft-deleted before the given
+// moment, in batches so a large backlog doesn't hold locks for long, then
+// the deleted owners none of the remaining cars point at.
+func (db *Database) PurgeDeletedCars(ctx context.Context, deletedBefore time.Time) (int, error) {
+	ctx = WithAuditMeta(ctx, ActorSystem, "")
+	purged := 0
+	for {
+		n, err := db.purgeBatch(ctx, deletedBefore)
+		purged += n
+		if err != nil {
+			return purged, err
+		}
+		if n < purgeBatchSize {
+			return purged, db.purgeOwners(ctx)
+		}
+	}
+}
+
+func (db *Database) purgeOwners(ctx context.Context) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx, PurgeDeletedOwners)
+	if err != nil {
+		return fmt.Errorf("error purging owners: %w", err)
+	}
+	var purged []Owner
+	for rows.Next() {
+		var owner Owner
+		if err := rows.Scan(&owner.ID, &owner.Name, &owner.Surname, &owner.Patronymic); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning purged owner: %w", err)
+		}
+		purged = append(purged, owner)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	for _, owner := range purged {
+		if err = writeAudit(ctx, tx, OpOwnerPurge, EntityOwner, owner.ID, owner, nil); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+func (db *Database) purgeBatch(ctx context.Context, deletedBefore time.Time) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx, PurgeDeletedCars, deletedBefore, purgeBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("error purging cars: %w", err)
+	}
+	var purged []carSnapshot
+	for rows.Next() {
+		var car carSnapshot
+		if err := rows.Scan(&car.ID, &car.RegNum, &car.Mark, &car.Model, &car.Year, &car.OwnerID, &car.DeletedAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning purged car: %w", err)
+		}
+		purged = append(purged, car)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	for _, car := range purged {
+		if err = writeAudit(ctx, tx, OpCarPurge, EntityCar, car.ID, car, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+	committed = true
+	return len(purged), nil
+}
+
+// RunPurger periodically purges cars that have been soft-deleted for longer
+// than retention, until ctx is cancelled. A non-positive interval disables
+// purging.
+func RunPurger(ctx context.Context, store CarStore, retention, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := store.PurgeDeletedCars(ctx, time.Now().Add(-retention))
+			logger := slog.Default().With("component", "purge")
+			if err != nil {
+				logger.Error("purge failed", logging.Err(err))
+			} else if purged > 0 {
+				logger.Info("permanently removed deleted cars", "purged", purged)
+			}
+		}
+	}
+}
@@ -1,39 +1,61 @@
 package database
 
 const (
-	CreateСheckTablePeoples = `
-		CREATE TABLE IF NOT EXISTS peoples (
-			id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
-			name VARCHAR(255) NOT NULL,
-			surname VARCHAR(255) NOT NULL,
-		    patronymic VARCHAR(255)   
-		);`
-	CreateCheckTableCars = `
-		CREATE TABLE IF NOT EXISTS cars (
-			id INT GENERATED ALWAYS AS IDENTITY,
-			reg_num VARCHAR(255) NOT NULL UNIQUE,
-			mark VARCHAR(255) NOT NULL,
-			model VARCHAR(255) NOT NULL,
-			year INT,
-			owner_id INT NOT NULL,
-			FOREIGN KEY (owner_id) REFERENCES peoples(id)
+	CreateSchemaMigrations = `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			dirty BOOLEAN NOT NULL DEFAULT FALSE,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
 		);`
+	CurrentMigration = `
+		SELECT version, dirty
+		FROM schema_migrations
+		ORDER BY dirty DESC, version DESC
+		LIMIT 1;`
+	MarkMigrationDirty = `
+		INSERT INTO schema_migrations (version, dirty)
+		VALUES ($1, TRUE)
+		ON CONFLICT (version) DO UPDATE SET dirty = TRUE;`
+	MarkMigrationApplied = `
+		UPDATE schema_migrations
+		SET dirty = FALSE, applied_at = now()
+		WHERE version = $1;`
+	DeleteMigration      = `DELETE FROM schema_migrations WHERE version = $1;`
+	AcquireMigrationLock = `SELECT pg_advisory_lock($1);`
+	ReleaseMigrationLock = `SELECT pg_advisory_unlock($1);`
+
 	GridCarInfo = `
-		SELECT cars.id, cars.reg_num, cars.mark, cars.model, cars.year, peoples.id, peoples.name, peoples.surname, peoples.patronymic
+		SELECT cars.id, cars.reg_num, cars.mark, cars.model, cars.year, cars.deleted_at, cars.provenance, cars.upstream_missing_since, peoples.id, peoples.name, peoples.surname, peoples.patronymic
 		FROM cars
-		JOIN peoples ON cars.owner_id = peoples.id
-		WHERE ($1 = '%' OR cars.mark LIKE $1) AND ($2 = '%' OR cars.model LIKE $2) AND ($3 = 0 OR cars.year = $3)
-		ORDER BY cars.id
-		LIMIT $4 OFFSET $5;`
+		JOIN peoples ON cars.owner_id = peoples.id`
+	CountCars = `
+		SELECT count(*)
+		FROM cars
+		JOIN peoples ON cars.owner_id = peoples.id`
 	GridOneCarInfo = `
-		SELECT cars.id, cars.reg_num, cars.mark, cars.model, cars.year, peoples.id, peoples.name, peoples.surname, peoples.patronymic
+		SELECT cars.id, cars.reg_num, cars.mark, cars.model, cars.year, cars.deleted_at, cars.provenance, cars.upstream_missing_since, peoples.id, peoples.name, peoples.surname, peoples.patronymic
+		FROM cars
+		JOIN peoples ON cars.owner_id = peoples.id
+		WHERE cars.id = $1 AND ($2 OR cars.deleted_at IS NULL);`
+	OwnerExists    = `SELECT EXISTS(SELECT 1 FROM peoples WHERE id = $1 AND deleted_at IS NULL)`
+	DeleteCar      = `UPDATE cars SET deleted_at = now() WHERE id = $1 RETURNING deleted_at;`
+	RestoreCar     = `UPDATE cars SET deleted_at = NULL WHERE id = $1;`
+	GetCarByRegNum = `
+		SELECT cars.id, cars.reg_num, cars.mark, cars.model, cars.year, cars.deleted_at, cars.provenance, cars.upstream_missing_since, peoples.id, peoples.name, peoples.surname, peoples.patronymic
 		FROM cars
 		JOIN peoples ON cars.owner_id = peoples.id
-		WHERE cars.id = $1;`
-	OwnerExists    = `SELECT EXISTS(SELECT 1 FROM peoples WHERE id = $1)`
-	DeleteCar      = `DELETE FROM cars WHERE id = $1;`
+		WHERE cars.reg_num = $1;`
+	SyncCar = `
+		UPDATE cars
+		SET mark = $2, model = $3, year = $4, owner_id = $5, provenance = $6, upstream_missing_since = NULL
+		WHERE id = $1;`
+	SetUpstreamMissing = `
+		UPDATE cars
+		SET upstream_missing_since = CASE WHEN $2::boolean THEN COALESCE(upstream_missing_since, now()) END
+		WHERE id = $1
+		RETURNING upstream_missing_since;`
 	CheckCarExists = `
-		SELECT id
+		SELECT id, deleted_at
 		FROM cars
 		WHERE reg_num = $1;`
 	UpdateCarInfo = `
@@ -42,17 +64,187 @@ const (
 			model = COALESCE(NULLIF($2, ''), model),
 			year = COALESCE(NULLIF($3, 0), year),
 			owner_id = COALESCE($5, owner_id)
-		WHERE id = $4;`
+		WHERE id = $4
+		RETURNING id, reg_num, mark, model, year, owner_id, provenance, upstream_missing_since;`
 	AddNewCar = `
-		INSERT INTO cars (reg_num, mark, model, year, owner_id)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO cars (reg_num, mark, model, year, owner_id, provenance)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id;`
 	СheckPerson = `
 		SELECT id
 		FROM peoples
-		WHERE name = $1 AND surname = $2;`
+		WHERE name = $1 AND surname = $2 AND COALESCE(patronymic, '') = COALESCE($3::varchar, '') AND deleted_at IS NULL;`
 	AddPerson = `
-		INSERT INTO peoples (name, surname)
-		VALUES ($1, $2)
-		RETURNING id`
+		INSERT INTO peoples (name, surname, patronymic)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name, surname, (COALESCE(patronymic, ''))) DO UPDATE
+		SET deleted_at = NULL
+		WHERE peoples.deleted_at IS NOT NULL
+		RETURNING id;`
+	GridOwnerInfo = `
+		SELECT id, name, surname, patronymic
+		FROM peoples
+		WHERE deleted_at IS NULL AND ($1 = '%' OR name LIKE $1) AND ($2 = '%' OR surname LIKE $2) AND ($3 = '%' OR patronymic LIKE $3)
+		ORDER BY id
+		LIMIT $4 OFFSET $5;`
+	GetOwner = `
+		SELECT id, name, surname, patronymic
+		FROM peoples
+		WHERE id = $1 AND deleted_at IS NULL;`
+	GetOwnerForUpdate = `
+		SELECT id, name, surname, patronymic
+		FROM peoples
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE;`
+	OwnerCars = `
+		SELECT id, reg_num, mark, model, year
+		FROM cars
+		WHERE owner_id = $1 AND deleted_at IS NULL
+		ORDER BY id;`
+	UpdateOwner = `
+		UPDATE peoples
+		SET name = COALESCE(NULLIF($2, ''), name),
+			surname = COALESCE(NULLIF($3, ''), surname),
+			patronymic = COALESCE($4, patronymic)
+		WHERE id = $1
+		RETURNING id, name, surname, patronymic;`
+	OwnerHasCars    = `SELECT EXISTS(SELECT 1 FROM cars WHERE owner_id = $1)`
+	OwnerHasHistory = `
+		SELECT EXISTS(
+			SELECT 1
+			FROM ownership_periods
+			JOIN cars ON ownership_periods.car_id = cars.id
+			WHERE ownership_periods.owner_id = $1 AND cars.owner_id <> $1
+		)`
+	DeleteOwner      = `DELETE FROM peoples WHERE id = $1;`
+	MarkOwnerDeleted = `UPDATE peoples SET deleted_at = now() WHERE id = $1;`
+	RestoreOwner     = `
+		UPDATE peoples
+		SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING id, name, surname, patronymic;`
+	PurgeDeletedOwners = `
+		DELETE FROM peoples
+		WHERE deleted_at IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM cars WHERE cars.owner_id = peoples.id)
+			AND NOT EXISTS (SELECT 1 FROM ownership_periods WHERE ownership_periods.owner_id = peoples.id)
+		RETURNING id, name, surname, patronymic;`
+	SetCarOwner           = `UPDATE cars SET owner_id = $2 WHERE id = $1;`
+	GetOpenOwnershipStart = `
+		SELECT owned_from
+		FROM ownership_periods
+		WHERE car_id = $1 AND owned_to IS NULL;`
+	CloseOwnershipPeriod = `
+		UPDATE ownership_periods
+		SET owned_to = $2
+		WHERE car_id = $1 AND owned_to IS NULL;`
+	OpenOwnershipPeriod = `
+		INSERT INTO ownership_periods (car_id, owner_id, owned_from, reason)
+		VALUES ($1, $2, $3, $4);`
+	CarOwnershipHistory = `
+		SELECT ownership_periods.id, ownership_periods.car_id, peoples.id, peoples.name, peoples.surname, peoples.patronymic,
+			ownership_periods.owned_from, ownership_periods.owned_to, ownership_periods.reason
+		FROM ownership_periods
+		JOIN peoples ON ownership_periods.owner_id = peoples.id
+		WHERE ownership_periods.car_id = $1
+		ORDER BY ownership_periods.owned_from, ownership_periods.id;`
+	OwnerCarsAt = `
+		SELECT cars.id, cars.reg_num, cars.mark, cars.model, cars.year
+		FROM ownership_periods
+		JOIN cars ON ownership_periods.car_id = cars.id
+		WHERE ownership_periods.owner_id = $1
+			AND ownership_periods.owned_from <= $2
+			AND (ownership_periods.owned_to IS NULL OR ownership_periods.owned_to > $2)
+			AND cars.deleted_at IS NULL
+		ORDER BY cars.id;`
+	GetCarForUpdate = `
+		SELECT id, reg_num, mark, model, year, owner_id, deleted_at, provenance, upstream_missing_since
+		FROM cars
+		WHERE id = $1
+		FOR UPDATE;`
+	GetOwnerCarsForUpdate = `
+		SELECT id, reg_num, mark, model, year, owner_id, deleted_at
+		FROM cars
+		WHERE owner_id = $1 AND deleted_at IS NULL
+		ORDER BY id
+		FOR UPDATE;`
+	CreateImportJob = `
+		INSERT INTO import_jobs (state, actor, request_id, total)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at;`
+	AddImportItems = `
+		INSERT INTO import_items (job_id, position, reg_num, spelled)
+		SELECT $1, plates.position - 1, plates.reg_num, plates.spelled
+		FROM unnest($2::text[], $3::text[]) WITH ORDINALITY AS plates(reg_num, spelled, position);`
+	GetImportJob = `
+		SELECT id, state, actor, request_id, total, error, created_at, updated_at, finished_at
+		FROM import_jobs
+		WHERE id = $1;`
+	CountImportItems = `
+		SELECT status, count(*)
+		FROM import_items
+		WHERE job_id = $1
+		GROUP BY status;`
+	GridImportItems = `
+		SELECT position, reg_num, spelled, status, car_id, error
+		FROM import_items
+		WHERE job_id = $1 AND (COALESCE(cardinality($2::text[]), 0) = 0 OR status = ANY($2)) AND position >= $3
+		ORDER BY position
+		LIMIT $4 OFFSET $5;`
+	SetImportItemResult = `
+		UPDATE import_items
+		SET status = $3, car_id = $4, error = $5
+		WHERE job_id = $1 AND position = $2;`
+	SetImportJobState = `
+		UPDATE import_jobs
+		SET state = $2::varchar, error = $3, updated_at = now(),
+			finished_at = CASE WHEN $2::varchar IN ('completed', 'canceled', 'failed') THEN now() END
+		WHERE id = $1 AND state = ANY($4)
+		RETURNING id;`
+	UnfinishedImportJobs = `
+		SELECT id, state, actor, request_id
+		FROM import_jobs
+		WHERE state IN ('queued', 'running')
+		ORDER BY id;`
+	InsertAuditEntry = `
+		INSERT INTO audit_log (actor, request_id, operation, entity, entity_id, before_state, after_state)
+		VALUES ($1, $2, $3, $4, $5, $6, $7);`
+	GridAuditLog = `
+		SELECT id, occurred_at, actor, request_id, operation, entity, entity_id, before_state, after_state
+		FROM audit_log
+		WHERE ($1 = '' OR entity = $1)
+			AND ($2 = 0 OR entity_id = $2)
+			AND ($3 = '' OR operation = $3)
+			AND ($4 = '' OR actor = $4)
+			AND ($5 = '' OR request_id = $5)
+			AND ($6::timestamptz IS NULL OR occurred_at >= $6)
+			AND ($7::timestamptz IS NULL OR occurred_at < $7)
+		ORDER BY id DESC
+		LIMIT $8 OFFSET $9;`
+	PurgeDeletedCars = `
+		DELETE FROM cars
+		WHERE id IN (
+			SELECT id
+			FROM cars
+			WHERE deleted_at < $1
+			ORDER BY id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, reg_num, mark, model, year, owner_id, deleted_at;`
+	SearchCars = `
+		SELECT cars.id, cars.reg_num, cars.mark, cars.model, cars.year, peoples.id, peoples.name, peoples.surname, peoples.patronymic,
+			word_similarity($1, cars.reg_num || ' ' || cars.mark || ' ' || cars.model) AS score
+		FROM cars
+		JOIN peoples ON cars.owner_id = peoples.id
+		WHERE cars.deleted_at IS NULL AND $1 <% (cars.reg_num || ' ' || cars.mark || ' ' || cars.model)
+		ORDER BY score DESC, cars.id
+		LIMIT $2;`
+	SearchOwners = `
+		SELECT id, name, surname, patronymic,
+			word_similarity($1, name || ' ' || surname || ' ' || COALESCE(patronymic, '')) AS score
+		FROM peoples
+		WHERE deleted_at IS NULL AND $1 <% (name || ' ' || surname || ' ' || COALESCE(patronymic, ''))
+		ORDER BY score DESC, id
+		LIMIT $2;`
 )
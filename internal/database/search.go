@@ -0,0 +1,244 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	HitCar   = "car"
+	HitOwner = "owner"
+)
+
+const (
+	// searchThreshold mirrors pg_trgm.word_similarity_threshold, below which
+	// the <% operator used by the search queries does not match.
+	searchThreshold = 0.6
+	// highlightThreshold mirrors pg_trgm.similarity_threshold: a misspelled
+	// word at least this similar to a query word is highlighted as a whole.
+	highlightThreshold = 0.3
+)
+
+// SearchHit is a car or an owner matching a search query. Highlights holds the
+// matching fields with the matched parts wrapped in <em>, the rest of the text
+// HTML-escaped.
+type SearchHit struct {
+	Type       string            `json:"type"`
+	Score      float64           `json:"score"`
+	Car        *Car              `json:"car,omitempty"`
+	Owner      *Owner            `json:"owner,omitempty"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// Search looks up cars by plate, mark and model and owners by full name,
+// tolerating typos, and returns the best hits of both kinds by relevance.
+func (db *Database) Search(ctx context.Context, query string, limit int) ([]SearchHit, error) {
+	var hits []SearchHit
+
+	rows, err := db.QueryContext(ctx, SearchCars, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error searching cars: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		hit := SearchHit{Type: HitCar, Car: &Car{}}
+		car := hit.Car
+		if err := rows.Scan(&car.ID, &car.RegNum, &car.Mark, &car.Model, &car.Year, &car.Owner.ID, &car.Owner.Name, &car.Owner.Surname, &car.Owner.Patronymic, &hit.Score); err != nil {
+			return nil, fmt.Errorf("error scanning car hit: %w", err)
+		}
+		hits = append(hits, hit)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	rows, err = db.QueryContext(ctx, SearchOwners, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error searching owners: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		hit := SearchHit{Type: HitOwner, Owner: &Owner{}}
+		owner := hit.Owner
+		if err := rows.Scan(&owner.ID, &owner.Name, &owner.Surname, &owner.Patronymic, &hit.Score); err != nil {
+			return nil, fmt.Errorf("error scanning owner hit: %w", err)
+		}
+		hits = append(hits, hit)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return rankHits(hits, query, limit), nil
+}
+
+// rankHits orders hits of both kinds by score, keeps the best limit of them
+// and highlights what matched.
+func rankHits(hits []SearchHit, query string, limit int) []SearchHit {
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].Type != hits[j].Type {
+			return hits[i].Type == HitCar
+		}
+		return hits[i].id() < hits[j].id()
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	tokens := searchWords(query)
+	for i := range hits {
+		hit := &hits[i]
+		hit.Highlights = map[string]string{}
+		fields := map[string]string{}
+		if hit.Car != nil {
+			fields["regNum"], fields["mark"], fields["model"] = hit.Car.RegNum, hit.Car.Mark, hit.Car.Model
+		} else {
+			fields["name"], fields["surname"] = hit.Owner.Name, hit.Owner.Surname
+			if hit.Owner.Patronymic != nil {
+				fields["patronymic"] = *hit.Owner.Patronymic
+			}
+		}
+		for field, text := range fields {
+			if marked, ok := highlight(text, tokens); ok {
+				hit.Highlights[field] = marked
+			}
+		}
+	}
+	return hits
+}
+
+func (h SearchHit) id() int {
+	if h.Car != nil {
+		return h.Car.ID
+	}
+	return h.Owner.ID
+}
+
+func carSearchDocument(car Car) string {
+	return car.RegNum + " " + car.Mark + " " + car.Model
+}
+
+func ownerSearchDocument(owner Owner) string {
+	document := owner.Name + " " + owner.Surname + " "
+	if owner.Patronymic != nil {
+		document += *owner.Patronymic
+	}
+	return document
+}
+
+// highlight wraps the parts of text that match the query words in <em>: the
+// matched substring when a word contains a query word, the whole word when
+// it is only similar to one.
+func highlight(text string, tokens []string) (string, bool) {
+	var b strings.Builder
+	matched := false
+	last := 0
+	for _, span := range wordSpans(text) {
+		word := strings.ToLower(text[span[0]:span[1]])
+		start, end := -1, -1
+		for _, token := range tokens {
+			if i := strings.Index(word, token); i >= 0 && len(token) > end-start {
+				start, end = i, i+len(token)
+			}
+		}
+		if start < 0 {
+			for _, token := range tokens {
+				if trigramSimilarity(token, word) >= highlightThreshold {
+					start, end = 0, len(word)
+					break
+				}
+			}
+		}
+		if start < 0 {
+			continue
+		}
+		// Lower-casing may change byte lengths; fall back to the whole word.
+		if len(word) != span[1]-span[0] {
+			start, end = 0, span[1]-span[0]
+		}
+		b.WriteString(html.EscapeString(text[last : span[0]+start]))
+		b.WriteString("<em>" + html.EscapeString(text[span[0]+start:span[0]+end]) + "</em>")
+		last = span[0] + end
+		matched = true
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String(), matched
+}
+
+// wordSimilarity approximates pg_trgm word_similarity: the share of the query
+// trigrams found in the document.
+func wordSimilarity(query, document string) float64 {
+	queryTrigrams := trigrams(query)
+	if len(queryTrigrams) == 0 {
+		return 0
+	}
+	documentTrigrams := trigrams(document)
+	common := 0
+	for trigram := range queryTrigrams {
+		if _, ok := documentTrigrams[trigram]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(queryTrigrams))
+}
+
+// trigramSimilarity is pg_trgm similarity: shared trigrams over all trigrams.
+func trigramSimilarity(a, b string) float64 {
+	aTrigrams, bTrigrams := trigrams(a), trigrams(b)
+	common := 0
+	for trigram := range aTrigrams {
+		if _, ok := bTrigrams[trigram]; ok {
+			common++
+		}
+	}
+	total := len(aTrigrams) + len(bTrigrams) - common
+	if total == 0 {
+		return 0
+	}
+	return float64(common) / float64(total)
+}
+
+// trigrams builds the trigram set of a text the way pg_trgm does: every
+// lower-cased word padded with two spaces in front and one behind.
+func trigrams(text string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, word := range searchWords(text) {
+		runes := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			set[string(runes[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// wordSpans returns the byte ranges of the words of a text.
+func wordSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if inWord && start < 0 {
+			start = i
+		} else if !inWord && start >= 0 {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
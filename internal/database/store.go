@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"github.com/likimiad/car-management-api/internal/config"
+	"log"
+	"time"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+)
+
+type CarStore interface {
+	GridCarInfo(ctx context.Context, filter CarFilter) ([]Car, error)
+	CountCars(ctx context.Context, filter CarFilter) (int, error)
+	GetCar(ctx context.Context, id int, includeDeleted bool) (*Car, error)
+	AddNewCar(ctx context.Context, regNum, mark, model string, year int, ownerId int64, provenance Provenance) (int64, error)
+	UpdateCarInfo(ctx context.Context, id int, mark, model string, year, ownerId int) error
+	DeleteCar(ctx context.Context, id int) error
+	RestoreCar(ctx context.Context, id int) error
+	PurgeDeletedCars(ctx context.Context, deletedBefore time.Time) (int, error)
+	TransferCar(ctx context.Context, id, ownerId int, at time.Time, reason string) error
+	CarOwnershipHistory(ctx context.Context, id int) ([]OwnershipPeriod, error)
+	BatchDeleteCars(ctx context.Context, ids []int, opts BatchOptions) ([]BatchItem, error)
+	BatchUpdateCars(ctx context.Context, ids []int, patch CarPatch, opts BatchOptions) ([]BatchItem, error)
+}
+
+type OwnerStore interface {
+	GridOwnerInfo(ctx context.Context, name, surname, patronymic string, limit, offset int) ([]Owner, error)
+	GetOwner(ctx context.Context, id int) (*OwnerDetails, error)
+	AddOwner(ctx context.Context, owner Owner) (int64, error)
+	UpdateOwner(ctx context.Context, id int, owner Owner) error
+	DeleteOwner(ctx context.Context, id int, cascade bool) error
+	GetOrCreateOwner(ctx context.Context, owner Owner) (int64, error)
+	OwnerExists(ctx context.Context, ownerId int) bool
+	OwnerCarsAt(ctx context.Context, ownerId int, at time.Time) ([]OwnedCar, error)
+}
+
+type AuditStore interface {
+	GridAuditLog(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+}
+
+// SyncStore holds the writes made by the upstream catalog sync.
+type SyncStore interface {
+	GetCarByRegNum(ctx context.Context, regNum string) (*Car, error)
+	SyncCar(ctx context.Context, id int, mark, model string, year, ownerId int, provenance Provenance) error
+	SetUpstreamMissing(ctx context.Context, id int, missing bool) error
+}
+
+// ImportStore persists bulk import jobs and the status of every plate in them.
+type ImportStore interface {
+	CreateImportJob(ctx context.Context, regNums, spelled []string) (*ImportJob, error)
+	GetImportJob(ctx context.Context, id int) (*ImportJob, error)
+	GridImportItems(ctx context.Context, jobID int, filter ImportItemFilter) ([]ImportItem, error)
+	SetImportItemResult(ctx context.Context, jobID int, item ImportItem) error
+	SetImportJobState(ctx context.Context, id int, from []string, state, errMsg string) error
+	UnfinishedImportJobs(ctx context.Context) ([]ImportJob, error)
+}
+
+type SearchStore interface {
+	Search(ctx context.Context, query string, limit int) ([]SearchHit, error)
+}
+
+// Store is everything the HTTP layer needs from a storage backend.
+type Store interface {
+	CarStore
+	OwnerStore
+	AuditStore
+	SearchStore
+	SyncStore
+	ImportStore
+	Close() error
+}
+
+// HealthChecker is implemented by backends that can tell whether they are
+// reachable and their schema matches the binary.
+type HealthChecker interface {
+	PingContext(ctx context.Context) error
+	MigrationStatus(ctx context.Context) (MigrationStatus, error)
+}
+
+var (
+	_ Store         = (*Database)(nil)
+	_ Store         = (*MemoryStore)(nil)
+	_ HealthChecker = (*Database)(nil)
+)
+
+// Open returns the storage backend selected by cfg.Driver.
+func Open(cfg config.DatabaseConfig) Store {
+	switch cfg.Driver {
+	case DriverPostgres:
+		return InitDatabase(cfg)
+	case DriverMemory:
+		return NewMemoryStore()
+	default:
+		log.Fatalf("unknown database driver %q", cfg.Driver)
+		return nil
+	}
+}
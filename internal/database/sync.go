@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"time"
+)
+
+// GetCarByRegNum returns the car with the given plate, soft-deleted or not.
+func (db *Database) GetCarByRegNum(ctx context.Context, regNum string) (*Car, error) {
+	var car Car
+	err := db.QueryRowContext(ctx, GetCarByRegNum, regNum).Scan(&car.ID, &car.RegNum, &car.Mark, &car.Model, &car.Year, &car.DeletedAt, &car.Provenance, &car.UpstreamMissingSince, &car.Owner.ID, &car.Owner.Name, &car.Owner.Surname, &car.Owner.Patronymic)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error querying car by plate: %w", err)
+	}
+	return &car, nil
+}
+
+// SyncCar overwrites a car with the data held upstream and clears its missing
+// flag. A new owner starts an ownership period with the synced reason.
+func (db *Database) SyncCar(ctx context.Context, id int, mark, model string, year, ownerId int, provenance Provenance) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	before, err := lockCar(ctx, tx, id, false)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, SyncCar, id, mark, model, year, ownerId, provenance); err != nil {
+		return fmt.Errorf("error syncing car: %w", err)
+	}
+
+	if before.OwnerID != ownerId {
+		if err = recordOwnershipChange(ctx, tx, id, ownerId, time.Now(), ReasonSynced); err != nil {
+			return err
+		}
+	}
+
+	after := *before
+	after.Mark, after.Model, after.Year, after.OwnerID = mark, model, year, ownerId
+	after.Provenance = maps.Clone(provenance)
+	after.UpstreamMissingSince = nil
+	if err = writeAudit(ctx, tx, OpCarSync, EntityCar, id, before, after); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+// SetUpstreamMissing flags a car as no longer listed upstream, keeping the
+// time it was first noticed, or clears the flag.
+func (db *Database) SetUpstreamMissing(ctx context.Context, id int, missing bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	before, err := lockCar(ctx, tx, id, false)
+	if err != nil {
+		return err
+	}
+	if (before.UpstreamMissingSince != nil) == missing {
+		return nil
+	}
+
+	after := *before
+	if err = tx.QueryRowContext(ctx, SetUpstreamMissing, id, missing).Scan(&after.UpstreamMissingSince); err != nil {
+		return fmt.Errorf("error flagging car: %w", err)
+	}
+
+	if err = writeAudit(ctx, tx, OpCarSync, EntityCar, id, before, after); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	committed = true
+	return nil
+}
@@ -0,0 +1,130 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"github.com/likimiad/car-management-api/internal/database"
+	"sync"
+	"time"
+)
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is a snapshot of a circuit breaker for health reporting.
+type BreakerState struct {
+	Provider            string     `json:"provider"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"`
+	RetryAt             *time.Time `json:"retryAt,omitempty"`
+}
+
+// HealthReporter is implemented by providers that track the health of their
+// upstream.
+type HealthReporter interface {
+	Health() []BreakerState
+}
+
+// Breaker stops calling a provider after Threshold consecutive upstream
+// failures and fails fast with ErrCircuitOpen for Cooldown. Then a single
+// probe call is let through: success closes the circuit, failure opens it
+// again. Not found, bad payload and other 4xx answers count as successes, the
+// upstream being up.
+type Breaker struct {
+	provider  CarInfoProvider
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewBreaker(provider CarInfoProvider, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{provider: provider, threshold: threshold, cooldown: cooldown, state: BreakerClosed}
+}
+
+func (b *Breaker) Name() string {
+	return b.provider.Name()
+}
+
+func (b *Breaker) CarInfo(ctx context.Context, regNum string) (database.Car, error) {
+	if err := b.allow(); err != nil {
+		return database.Car{}, &Error{Provider: b.provider.Name(), RegNum: regNum, Kind: ErrUpstream, Err: err}
+	}
+	car, err := b.provider.CarInfo(ctx, regNum)
+	b.record(err)
+	return car, err
+}
+
+func (b *Breaker) Health() []BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := BreakerState{Provider: b.provider.Name(), State: b.currentState(), ConsecutiveFailures: b.failures}
+	if b.state != BreakerClosed {
+		openedAt, retryAt := b.openedAt, b.openedAt.Add(b.cooldown)
+		state.OpenedAt, state.RetryAt = &openedAt, &retryAt
+	}
+	return []BreakerState{state}
+}
+
+// Probe goes past the breaker: an open circuit is exactly when it is worth
+// knowing whether the upstream is back.
+func (b *Breaker) Probe(ctx context.Context) []ProbeResult {
+	if p, ok := b.provider.(Prober); ok {
+		return p.Probe(ctx)
+	}
+	return nil
+}
+
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case BreakerOpen:
+		return ErrCircuitOpen
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.state, b.probing = BreakerHalfOpen, true
+	}
+	return nil
+}
+
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err == nil || !retryable(err) {
+		b.state, b.failures = BreakerClosed, 0
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state, b.openedAt = BreakerOpen, time.Now()
+	}
+}
+
+// currentState turns an open circuit half-open once the cooldown is over;
+// callers must hold b.mu.
+func (b *Breaker) currentState() string {
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
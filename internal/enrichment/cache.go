@@ -0,0 +1,207 @@
+package enrichment
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"github.com/likimiad/car-management-api/internal/database"
+	"maps"
+	"sync"
+	"time"
+)
+
+// CacheStats counts how lookups were served since start.
+type CacheStats struct {
+	Size         int   `json:"size"`
+	Capacity     int   `json:"capacity"`
+	Hits         int64 `json:"hits"`
+	NegativeHits int64 `json:"negativeHits"`
+	Misses       int64 `json:"misses"`
+	Coalesced    int64 `json:"coalesced"`
+	Evictions    int64 `json:"evictions"`
+}
+
+// CacheStatsReporter is implemented by providers that cache lookups.
+type CacheStatsReporter interface {
+	CacheStats() CacheStats
+}
+
+type cacheEntry struct {
+	regNum    string
+	car       database.Car
+	notFound  bool
+	expiresAt time.Time
+}
+
+// call is a lookup in flight that concurrent callers for the same plate
+// wait for instead of asking the upstream again.
+type call struct {
+	done chan struct{}
+	car  database.Car
+	err  error
+}
+
+// Caching keeps found cars for ttl and not found answers for negativeTTL,
+// holding at most capacity plates and evicting the least recently used.
+// Other failures are not cached.
+type Caching struct {
+	provider    CarInfoProvider
+	capacity    int
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	inflight map[string]*call
+	stats    CacheStats
+}
+
+func NewCaching(provider CarInfoProvider, capacity int, ttl, negativeTTL time.Duration) *Caching {
+	return &Caching{
+		provider:    provider,
+		capacity:    capacity,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		inflight:    make(map[string]*call),
+	}
+}
+
+func (c *Caching) Name() string {
+	return c.provider.Name()
+}
+
+func (c *Caching) CarInfo(ctx context.Context, regNum string) (database.Car, error) {
+	for {
+		c.mu.Lock()
+		if entry, ok := c.lookup(regNum); ok {
+			c.mu.Unlock()
+			if entry.notFound {
+				return database.Car{}, &Error{Provider: c.provider.Name(), RegNum: regNum, Kind: ErrNotFound}
+			}
+			return copyCar(entry.car), nil
+		}
+		if cl, ok := c.inflight[regNum]; ok {
+			c.stats.Coalesced++
+			c.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				return database.Car{}, &Error{Provider: c.provider.Name(), RegNum: regNum, Kind: ErrUpstream, Err: ctx.Err()}
+			case <-cl.done:
+			}
+			// The leading caller gave up; look the plate up again ourselves.
+			if errors.Is(cl.err, context.Canceled) || errors.Is(cl.err, context.DeadlineExceeded) {
+				continue
+			}
+			return copyCar(cl.car), cl.err
+		}
+		cl := &call{done: make(chan struct{})}
+		c.inflight[regNum] = cl
+		c.stats.Misses++
+		c.mu.Unlock()
+
+		cl.car, cl.err = c.provider.CarInfo(ctx, regNum)
+
+		c.mu.Lock()
+		delete(c.inflight, regNum)
+		if cl.err == nil {
+			c.store(&cacheEntry{regNum: regNum, car: copyCar(cl.car), expiresAt: time.Now().Add(c.ttl)})
+		} else if errors.Is(cl.err, ErrNotFound) {
+			c.store(&cacheEntry{regNum: regNum, notFound: true, expiresAt: time.Now().Add(c.negativeTTL)})
+		}
+		c.mu.Unlock()
+		close(cl.done)
+		return cl.car, cl.err
+	}
+}
+
+// Refresh asks the provider again, bypassing the cache, and caches the answer
+// for later lookups.
+func (c *Caching) Refresh(ctx context.Context, regNum string) (database.Car, error) {
+	car, err := c.provider.CarInfo(ctx, regNum)
+
+	c.mu.Lock()
+	if err == nil {
+		c.store(&cacheEntry{regNum: regNum, car: copyCar(car), expiresAt: time.Now().Add(c.ttl)})
+	} else if errors.Is(err, ErrNotFound) {
+		c.store(&cacheEntry{regNum: regNum, notFound: true, expiresAt: time.Now().Add(c.negativeTTL)})
+	}
+	c.mu.Unlock()
+	return car, err
+}
+
+func (c *Caching) CacheStats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size, stats.Capacity = c.lru.Len(), c.capacity
+	return stats
+}
+
+func (c *Caching) Health() []BreakerState {
+	if h, ok := c.provider.(HealthReporter); ok {
+		return h.Health()
+	}
+	return nil
+}
+
+func (c *Caching) Probe(ctx context.Context) []ProbeResult {
+	if p, ok := c.provider.(Prober); ok {
+		return p.Probe(ctx)
+	}
+	return nil
+}
+
+// lookup returns a live entry and marks it recently used, dropping it if it
+// has expired; callers must hold c.mu.
+func (c *Caching) lookup(regNum string) (*cacheEntry, bool) {
+	elem, ok := c.entries[regNum]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.lru.Remove(elem)
+		delete(c.entries, regNum)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	if entry.notFound {
+		c.stats.NegativeHits++
+	} else {
+		c.stats.Hits++
+	}
+	return entry, true
+}
+
+// store adds or replaces an entry, evicting the least recently used ones
+// over capacity; callers must hold c.mu.
+func (c *Caching) store(entry *cacheEntry) {
+	if c.capacity <= 0 {
+		return
+	}
+	if elem, ok := c.entries[entry.regNum]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[entry.regNum] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).regNum)
+		c.stats.Evictions++
+	}
+}
+
+func copyCar(car database.Car) database.Car {
+	car.Provenance = maps.Clone(car.Provenance)
+	if car.Owner.Patronymic != nil {
+		patronymic := *car.Owner.Patronymic
+		car.Owner.Patronymic = &patronymic
+	}
+	return car
+}
@@ -0,0 +1,218 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"github.com/likimiad/car-management-api/internal/database"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingProvider answers every plate through lookup, numbering the calls
+// from 1, and counts them.
+type countingProvider struct {
+	calls  atomic.Int32
+	lookup func(ctx context.Context, regNum string, call int32) (database.Car, error)
+}
+
+func (p *countingProvider) Name() string { return "fake" }
+
+func (p *countingProvider) CarInfo(ctx context.Context, regNum string) (database.Car, error) {
+	return p.lookup(ctx, regNum, p.calls.Add(1))
+}
+
+func foundCar(ctx context.Context, regNum string, call int32) (database.Car, error) {
+	return database.Car{RegNum: regNum, Mark: "Lada", Model: "Vesta", Year: 2020}, nil
+}
+
+// waitFor polls until cond holds, failing the test after a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCachingHitsAndEvictsLeastRecentlyUsed(t *testing.T) {
+	provider := &countingProvider{lookup: foundCar}
+	cache := NewCaching(provider, 2, time.Hour, time.Hour)
+	ctx := context.Background()
+
+	for _, regNum := range []string{"A1", "B2", "A1", "C3", "A1", "B2"} {
+		if _, err := cache.CarInfo(ctx, regNum); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A1 and B2 are looked up, A1 is a hit, C3 evicts B2 as A1 was used more
+	// recently, A1 is a hit and B2 is looked up again, evicting C3.
+	if got := provider.calls.Load(); got != 4 {
+		t.Errorf("provider called %d times, want 4", got)
+	}
+	stats := cache.CacheStats()
+	want := CacheStats{Size: 2, Capacity: 2, Hits: 2, Misses: 4, Evictions: 2}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	if _, err := cache.CarInfo(ctx, "C3"); err != nil {
+		t.Fatal(err)
+	}
+	if got := provider.calls.Load(); got != 5 {
+		t.Errorf("evicted plate served from the cache: %d calls, want 5", got)
+	}
+}
+
+func TestCachingExpiresEntries(t *testing.T) {
+	provider := &countingProvider{lookup: foundCar}
+	cache := NewCaching(provider, 10, 20*time.Millisecond, time.Hour)
+	ctx := context.Background()
+
+	_, _ = cache.CarInfo(ctx, "A1")
+	_, _ = cache.CarInfo(ctx, "A1")
+	time.Sleep(30 * time.Millisecond)
+	_, _ = cache.CarInfo(ctx, "A1")
+	if got := provider.calls.Load(); got != 2 {
+		t.Errorf("provider called %d times, want 2", got)
+	}
+}
+
+func TestCachingNegativeTTL(t *testing.T) {
+	provider := &countingProvider{lookup: func(ctx context.Context, regNum string, call int32) (database.Car, error) {
+		if call == 1 {
+			return database.Car{}, &Error{Provider: "fake", RegNum: regNum, Kind: ErrNotFound}
+		}
+		return foundCar(ctx, regNum, call)
+	}}
+	cache := NewCaching(provider, 10, time.Hour, 20*time.Millisecond)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.CarInfo(ctx, "A1"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("lookup %d: error = %v, want ErrNotFound", i, err)
+		}
+	}
+	if got := cache.CacheStats().NegativeHits; got != 1 {
+		t.Errorf("negative hits = %d, want 1", got)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := cache.CarInfo(ctx, "A1"); err != nil {
+		t.Fatalf("not found answer outlived the negative ttl: %v", err)
+	}
+	if got := provider.calls.Load(); got != 2 {
+		t.Errorf("provider called %d times, want 2", got)
+	}
+}
+
+func TestCachingSkipsOtherFailures(t *testing.T) {
+	provider := &countingProvider{lookup: func(ctx context.Context, regNum string, call int32) (database.Car, error) {
+		return database.Car{}, &Error{Provider: "fake", RegNum: regNum, Kind: ErrUpstream, StatusCode: 502}
+	}}
+	cache := NewCaching(provider, 10, time.Hour, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.CarInfo(context.Background(), "A1"); !errors.Is(err, ErrUpstream) {
+			t.Fatalf("error = %v, want ErrUpstream", err)
+		}
+	}
+	if got := provider.calls.Load(); got != 2 {
+		t.Errorf("provider called %d times, want 2", got)
+	}
+}
+
+func TestCachingCoalescesConcurrentLookups(t *testing.T) {
+	release := make(chan struct{})
+	provider := &countingProvider{lookup: func(ctx context.Context, regNum string, call int32) (database.Car, error) {
+		<-release
+		return foundCar(ctx, regNum, call)
+	}}
+	cache := NewCaching(provider, 10, time.Hour, time.Hour)
+
+	const callers = 5
+	var wg sync.WaitGroup
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = cache.CarInfo(context.Background(), "A1")
+		}(i)
+	}
+	waitFor(t, "the callers to coalesce", func() bool { return cache.CacheStats().Coalesced == callers-1 })
+	close(release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("caller %d: %v", i, err)
+		}
+	}
+	if got := provider.calls.Load(); got != 1 {
+		t.Errorf("provider called %d times, want 1", got)
+	}
+}
+
+func TestCachingWaiterRetriesAfterCanceledLeader(t *testing.T) {
+	provider := &countingProvider{lookup: func(ctx context.Context, regNum string, call int32) (database.Car, error) {
+		if call == 1 {
+			<-ctx.Done()
+			return database.Car{}, &Error{Provider: "fake", RegNum: regNum, Kind: ErrUpstream, Err: ctx.Err()}
+		}
+		return foundCar(ctx, regNum, call)
+	}}
+	cache := NewCaching(provider, 10, time.Hour, time.Hour)
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := cache.CarInfo(leaderCtx, "A1")
+		leaderErr <- err
+	}()
+	waitFor(t, "the leading lookup", func() bool { return provider.calls.Load() == 1 })
+
+	waiterErr := make(chan error, 1)
+	go func() {
+		_, err := cache.CarInfo(context.Background(), "A1")
+		waiterErr <- err
+	}()
+	waitFor(t, "the waiter to coalesce", func() bool { return cache.CacheStats().Coalesced == 1 })
+	cancelLeader()
+
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("leader error = %v, want context.Canceled", err)
+	}
+	if err := <-waiterErr; err != nil {
+		t.Errorf("waiter inherited the leader's cancellation: %v", err)
+	}
+	if got := provider.calls.Load(); got != 2 {
+		t.Errorf("provider called %d times, want 2", got)
+	}
+	if _, err := cache.CarInfo(context.Background(), "A1"); err != nil || provider.calls.Load() != 2 {
+		t.Errorf("the waiter's answer was not cached: %v, %d calls", err, provider.calls.Load())
+	}
+}
+
+func TestCachingWaiterGivesUpOnItsOwnContext(t *testing.T) {
+	release := make(chan struct{})
+	provider := &countingProvider{lookup: func(ctx context.Context, regNum string, call int32) (database.Car, error) {
+		<-release
+		return foundCar(ctx, regNum, call)
+	}}
+	cache := NewCaching(provider, 10, time.Hour, time.Hour)
+	defer close(release)
+
+	go func() { _, _ = cache.CarInfo(context.Background(), "A1") }()
+	waitFor(t, "the leading lookup", func() bool { return provider.calls.Load() == 1 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := cache.CarInfo(ctx, "A1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+}
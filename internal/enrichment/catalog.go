@@ -0,0 +1,116 @@
+package enrichment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	// catalogRef names the catalog listing in errors in place of a plate.
+	catalogRef = "catalog"
+	// maxCatalogPageSize bounds a catalog page, which may be the whole
+	// catalog if the API does not page.
+	maxCatalogPageSize = 16 << 20
+)
+
+// Catalog is implemented by providers that can list the plates they know,
+// a page at a time. A page shorter than limit is the last one.
+type Catalog interface {
+	RegNums(ctx context.Context, offset, limit int) ([]string, error)
+}
+
+// ErrNoCatalog is returned when no provider in the chain lists its plates.
+var ErrNoCatalog = errors.New("car info provider does not list its plates")
+
+// RegNums pages through GET {baseURL}/all_reg_numbers?offset=&limit=. An API
+// that ignores the paging parameters returns its whole catalog at once.
+func (p *HTTPProvider) RegNums(ctx context.Context, offset, limit int) ([]string, error) {
+	endpoint := p.baseURL.JoinPath("all_reg_numbers")
+	endpoint.RawQuery = url.Values{"offset": {strconv.Itoa(offset)}, "limit": {strconv.Itoa(limit)}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, p.fail(catalogRef, ErrUpstream, 0, err)
+	}
+	req.Header = p.headers.Clone()
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, p.fail(catalogRef, ErrUpstream, 0, err)
+	}
+	defer resp.Body.Close()
+	body := io.LimitReader(resp.Body, maxCatalogPageSize)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var regNums []string
+		if err := json.NewDecoder(body).Decode(&regNums); err != nil {
+			return nil, p.fail(catalogRef, ErrBadPayload, resp.StatusCode, err)
+		}
+		return regNums, nil
+	case http.StatusTooManyRequests:
+		e := p.fail(catalogRef, ErrRateLimited, resp.StatusCode, nil)
+		e.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		return nil, e
+	default:
+		_, _ = io.Copy(io.Discard, body)
+		e := p.fail(catalogRef, statusKind(resp.StatusCode), resp.StatusCode, nil)
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			e.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+		return nil, e
+	}
+}
+
+func (b *Breaker) RegNums(ctx context.Context, offset, limit int) ([]string, error) {
+	catalog, ok := b.provider.(Catalog)
+	if !ok {
+		return nil, ErrNoCatalog
+	}
+	if err := b.allow(); err != nil {
+		return nil, &Error{Provider: b.provider.Name(), RegNum: catalogRef, Kind: ErrUpstream, Err: err}
+	}
+	regNums, err := catalog.RegNums(ctx, offset, limit)
+	b.record(err)
+	return regNums, err
+}
+
+func (r *Retrying) RegNums(ctx context.Context, offset, limit int) ([]string, error) {
+	catalog, ok := r.provider.(Catalog)
+	if !ok {
+		return nil, ErrNoCatalog
+	}
+	var regNums []string
+	err := r.retry(ctx, func() (err error) {
+		regNums, err = catalog.RegNums(ctx, offset, limit)
+		return err
+	})
+	return regNums, err
+}
+
+// RegNums lists the catalog of the highest priority provider that has one;
+// pages from different providers would not line up.
+func (m *Multi) RegNums(ctx context.Context, offset, limit int) ([]string, error) {
+	for _, p := range m.providers {
+		if catalog, ok := p.(Catalog); ok {
+			regNums, err := catalog.RegNums(ctx, offset, limit)
+			if errors.Is(err, ErrNoCatalog) {
+				continue
+			}
+			return regNums, err
+		}
+	}
+	return nil, ErrNoCatalog
+}
+
+func (c *Caching) RegNums(ctx context.Context, offset, limit int) ([]string, error) {
+	if catalog, ok := c.provider.(Catalog); ok {
+		return catalog.RegNums(ctx, offset, limit)
+	}
+	return nil, ErrNoCatalog
+}
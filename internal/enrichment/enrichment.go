@@ -0,0 +1,105 @@
+// Package enrichment fetches the details of a car by its registration number
+// from third-party car info APIs.
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/database"
+	"strings"
+	"time"
+)
+
+// CarInfoProvider looks up a car by its registration number. Failures are
+// reported as *Error values matching one of the sentinel errors below.
+type CarInfoProvider interface {
+	Name() string
+	CarInfo(ctx context.Context, regNum string) (database.Car, error)
+}
+
+var (
+	ErrNotFound    = errors.New("car with registration number not found")
+	ErrRateLimited = errors.New("car info provider rate limit exceeded")
+	ErrUpstream    = errors.New("car info provider failed")
+	ErrBadPayload  = errors.New("car info provider returned invalid car data")
+	// ErrRejected is a 4xx answer other than 404 and 429, such as a bad auth
+	// token: asking again will not help.
+	ErrRejected = errors.New("car info provider rejected the request")
+)
+
+// statusKind classifies an unexpected answer: 4xx are rejections, anything
+// else an upstream failure.
+func statusKind(status int) error {
+	if status >= 400 && status < 500 {
+		return ErrRejected
+	}
+	return ErrUpstream
+}
+
+// Error describes a failed lookup. Kind is one of the sentinel errors and is
+// matched by errors.Is, as is the underlying cause.
+type Error struct {
+	Provider   string
+	RegNum     string
+	Kind       error
+	StatusCode int
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s: %s: %v", e.Provider, e.RegNum, e.Kind)
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.StatusCode)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// Validate checks a car returned for regNum before it is stored. A missing
+// plate is filled in from the request; every other field is required.
+func Validate(regNum string, car *database.Car) error {
+	if err := checkConsistent(regNum, car); err != nil {
+		return err
+	}
+	if car.RegNum == "" {
+		car.RegNum = regNum
+	}
+	if car.Mark == "" || car.Model == "" {
+		return errors.New("mark and model are required")
+	}
+	if car.Year == 0 {
+		return errors.New("year is required")
+	}
+	if car.Owner.Name == "" || car.Owner.Surname == "" {
+		return errors.New("owner name and surname are required")
+	}
+	return nil
+}
+
+// checkConsistent checks the fields a provider did supply, trimming them.
+// Missing fields are left for another provider to fill in.
+func checkConsistent(regNum string, car *database.Car) error {
+	car.RegNum = strings.TrimSpace(car.RegNum)
+	if car.RegNum != "" && !strings.EqualFold(car.RegNum, regNum) {
+		return fmt.Errorf("registration number %q does not match the requested %q", car.RegNum, regNum)
+	}
+	car.Mark = strings.TrimSpace(car.Mark)
+	car.Model = strings.TrimSpace(car.Model)
+	if maxYear := time.Now().Year() + 1; car.Year != 0 && (car.Year < 1886 || car.Year > maxYear) {
+		return fmt.Errorf("year %d is out of range", car.Year)
+	}
+	car.Owner.Name = strings.TrimSpace(car.Owner.Name)
+	car.Owner.Surname = strings.TrimSpace(car.Owner.Surname)
+	return nil
+}
@@ -0,0 +1,179 @@
+package enrichment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/config"
+	"github.com/likimiad/car-management-api/internal/database"
+	"github.com/likimiad/car-management-api/internal/metrics"
+	"github.com/likimiad/car-management-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// maxPayloadSize bounds how much of an upstream response is read.
+const maxPayloadSize = 1 << 20
+
+// HTTPProvider queries a car info API serving GET {baseURL}/info?regNum=.
+// The answer may be partial; completeness is checked by Multi.
+type HTTPProvider struct {
+	name    string
+	baseURL *url.URL
+	client  *http.Client
+	headers http.Header
+}
+
+func NewHTTPProvider(name, baseURL string, cfg config.EnrichmentConfig) (*HTTPProvider, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid car info provider URL %q", baseURL)
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   cfg.Timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxConnsPerHost,
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+	}
+
+	headers := http.Header{}
+	headers.Set("Accept", "application/json")
+	for key, value := range cfg.Headers {
+		headers.Set(key, value)
+	}
+	if cfg.AuthToken != "" {
+		headers.Set("Authorization", "Bearer "+cfg.AuthToken)
+	}
+
+	return &HTTPProvider{
+		name:    name,
+		baseURL: u,
+		client:  &http.Client{Transport: transport, Timeout: cfg.Timeout},
+		headers: headers,
+	}, nil
+}
+
+func (p *HTTPProvider) Name() string {
+	return p.name
+}
+
+func (p *HTTPProvider) CarInfo(ctx context.Context, regNum string) (database.Car, error) {
+	ctx, span := tracing.Start(ctx, "GET /info", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("enrichment.provider", p.name),
+		attribute.String("car.reg_num", regNum),
+	))
+	defer span.End()
+
+	start := time.Now()
+	car, err := p.carInfo(ctx, regNum)
+	outcome := callOutcome(ctx, err)
+	metrics.UpstreamRequests.WithLabelValues(p.name, outcome).Inc()
+	metrics.UpstreamRequestDuration.WithLabelValues(p.name, outcome).Observe(time.Since(start).Seconds())
+
+	span.SetAttributes(attribute.String("enrichment.outcome", outcome))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		tracing.Fail(span, err)
+	}
+	return car, err
+}
+
+func (p *HTTPProvider) carInfo(ctx context.Context, regNum string) (database.Car, error) {
+	endpoint := p.baseURL.JoinPath("info")
+	endpoint.RawQuery = url.Values{"regNum": {regNum}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return database.Car{}, p.fail(regNum, ErrUpstream, 0, err)
+	}
+	req.Header = p.headers.Clone()
+	tracing.Inject(ctx, req.Header)
+	trace.SpanFromContext(ctx).SetAttributes(semconv.HTTPRequestMethodKey.String(http.MethodGet), semconv.URLFull(endpoint.String()))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return database.Car{}, p.fail(regNum, ErrUpstream, 0, err)
+	}
+	defer resp.Body.Close()
+	trace.SpanFromContext(ctx).SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	body := io.LimitReader(resp.Body, maxPayloadSize)
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		var car database.Car
+		if err := json.NewDecoder(body).Decode(&car); err != nil {
+			return database.Car{}, p.fail(regNum, ErrBadPayload, resp.StatusCode, err)
+		}
+		if err := checkConsistent(regNum, &car); err != nil {
+			return database.Car{}, p.fail(regNum, ErrBadPayload, resp.StatusCode, err)
+		}
+		return car, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return database.Car{}, p.fail(regNum, ErrNotFound, resp.StatusCode, nil)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		e := p.fail(regNum, ErrRateLimited, resp.StatusCode, nil)
+		e.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		return database.Car{}, e
+	default:
+		_, _ = io.Copy(io.Discard, body)
+		e := p.fail(regNum, statusKind(resp.StatusCode), resp.StatusCode, nil)
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			e.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+		return database.Car{}, e
+	}
+}
+
+// callOutcome names the outcome of an upstream call for the metrics.
+func callOutcome(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case ctx.Err() != nil:
+		return "canceled"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrBadPayload):
+		return "bad_payload"
+	case errors.Is(err, ErrRejected):
+		return "rejected"
+	default:
+		return "upstream_error"
+	}
+}
+
+func (p *HTTPProvider) fail(regNum string, kind error, status int, err error) *Error {
+	return &Error{Provider: p.name, RegNum: regNum, Kind: kind, StatusCode: status, Err: err}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date, returning zero when it is absent or malformed.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
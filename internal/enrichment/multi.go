@@ -0,0 +1,181 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/database"
+	"strings"
+	"sync"
+)
+
+const (
+	// StrategyFallback returns the first complete answer, asking the
+	// providers in order.
+	StrategyFallback = "fallback"
+	// StrategyMerge asks every provider at once and takes each field from
+	// the highest priority provider that supplied it.
+	StrategyMerge = "merge"
+)
+
+// Multi combines an ordered list of providers, the first having the highest
+// priority. The car it returns is complete and records in Provenance which
+// provider supplied each field.
+type Multi struct {
+	providers []CarInfoProvider
+	strategy  string
+}
+
+func NewMulti(strategy string, providers ...CarInfoProvider) (*Multi, error) {
+	if strategy != StrategyFallback && strategy != StrategyMerge {
+		return nil, fmt.Errorf("unknown enrichment strategy %q", strategy)
+	}
+	if len(providers) == 0 {
+		return nil, errors.New("no car info providers configured")
+	}
+	return &Multi{providers: providers, strategy: strategy}, nil
+}
+
+func (m *Multi) Name() string {
+	names := make([]string, len(m.providers))
+	for i, p := range m.providers {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+func (m *Multi) CarInfo(ctx context.Context, regNum string) (database.Car, error) {
+	if m.strategy == StrategyMerge {
+		return m.merge(ctx, regNum)
+	}
+	return m.fallback(ctx, regNum)
+}
+
+func (m *Multi) Health() []BreakerState {
+	var states []BreakerState
+	for _, p := range m.providers {
+		if h, ok := p.(HealthReporter); ok {
+			states = append(states, h.Health()...)
+		}
+	}
+	return states
+}
+
+func (m *Multi) Probe(ctx context.Context) []ProbeResult {
+	var results []ProbeResult
+	for _, p := range m.providers {
+		if pr, ok := p.(Prober); ok {
+			results = append(results, pr.Probe(ctx)...)
+		}
+	}
+	return results
+}
+
+func (m *Multi) fallback(ctx context.Context, regNum string) (database.Car, error) {
+	errs := make([]error, 0, len(m.providers))
+	for _, p := range m.providers {
+		car, err := p.CarInfo(ctx, regNum)
+		if err == nil {
+			if err = Validate(regNum, &car); err == nil {
+				car.Provenance = database.Provenance{}
+				supply(&car, car, p.Name())
+				return car, nil
+			}
+			err = &Error{Provider: p.Name(), RegNum: regNum, Kind: ErrBadPayload, Err: err}
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return database.Car{}, m.combine(regNum, errs)
+}
+
+func (m *Multi) merge(ctx context.Context, regNum string) (database.Car, error) {
+	cars := make([]database.Car, len(m.providers))
+	errs := make([]error, len(m.providers))
+	var wg sync.WaitGroup
+	for i, p := range m.providers {
+		wg.Add(1)
+		go func(i int, p CarInfoProvider) {
+			defer wg.Done()
+			cars[i], errs[i] = p.CarInfo(ctx, regNum)
+		}(i, p)
+	}
+	wg.Wait()
+
+	merged := database.Car{Provenance: database.Provenance{}}
+	var failed []error
+	for i, p := range m.providers {
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+			continue
+		}
+		supply(&merged, cars[i], p.Name())
+	}
+	if len(merged.Provenance) == 0 {
+		return database.Car{}, m.combine(regNum, failed)
+	}
+	if err := Validate(regNum, &merged); err != nil {
+		return database.Car{}, &Error{Provider: m.Name(), RegNum: regNum, Kind: ErrBadPayload, Err: err}
+	}
+	return merged, nil
+}
+
+// supply fills the fields of dst that are still empty from src, recording
+// the provider in the provenance of dst.
+func supply(dst *database.Car, src database.Car, provider string) {
+	fill := func(field string, empty bool, set func()) {
+		if _, ok := dst.Provenance[field]; !ok && !empty {
+			set()
+			dst.Provenance[field] = provider
+		}
+	}
+	fill("regNum", src.RegNum == "", func() { dst.RegNum = src.RegNum })
+	fill("mark", src.Mark == "", func() { dst.Mark = src.Mark })
+	fill("model", src.Model == "", func() { dst.Model = src.Model })
+	fill("year", src.Year == 0, func() { dst.Year = src.Year })
+	fill("owner.name", src.Owner.Name == "", func() { dst.Owner.Name = src.Owner.Name })
+	fill("owner.surname", src.Owner.Surname == "", func() { dst.Owner.Surname = src.Owner.Surname })
+	fill("owner.patronymic", src.Owner.Patronymic == nil || *src.Owner.Patronymic == "", func() {
+		patronymic := *src.Owner.Patronymic
+		dst.Owner.Patronymic = &patronymic
+	})
+}
+
+// combine reports the failure of every provider as one error. It is not
+// found only if every provider said so; otherwise the upstream failure wins,
+// so that a plate is not reported missing because a provider was down.
+func (m *Multi) combine(regNum string, errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	kind, cause := ErrNotFound, error(nil)
+	allOpen := len(errs) > 0
+	for _, err := range errs {
+		switch {
+		case errors.Is(err, ErrNotFound):
+		case errors.Is(err, ErrUpstream):
+			kind = ErrUpstream
+		case errors.Is(err, ErrRateLimited) && kind != ErrUpstream:
+			kind = ErrRateLimited
+		case errors.Is(err, ErrRejected) && (kind == ErrNotFound || kind == ErrBadPayload):
+			kind = ErrRejected
+		case kind == ErrNotFound:
+			kind = ErrBadPayload
+		}
+		allOpen = allOpen && errors.Is(err, ErrCircuitOpen)
+	}
+	if allOpen {
+		cause = ErrCircuitOpen
+	}
+
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	if cause == nil && len(messages) > 0 {
+		cause = errors.New(strings.Join(messages, "; "))
+	}
+	return &Error{Provider: m.Name(), RegNum: regNum, Kind: kind, Err: cause}
+}
@@ -0,0 +1,55 @@
+package enrichment
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ProbeResult tells whether a provider's API answered a probe.
+type ProbeResult struct {
+	Provider  string `json:"provider"`
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Prober is implemented by providers that can check that their upstream
+// answers at all, whatever the state of their circuit breakers.
+type Prober interface {
+	Probe(ctx context.Context) []ProbeResult
+}
+
+// Probe sends a lookup without a plate to the API. Any answer short of a
+// server error or a refusal of the credentials counts as reachable, a
+// rejection of the missing plate included.
+func (p *HTTPProvider) Probe(ctx context.Context) []ProbeResult {
+	result := ProbeResult{Provider: p.name}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL.JoinPath("info").String(), nil)
+	if err != nil {
+		result.Error = err.Error()
+		return []ProbeResult{result}
+	}
+	req.Header = p.headers.Clone()
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return []ProbeResult{result}
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxPayloadSize))
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden:
+		// Every lookup would be refused just the same.
+		result.Error = fmt.Sprintf("upstream refused the credentials: %d", resp.StatusCode)
+		return []ProbeResult{result}
+	case resp.StatusCode >= http.StatusInternalServerError:
+		result.Error = fmt.Sprintf("upstream answered %d", resp.StatusCode)
+		return []ProbeResult{result}
+	}
+	result.Reachable = true
+	return []ProbeResult{result}
+}
@@ -0,0 +1,46 @@
+package enrichment
+
+import (
+	"context"
+	"github.com/likimiad/car-management-api/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProbeByStatus(t *testing.T) {
+	tests := []struct {
+		status    int
+		reachable bool
+	}{
+		{http.StatusOK, true},
+		{http.StatusBadRequest, true},
+		{http.StatusNotFound, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusUnauthorized, false},
+		{http.StatusForbidden, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusServiceUnavailable, false},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer upstream.Close()
+
+			provider, err := NewHTTPProvider("test", upstream.URL, config.EnrichmentConfig{Timeout: time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := provider.Probe(context.Background())[0]
+			if result.Reachable != tt.reachable {
+				t.Errorf("reachable = %t, want %t", result.Reachable, tt.reachable)
+			}
+			if !result.Reachable && result.Error == "" {
+				t.Error("unreachable probe has no error")
+			}
+		})
+	}
+}
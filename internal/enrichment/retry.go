@@ -0,0 +1,117 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"github.com/likimiad/car-management-api/internal/database"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy controls how failed lookups are retried. Attempts counts the
+// retries after the first call; a Retry-After longer than MaxBackoff is not
+// waited for and the error is returned as is.
+type RetryPolicy struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Retrying retries lookups that failed for a transient reason: an upstream
+// 5xx, a network error or timeout, or a 429. Not found, bad payload and
+// other 4xx answers are final.
+type Retrying struct {
+	provider CarInfoProvider
+	policy   RetryPolicy
+}
+
+func NewRetrying(provider CarInfoProvider, policy RetryPolicy) *Retrying {
+	return &Retrying{provider: provider, policy: policy}
+}
+
+func (r *Retrying) Name() string {
+	return r.provider.Name()
+}
+
+func (r *Retrying) CarInfo(ctx context.Context, regNum string) (database.Car, error) {
+	var car database.Car
+	err := r.retry(ctx, func() (err error) {
+		car, err = r.provider.CarInfo(ctx, regNum)
+		return err
+	})
+	return car, err
+}
+
+// retry runs call until it succeeds, fails for good or runs out of attempts,
+// returning its last error.
+func (r *Retrying) retry(ctx context.Context, call func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= r.policy.Attempts || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		wait := r.backoff(attempt)
+		var e *Error
+		if errors.As(err, &e) && e.RetryAfter > 0 {
+			if e.RetryAfter > r.policy.MaxBackoff {
+				return err
+			}
+			wait = max(wait, e.RetryAfter)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *Retrying) Health() []BreakerState {
+	if h, ok := r.provider.(HealthReporter); ok {
+		return h.Health()
+	}
+	return nil
+}
+
+func (r *Retrying) Probe(ctx context.Context) []ProbeResult {
+	if p, ok := r.provider.(Prober); ok {
+		return p.Probe(ctx)
+	}
+	return nil
+}
+
+// backoff doubles the base delay with every attempt up to MaxBackoff and
+// picks a random point in its upper half, so that concurrent workers do not
+// retry in lockstep.
+func (r *Retrying) backoff(attempt int) time.Duration {
+	d := r.policy.Backoff << attempt
+	if d <= 0 || d > r.policy.MaxBackoff {
+		d = r.policy.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryable tells transient failures, a 429 or an upstream failure that is a
+// network error, a timeout or a 5xx, from final ones. It also decides which
+// failures count against a circuit breaker.
+func retryable(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	if errors.Is(err, ErrRateLimited) {
+		return true
+	}
+	var e *Error
+	if !errors.Is(err, ErrUpstream) || !errors.As(err, &e) {
+		return false
+	}
+	return e.StatusCode == 0 || e.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,68 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"github.com/likimiad/car-management-api/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryingByStatus(t *testing.T) {
+	tests := []struct {
+		status    int
+		kind      error
+		calls     int32
+		breakerUp bool
+	}{
+		{http.StatusNotFound, ErrNotFound, 1, true},
+		{http.StatusBadRequest, ErrRejected, 1, true},
+		{http.StatusUnauthorized, ErrRejected, 1, true},
+		{http.StatusForbidden, ErrRejected, 1, true},
+		{http.StatusTooManyRequests, ErrRateLimited, 3, false},
+		{http.StatusInternalServerError, ErrUpstream, 3, false},
+		{http.StatusBadGateway, ErrUpstream, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			var calls atomic.Int32
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer upstream.Close()
+
+			provider, err := NewHTTPProvider("test", upstream.URL, config.EnrichmentConfig{Timeout: time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+			breaker := NewBreaker(provider, 3, time.Minute)
+			retrying := NewRetrying(breaker, RetryPolicy{Attempts: 2, MaxBackoff: time.Millisecond})
+
+			_, err = retrying.CarInfo(context.Background(), "A123AA150")
+			if !errors.Is(err, tt.kind) {
+				t.Errorf("error = %v, want %v", err, tt.kind)
+			}
+			if got := calls.Load(); got != tt.calls {
+				t.Errorf("upstream called %d times, want %d", got, tt.calls)
+			}
+			if closed := breaker.Health()[0].State == BreakerClosed; closed != tt.breakerUp {
+				t.Errorf("breaker closed = %t, want %t", closed, tt.breakerUp)
+			}
+		})
+	}
+}
+
+func TestRetryableTransportError(t *testing.T) {
+	provider, err := NewHTTPProvider("test", "http://127.0.0.1:1", config.EnrichmentConfig{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = provider.CarInfo(context.Background(), "A123AA150")
+	if !retryable(err) {
+		t.Errorf("transport error %v is not retryable", err)
+	}
+}
@@ -0,0 +1,53 @@
+package enrichment
+
+import (
+	"errors"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/config"
+	"strings"
+)
+
+// NewFromConfig builds the provider chain used by the server: every
+// configured API behind its own circuit breaker and retries, combined with
+// the configured strategy, behind the lookup cache.
+//
+// APIs are taken from HTTP_THIRD_PARTY_APIS as name=url items in priority
+// order, falling back to the single HTTP_THIRD_PARTY_API_URL.
+func NewFromConfig(httpCfg config.HTTPServer, cfg config.EnrichmentConfig) (*Caching, error) {
+	apis := httpCfg.ThirdPartyAPIs
+	if len(apis) == 0 && httpCfg.ThirdPartyAPIURL != "" {
+		apis = []string{"default=" + httpCfg.ThirdPartyAPIURL}
+	}
+	if len(apis) == 0 {
+		return nil, errors.New("no third party API configured")
+	}
+
+	providers := make([]CarInfoProvider, 0, len(apis))
+	seen := map[string]bool{}
+	for _, api := range apis {
+		name, baseURL, ok := strings.Cut(strings.TrimSpace(api), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("third party API %q is not in name=url form", api)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("third party API %q is configured twice", name)
+		}
+		seen[name] = true
+
+		provider, err := NewHTTPProvider(name, baseURL, cfg)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, NewRetrying(NewBreaker(provider, httpCfg.BreakerThreshold, httpCfg.BreakerCooldown), RetryPolicy{
+			Attempts:   httpCfg.UpstreamRetries,
+			Backoff:    httpCfg.UpstreamBackoff,
+			MaxBackoff: httpCfg.UpstreamMaxBackoff,
+		}))
+	}
+
+	multi, err := NewMulti(httpCfg.EnrichStrategy, providers...)
+	if err != nil {
+		return nil, err
+	}
+	return NewCaching(multi, cfg.CacheSize, cfg.CacheTTL, cfg.CacheNegativeTTL), nil
+}
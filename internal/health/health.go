@@ -0,0 +1,115 @@
+// Package health runs the dependency checks behind the readiness endpoint.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+	// StatusDegraded is reported when only non-critical checks fail: the
+	// service stays ready with reduced functionality.
+	StatusDegraded = "degraded"
+)
+
+// Check tests one dependency, returning details worth showing either way and
+// an error if the dependency does not work.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (any, error)
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+	Detail   any    `json:"detail,omitempty"`
+}
+
+// Report sums the checks up: down if a critical check failed or the service
+// is shutting down, degraded if another one failed, up otherwise.
+type Report struct {
+	Status   string   `json:"status"`
+	Draining bool     `json:"draining,omitempty"`
+	Checks   []Result `json:"checks"`
+}
+
+// Checker runs the checks concurrently, each within Timeout.
+type Checker struct {
+	Timeout  time.Duration
+	checks   []Check
+	critical map[string]bool
+	draining atomic.Bool
+}
+
+// NewChecker returns a checker running the checks, the named ones being
+// critical.
+func NewChecker(timeout time.Duration, critical []string, checks ...Check) *Checker {
+	c := &Checker{Timeout: timeout, checks: checks, critical: map[string]bool{}}
+	for _, name := range critical {
+		c.critical[name] = true
+	}
+	return c
+}
+
+// Drain makes every later report down, so that the service is taken out of
+// rotation while it shuts down.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Ready runs the checks. A draining checker reports down at once.
+func (c *Checker) Ready(ctx context.Context) Report {
+	if c.draining.Load() {
+		return Report{Status: StatusDown, Draining: true, Checks: []Result{}}
+	}
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: results}
+	for _, result := range results {
+		switch {
+		case result.Status == StatusUp:
+		case result.Critical:
+			report.Status = StatusDown
+		case report.Status == StatusUp:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := check.Run(ctx)
+	result := Result{
+		Name:     check.Name,
+		Status:   StatusUp,
+		Critical: c.critical[check.Name],
+		Duration: time.Since(start).String(),
+		Detail:   detail,
+	}
+	if err != nil {
+		result.Status, result.Error = StatusDown, err.Error()
+	}
+	return result
+}
@@ -0,0 +1,97 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func up(ctx context.Context) (any, error) { return "ok", nil }
+
+func down(ctx context.Context) (any, error) { return nil, errors.New("unreachable") }
+
+// hang waits for its context, as a check against a stalled dependency does.
+func hang(ctx context.Context) (any, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCheckerReady(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks []Check
+		want   string
+	}{
+		{"no checks", nil, StatusUp},
+		{"all up", []Check{{"db", up}, {"upstream", up}}, StatusUp},
+		{"non-critical down", []Check{{"db", up}, {"upstream", down}}, StatusDegraded},
+		{"critical down", []Check{{"db", down}, {"upstream", up}}, StatusDown},
+		{"critical down wins over degraded", []Check{{"upstream", down}, {"db", down}}, StatusDown},
+		{"critical check times out", []Check{{"db", hang}, {"upstream", up}}, StatusDown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(20*time.Millisecond, []string{"db"}, tt.checks...)
+			report := checker.Ready(context.Background())
+			if report.Status != tt.want {
+				t.Errorf("status = %s, want %s: %+v", report.Status, tt.want, report.Checks)
+			}
+			if len(report.Checks) != len(tt.checks) {
+				t.Fatalf("got %d results, want %d", len(report.Checks), len(tt.checks))
+			}
+			for i, result := range report.Checks {
+				if result.Name != tt.checks[i].Name {
+					t.Errorf("results[%d] = %s, want %s in check order", i, result.Name, tt.checks[i].Name)
+				}
+				if result.Critical != (result.Name == "db") {
+					t.Errorf("%s critical = %v", result.Name, result.Critical)
+				}
+				if (result.Status == StatusUp) != (result.Error == "") {
+					t.Errorf("%s = %s with error %q", result.Name, result.Status, result.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckerReportsDetailAndError(t *testing.T) {
+	checker := NewChecker(time.Second, nil, Check{"upstream", up}, Check{"cache", down})
+	report := checker.Ready(context.Background())
+	if got := report.Checks[0]; got.Status != StatusUp || got.Detail != "ok" || got.Duration == "" {
+		t.Errorf("up check = %+v", got)
+	}
+	if got := report.Checks[1]; got.Status != StatusDown || got.Error != "unreachable" {
+		t.Errorf("down check = %+v", got)
+	}
+}
+
+func TestCheckerRunsChecksConcurrently(t *testing.T) {
+	checker := NewChecker(50*time.Millisecond, nil, Check{"a", hang}, Check{"b", hang}, Check{"c", hang})
+	start := time.Now()
+	report := checker.Ready(context.Background())
+	if elapsed := time.Since(start); elapsed > 140*time.Millisecond {
+		t.Errorf("three timed out checks took %s, want about one timeout", elapsed)
+	}
+	for _, result := range report.Checks {
+		if result.Error != context.DeadlineExceeded.Error() {
+			t.Errorf("%s error = %q, want the timeout", result.Name, result.Error)
+		}
+	}
+}
+
+func TestCheckerDrain(t *testing.T) {
+	ran := false
+	checker := NewChecker(time.Second, nil, Check{"db", func(ctx context.Context) (any, error) {
+		ran = true
+		return nil, nil
+	}})
+	checker.Drain()
+	report := checker.Ready(context.Background())
+	if report.Status != StatusDown || !report.Draining || len(report.Checks) != 0 {
+		t.Errorf("draining report = %+v, want down with no checks", report)
+	}
+	if ran {
+		t.Error("a draining checker ran its checks")
+	}
+}
@@ -0,0 +1,266 @@
+package imports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/config"
+	"github.com/likimiad/car-management-api/internal/database"
+	"github.com/likimiad/car-management-api/internal/enrichment"
+	"github.com/likimiad/car-management-api/internal/logging"
+	"github.com/likimiad/car-management-api/internal/metrics"
+	"github.com/likimiad/car-management-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"sync"
+)
+
+var ErrTooManyPlates = errors.New("too many plates in one import")
+
+// Importer runs import jobs in the background. Every job walks its pending
+// items in batches; the plates of all jobs share one pool of workers so that
+// concurrent jobs do not multiply the load on the upstream API. Item results
+// are stored as they complete, so a job stopped by a restart or a cancel
+// picks up where it left off.
+type Importer struct {
+	ctx       context.Context
+	store     database.Store
+	provider  enrichment.CarInfoProvider
+	batchSize int
+	maxPlates int
+	workers   chan struct{}
+
+	cancel context.CancelFunc
+	jobs   sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[int]context.CancelFunc
+}
+
+// NewImporter returns an importer whose jobs run until ctx is cancelled or
+// the importer is shut down.
+func NewImporter(ctx context.Context, store database.Store, provider enrichment.CarInfoProvider, cfg config.ImportConfig) *Importer {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Importer{
+		ctx:       ctx,
+		cancel:    cancel,
+		store:     store,
+		provider:  provider,
+		batchSize: max(cfg.BatchSize, 1),
+		maxPlates: cfg.MaxPlates,
+		workers:   make(chan struct{}, max(cfg.Workers, 1)),
+		running:   make(map[int]context.CancelFunc),
+	}
+}
+
+// ResumeUnfinished restarts the jobs left queued or running by a previous
+// process.
+func (im *Importer) ResumeUnfinished() error {
+	jobs, err := im.store.UnfinishedImportJobs(im.ctx)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		logger().Info("resuming import job", "job", job.ID)
+		im.start(job)
+	}
+	return nil
+}
+
+// Submit stores a job for the normalized plates and starts it; spelled holds
+// the plates as submitted, asked of the upstream when it does not know the
+// normalized ones. The job is attributed to the actor and request of ctx.
+func (im *Importer) Submit(ctx context.Context, regNums, spelled []string) (*database.ImportJob, error) {
+	if im.maxPlates > 0 && len(regNums) > im.maxPlates {
+		return nil, ErrTooManyPlates
+	}
+	job, err := im.store.CreateImportJob(ctx, regNums, spelled)
+	if err != nil {
+		return nil, err
+	}
+	im.start(*job)
+	return job, nil
+}
+
+// Cancel stops a queued or running job; items already processed keep their
+// results and the rest stay pending until the job is resumed.
+func (im *Importer) Cancel(ctx context.Context, id int) error {
+	err := im.store.SetImportJobState(ctx, id, []string{database.ImportQueued, database.ImportRunning}, database.ImportCanceled, "")
+	if err != nil {
+		return err
+	}
+	im.mu.Lock()
+	if cancel, ok := im.running[id]; ok {
+		cancel()
+	}
+	im.mu.Unlock()
+	return nil
+}
+
+// Resume restarts a cancelled or failed job from its pending items.
+func (im *Importer) Resume(ctx context.Context, id int) error {
+	im.mu.Lock()
+	_, stopping := im.running[id]
+	im.mu.Unlock()
+	if stopping {
+		return database.ErrImportJobState
+	}
+
+	err := im.store.SetImportJobState(ctx, id, []string{database.ImportCanceled, database.ImportFailed}, database.ImportQueued, "")
+	if err != nil {
+		return err
+	}
+	job, err := im.store.GetImportJob(ctx, id)
+	if err != nil {
+		return err
+	}
+	im.start(*job)
+	return nil
+}
+
+// Shutdown stops every job and waits for them to wind down or for ctx to
+// expire. Stopped jobs stay running in the store and are picked up by
+// ResumeUnfinished on the next start.
+func (im *Importer) Shutdown(ctx context.Context) error {
+	im.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		im.jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("error stopping import jobs: %w", ctx.Err())
+	}
+}
+
+func (im *Importer) start(job database.ImportJob) {
+	ctx, cancel := context.WithCancel(database.WithAuditMeta(im.ctx, job.Actor, job.RequestID))
+
+	im.mu.Lock()
+	im.running[job.ID] = cancel
+	im.mu.Unlock()
+
+	im.jobs.Add(1)
+	go func() {
+		defer im.jobs.Done()
+		defer func() {
+			im.mu.Lock()
+			delete(im.running, job.ID)
+			im.mu.Unlock()
+			cancel()
+		}()
+		im.process(ctx, job.ID)
+	}()
+}
+
+func (im *Importer) process(ctx context.Context, id int) {
+	running := []string{database.ImportRunning}
+	err := im.store.SetImportJobState(ctx, id, []string{database.ImportQueued, database.ImportRunning}, database.ImportRunning, "")
+	if errors.Is(err, database.ErrImportJobState) {
+		// Cancelled before it started.
+		return
+	} else if err != nil {
+		im.logError(id, err)
+		return
+	}
+
+	err = im.processPending(ctx, id)
+	if ctx.Err() != nil {
+		// Cancelled, or shutting down with the job left running to resume.
+		return
+	}
+	state, errMsg := database.ImportCompleted, ""
+	if err != nil {
+		im.logError(id, err)
+		state, errMsg = database.ImportFailed, err.Error()
+	}
+	if err = im.store.SetImportJobState(ctx, id, running, state, errMsg); err != nil && !errors.Is(err, database.ErrImportJobState) {
+		im.logError(id, err)
+		return
+	}
+
+	if job, err := im.store.GetImportJob(ctx, id); err == nil {
+		logger().Info("import job finished", "job", id, "state", job.State,
+			"created", job.Counts[database.ItemCreated], "existing", job.Counts[database.ItemExisting],
+			"notFound", job.Counts[database.ItemNotFound], "failed", job.Counts[database.ItemFailed])
+	}
+}
+
+// processPending imports the pending items of a job a batch at a time,
+// returning the first error met while storing results.
+func (im *Importer) processPending(ctx context.Context, id int) error {
+	filter := database.ImportItemFilter{Statuses: []string{database.ItemPending}, Limit: im.batchSize}
+	for {
+		items, err := im.store.GridImportItems(ctx, id, filter)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var storeErr error
+		for _, item := range items {
+			select {
+			case <-ctx.Done():
+			case im.workers <- struct{}{}:
+				wg.Add(1)
+				go func(item database.ImportItem) {
+					defer func() {
+						<-im.workers
+						wg.Done()
+					}()
+					if err := im.importItem(ctx, id, item); err != nil {
+						mu.Lock()
+						storeErr = errors.Join(storeErr, err)
+						mu.Unlock()
+					}
+				}(item)
+			}
+		}
+		wg.Wait()
+
+		if storeErr != nil || ctx.Err() != nil {
+			return storeErr
+		}
+		filter.From = items[len(items)-1].Position + 1
+	}
+}
+
+// importItem imports one plate and stores the outcome. A lookup cut short by
+// the job being stopped leaves the item pending.
+func (im *Importer) importItem(ctx context.Context, id int, item database.ImportItem) error {
+	ctx, span := tracing.Start(ctx, "import plate", trace.WithAttributes(
+		attribute.Int("import.job", id),
+		attribute.String("car.reg_num", item.RegNum),
+	))
+	defer span.End()
+
+	result, err := ImportPlate(ctx, im.store, im.provider, item.RegNum, item.Spelled)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		logger().Debug("plate import failed", "job", id, "regNum", item.RegNum, logging.Err(err))
+		tracing.Fail(span, err)
+	}
+	span.SetAttributes(attribute.String("plate.status", result.Status))
+	metrics.Plates.WithLabelValues(metrics.SourceImport, result.Status).Inc()
+	result.Position = item.Position
+	return im.store.SetImportItemResult(ctx, id, result)
+}
+
+func (im *Importer) logError(id int, err error) {
+	logger().Error("import job failed", "job", id, logging.Err(err))
+}
+
+func logger() *slog.Logger {
+	return slog.Default().With("component", "import")
+}
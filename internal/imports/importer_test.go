@@ -0,0 +1,237 @@
+package imports
+
+import (
+	"context"
+	"errors"
+	"github.com/likimiad/car-management-api/internal/config"
+	"github.com/likimiad/car-management-api/internal/database"
+	"github.com/likimiad/car-management-api/internal/enrichment"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var vesta = database.Car{Mark: "Lada", Model: "Vesta", Year: 2020, Owner: database.Owner{Name: "Ivan", Surname: "Petrov"}}
+
+// fakeProvider answers the plates found in cars, keyed as asked, and records
+// every plate it is asked for. fail, when set, is returned for every plate;
+// with block, lookups wait until it is closed or the context is done.
+type fakeProvider struct {
+	mu    sync.Mutex
+	cars  map[string]database.Car
+	fail  error
+	block chan struct{}
+	asked []string
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) CarInfo(ctx context.Context, regNum string) (database.Car, error) {
+	p.mu.Lock()
+	p.asked = append(p.asked, regNum)
+	p.mu.Unlock()
+	if p.block != nil {
+		select {
+		case <-p.block:
+		case <-ctx.Done():
+			return database.Car{}, ctx.Err()
+		}
+	}
+	if p.fail != nil {
+		return database.Car{}, &enrichment.Error{Provider: p.Name(), RegNum: regNum, Kind: p.fail}
+	}
+	car, ok := p.cars[regNum]
+	if !ok {
+		return database.Car{}, &enrichment.Error{Provider: p.Name(), RegNum: regNum, Kind: enrichment.ErrNotFound}
+	}
+	car.RegNum = regNum
+	return car, nil
+}
+
+func (p *fakeProvider) askedFor() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.asked)
+}
+
+// seededStore holds an active car A111AA150 and a soft-deleted B222BB150.
+func seededStore(t *testing.T) *database.MemoryStore {
+	t.Helper()
+	store := database.NewMemoryStore()
+	ctx := context.Background()
+	ownerID, err := store.GetOrCreateOwner(ctx, vesta.Owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, regNum := range []string{"A111AA150", "B222BB150"} {
+		if _, err := store.AddNewCar(ctx, regNum, vesta.Mark, vesta.Model, vesta.Year, ownerID, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.DeleteCar(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// waitForJob polls the job until it reaches state, failing the test after a
+// few seconds.
+func waitForJob(t *testing.T, store database.Store, id int, state string) *database.ImportJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := store.GetImportJob(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.State == state {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d is %s, want %s", id, job.State, state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func shutdown(t *testing.T, im *Importer) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := im.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImporterRunsJob(t *testing.T) {
+	store := seededStore(t)
+	provider := &fakeProvider{cars: map[string]database.Car{"X123XX150": vesta, "A111AA150": vesta, "B222BB150": vesta, "E555EE150": vesta}}
+	im := NewImporter(context.Background(), store, provider, config.ImportConfig{Workers: 2, BatchSize: 2})
+	defer shutdown(t, im)
+
+	regNums := []string{"X123XX150", "A111AA150", "H777HH150", "B222BB150", "E555EE150"}
+	job, err := im.Submit(context.Background(), regNums, regNums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Total != len(regNums) {
+		t.Errorf("total = %d, want %d", job.Total, len(regNums))
+	}
+
+	done := waitForJob(t, store, job.ID, database.ImportCompleted)
+	want := map[string]int{database.ItemCreated: 2, database.ItemExisting: 1, database.ItemNotFound: 1, database.ItemFailed: 1}
+	if !maps.Equal(done.Counts, want) {
+		t.Errorf("counts = %v, want %v", done.Counts, want)
+	}
+	if done.Processed != len(regNums) || done.FinishedAt == nil {
+		t.Errorf("job = %+v, want every plate processed and a finish time", done)
+	}
+
+	items, err := store.GridImportItems(context.Background(), job.ID, database.ImportItemFilter{Limit: len(regNums)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make([]string, len(items))
+	for i, item := range items {
+		statuses[i] = item.Status
+	}
+	wantStatuses := []string{database.ItemCreated, database.ItemExisting, database.ItemNotFound, database.ItemFailed, database.ItemCreated}
+	if !slices.Equal(statuses, wantStatuses) {
+		t.Errorf("item statuses = %v, want %v", statuses, wantStatuses)
+	}
+}
+
+func TestImporterTooManyPlates(t *testing.T) {
+	store := database.NewMemoryStore()
+	im := NewImporter(context.Background(), store, &fakeProvider{}, config.ImportConfig{Workers: 1, BatchSize: 1, MaxPlates: 2})
+	defer shutdown(t, im)
+
+	regNums := []string{"A1", "B2", "C3"}
+	if _, err := im.Submit(context.Background(), regNums, regNums); !errors.Is(err, ErrTooManyPlates) {
+		t.Fatalf("error = %v, want %v", err, ErrTooManyPlates)
+	}
+	if jobs, _ := store.UnfinishedImportJobs(context.Background()); len(jobs) != 0 {
+		t.Errorf("rejected import stored %d jobs", len(jobs))
+	}
+}
+
+func TestImporterCancelAndResume(t *testing.T) {
+	store := seededStore(t)
+	provider := &fakeProvider{cars: map[string]database.Car{"X123XX150": vesta, "E555EE150": vesta}, block: make(chan struct{})}
+	im := NewImporter(context.Background(), store, provider, config.ImportConfig{Workers: 1, BatchSize: 1})
+	defer shutdown(t, im)
+	ctx := context.Background()
+
+	regNums := []string{"X123XX150", "E555EE150"}
+	job, err := im.Submit(ctx, regNums, regNums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForJob(t, store, job.ID, database.ImportRunning)
+	if err := im.Cancel(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := im.Cancel(ctx, job.ID); !errors.Is(err, database.ErrImportJobState) {
+		t.Errorf("second cancel = %v, want %v", err, database.ErrImportJobState)
+	}
+
+	// The job winds down in the background; until it has, it cannot resume.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := im.Resume(ctx, job.ID)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, database.ErrImportJobState) || time.Now().After(deadline) {
+			t.Fatalf("resume = %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(provider.block)
+
+	done := waitForJob(t, store, job.ID, database.ImportCompleted)
+	if done.Counts[database.ItemCreated] != 2 {
+		t.Errorf("counts = %v, want two created cars", done.Counts)
+	}
+	if err := im.Resume(ctx, job.ID); !errors.Is(err, database.ErrImportJobState) {
+		t.Errorf("resuming a completed job = %v, want %v", err, database.ErrImportJobState)
+	}
+}
+
+func TestImporterResumesUnfinishedJobs(t *testing.T) {
+	store := seededStore(t)
+	blocked := &fakeProvider{block: make(chan struct{})}
+	im := NewImporter(context.Background(), store, blocked, config.ImportConfig{Workers: 1, BatchSize: 1})
+
+	regNums := []string{"X123XX150", "E555EE150"}
+	job, err := im.Submit(context.Background(), regNums, regNums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForJob(t, store, job.ID, database.ImportRunning)
+	shutdown(t, im)
+
+	stopped, err := store.GetImportJob(context.Background(), job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stopped.State != database.ImportRunning || stopped.Counts[database.ItemPending] != len(regNums) {
+		t.Fatalf("stopped job = %s %v, want running with every plate pending", stopped.State, stopped.Counts)
+	}
+
+	provider := &fakeProvider{cars: map[string]database.Car{"X123XX150": vesta, "E555EE150": vesta}}
+	restarted := NewImporter(context.Background(), store, provider, config.ImportConfig{Workers: 1, BatchSize: 1})
+	defer shutdown(t, restarted)
+	if err := restarted.ResumeUnfinished(); err != nil {
+		t.Fatal(err)
+	}
+	done := waitForJob(t, store, job.ID, database.ImportCompleted)
+	if done.Counts[database.ItemCreated] != 2 {
+		t.Errorf("counts = %v, want two created cars", done.Counts)
+	}
+	if got := provider.askedFor(); len(got) != 2 {
+		t.Errorf("resumed job asked for %v, want each plate once", got)
+	}
+}
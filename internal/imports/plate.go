@@ -0,0 +1,66 @@
+// Package imports creates cars from plate lists, either one request at a time
+// or as jobs processed in the background.
+package imports
+
+import (
+	"context"
+	"errors"
+	"github.com/likimiad/car-management-api/internal/database"
+	"github.com/likimiad/car-management-api/internal/enrichment"
+)
+
+// ImportPlate looks a plate up upstream and stores the car and its owner under
+// regNum, the normalized plate. Upstream lookups may be case-sensitive, so a
+// plate the upstream does not know as normalized is asked for again as
+// spelled, the plate as submitted, when that differs. The returned item
+// carries the outcome with a message fit for clients; the error, if any, is
+// the underlying cause for logging.
+func ImportPlate(ctx context.Context, store database.Store, provider enrichment.CarInfoProvider, regNum, spelled string) (database.ImportItem, error) {
+	item := database.ImportItem{RegNum: regNum, Status: database.ItemFailed}
+
+	car, err := provider.CarInfo(ctx, regNum)
+	if errors.Is(err, enrichment.ErrNotFound) && spelled != "" && spelled != regNum {
+		car, err = provider.CarInfo(ctx, spelled)
+	}
+	switch {
+	case errors.Is(err, enrichment.ErrNotFound):
+		item.Status, item.Error = database.ItemNotFound, "car with registration number not found"
+		return item, nil
+	case errors.Is(err, enrichment.ErrCircuitOpen):
+		item.Error = "third party api is unavailable, try again later"
+		return item, err
+	case errors.Is(err, enrichment.ErrRateLimited):
+		item.Error = "third party api rate limit exceeded"
+		return item, err
+	case errors.Is(err, enrichment.ErrRejected):
+		item.Error = "third party api rejected the request"
+		return item, err
+	case errors.Is(err, enrichment.ErrBadPayload):
+		item.Error = "third party api returned invalid car data"
+		return item, err
+	case err != nil:
+		item.Error = "error with getting data from third party api"
+		return item, err
+	}
+
+	ownerId, err := store.GetOrCreateOwner(ctx, car.Owner)
+	if err != nil {
+		item.Error = "error while adding owner in database"
+		return item, err
+	}
+
+	carID, err := store.AddNewCar(ctx, regNum, car.Mark, car.Model, car.Year, ownerId, car.Provenance)
+	switch {
+	case errors.Is(err, database.ErrCarExists):
+		item.Status, item.CarID = database.ItemExisting, &carID
+		return item, nil
+	case errors.Is(err, database.ErrCarDeleted):
+		item.CarID, item.Error = &carID, "car with registration number is deleted, restore it instead"
+		return item, nil
+	case err != nil:
+		item.Error = "error while adding car in database"
+		return item, err
+	}
+	item.Status, item.CarID = database.ItemCreated, &carID
+	return item, nil
+}
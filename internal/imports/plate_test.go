@@ -0,0 +1,88 @@
+package imports
+
+import (
+	"context"
+	"errors"
+	"github.com/likimiad/car-management-api/internal/database"
+	"github.com/likimiad/car-management-api/internal/enrichment"
+	"slices"
+	"testing"
+)
+
+func TestImportPlate(t *testing.T) {
+	tests := []struct {
+		name    string
+		regNum  string
+		spelled string
+		fail    error
+		status  string
+		msg     string
+		err     bool
+		asked   []string
+	}{
+		{name: "created", regNum: "X123XX150", status: database.ItemCreated, asked: []string{"X123XX150"}},
+		{name: "existing", regNum: "A111AA150", status: database.ItemExisting, asked: []string{"A111AA150"}},
+		{
+			name: "deleted", regNum: "B222BB150", status: database.ItemFailed,
+			msg: "car with registration number is deleted, restore it instead", asked: []string{"B222BB150"},
+		},
+		{
+			name: "not found", regNum: "H777HH150", spelled: "H777HH150", status: database.ItemNotFound,
+			msg: "car with registration number not found", asked: []string{"H777HH150"},
+		},
+		{
+			name: "asked again as spelled", regNum: "E555EE150", spelled: "e555ee150", status: database.ItemCreated,
+			asked: []string{"E555EE150", "e555ee150"},
+		},
+		{
+			name: "not found either way", regNum: "H777HH150", spelled: "h777hh150", status: database.ItemNotFound,
+			msg: "car with registration number not found", asked: []string{"H777HH150", "h777hh150"},
+		},
+		{
+			name: "rate limited", regNum: "X123XX150", fail: enrichment.ErrRateLimited, status: database.ItemFailed,
+			msg: "third party api rate limit exceeded", err: true, asked: []string{"X123XX150"},
+		},
+		{
+			name: "circuit open", regNum: "X123XX150", fail: enrichment.ErrCircuitOpen, status: database.ItemFailed,
+			msg: "third party api is unavailable, try again later", err: true, asked: []string{"X123XX150"},
+		},
+		{
+			name: "upstream failed", regNum: "X123XX150", fail: errors.New("connection reset"), status: database.ItemFailed,
+			msg: "error with getting data from third party api", err: true, asked: []string{"X123XX150"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := seededStore(t)
+			provider := &fakeProvider{
+				cars: map[string]database.Car{"X123XX150": vesta, "A111AA150": vesta, "B222BB150": vesta, "e555ee150": vesta},
+				fail: tt.fail,
+			}
+
+			item, err := ImportPlate(context.Background(), store, provider, tt.regNum, tt.spelled)
+			if (err != nil) != tt.err {
+				t.Errorf("error = %v, want error %v", err, tt.err)
+			}
+			if item.RegNum != tt.regNum || item.Status != tt.status || item.Error != tt.msg {
+				t.Errorf("item = %s %s %q, want %s %s %q", item.RegNum, item.Status, item.Error, tt.regNum, tt.status, tt.msg)
+			}
+			if got := provider.askedFor(); !slices.Equal(got, tt.asked) {
+				t.Errorf("asked upstream for %v, want %v", got, tt.asked)
+			}
+
+			switch tt.status {
+			case database.ItemCreated, database.ItemExisting:
+				if item.CarID == nil {
+					t.Fatal("no car id")
+				}
+				car, err := store.GetCarByRegNum(context.Background(), tt.regNum)
+				if err != nil {
+					t.Fatalf("car not stored under %s: %v", tt.regNum, err)
+				}
+				if int64(car.ID) != *item.CarID {
+					t.Errorf("car id = %d, stored as %d", *item.CarID, car.ID)
+				}
+			}
+		})
+	}
+}
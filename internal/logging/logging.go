@@ -0,0 +1,104 @@
+// Package logging sets up the structured logger of the service and carries
+// request-scoped loggers in contexts.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"github.com/likimiad/car-management-api/internal/config"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New returns a logger writing to w in the configured format, dropping
+// records below the configured level.
+func New(w io.Writer, cfg config.LogConfig) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", cfg.Level)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(cfg.Format) {
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+}
+
+// Setup makes the configured logger, writing to stdout, the default one,
+// which the standard log package then writes through as well.
+func Setup(cfg config.LogConfig) (*slog.Logger, error) {
+	logger, err := New(os.Stdout, cfg)
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+	return logger, nil
+}
+
+type contextKey struct{}
+
+// WithLogger returns a context carrying the logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger of the context, or the default logger if
+// it carries none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Err describes an error as an "error" group holding its message and the
+// messages of the errors it wraps, outermost first.
+func Err(err error) slog.Attr {
+	if err == nil {
+		return slog.Attr{}
+	}
+	attrs := []any{slog.String("msg", err.Error())}
+	if chain := unwrapChain(err); len(chain) > 0 {
+		attrs = append(attrs, slog.Any("chain", chain))
+	}
+	return slog.Group("error", attrs...)
+}
+
+// unwrapChain lists the messages of the errors wrapped by err, depth first,
+// skipping the ones that add nothing to the message of the error wrapping
+// them.
+func unwrapChain(err error) []string {
+	var wrapped []error
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		if inner := e.Unwrap(); inner != nil {
+			wrapped = []error{inner}
+		}
+	case interface{ Unwrap() []error }:
+		wrapped = e.Unwrap()
+	}
+
+	var chain []string
+	for _, inner := range wrapped {
+		if inner == nil {
+			continue
+		}
+		if msg := inner.Error(); msg != err.Error() {
+			chain = append(chain, msg)
+		}
+		chain = append(chain, unwrapChain(inner)...)
+	}
+	return chain
+}
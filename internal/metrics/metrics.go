@@ -0,0 +1,105 @@
+// Package metrics holds the Prometheus metrics of the service and serves
+// them for scraping.
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const namespace = "car_api"
+
+// Registry holds every metric of the service along with the Go runtime and
+// process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests counts served requests by route template, method and
+	// status code.
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route template, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by route template, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	HTTPInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests being served.",
+	})
+
+	// UpstreamRequests counts calls to the car info APIs by provider and
+	// outcome: ok, not_found, rate_limited, upstream_error, bad_payload,
+	// rejected or canceled.
+	UpstreamRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_requests_total",
+		Help:      "Calls to third party car info APIs, by provider and outcome.",
+	}, []string{"provider", "outcome"})
+
+	UpstreamRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Latency of calls to third party car info APIs, by provider and outcome.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"provider", "outcome"})
+
+	// PlateWorkersBusy and PlateWorkers describe the worker pools looking up
+	// the plates of POST /api/cars: every request in progress brings its own
+	// pool, so their ratio is the utilization of the pools.
+	PlateWorkersBusy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "plate_workers_busy",
+		Help:      "Workers of POST /api/cars busy looking up a plate.",
+	})
+
+	PlateWorkers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "plate_workers",
+		Help:      "Workers of the POST /api/cars requests in progress.",
+	})
+
+	// Plates counts processed plates by source, request or import, and
+	// status: created, existing, not_found, failed or invalid.
+	Plates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "plates_total",
+		Help:      "Plates processed, by source and resulting status.",
+	}, []string{"source", "status"})
+)
+
+const (
+	SourceRequest = "request"
+	SourceImport  = "import"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPRequestDuration, HTTPInFlight,
+		UpstreamRequests, UpstreamRequestDuration,
+		PlateWorkersBusy, PlateWorkers,
+		Plates,
+	)
+}
+
+// RegisterDB exports the connection pool statistics of db.
+func RegisterDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
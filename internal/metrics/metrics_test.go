@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestHandlerExposesServiceMetrics(t *testing.T) {
+	HTTPRequests.WithLabelValues("/api/cars", http.MethodGet, "200").Inc()
+	HTTPRequestDuration.WithLabelValues("/api/cars", http.MethodGet, "200").Observe(0.01)
+	UpstreamRequests.WithLabelValues("http", "ok").Inc()
+	UpstreamRequestDuration.WithLabelValues("http", "ok").Observe(0.1)
+	Plates.WithLabelValues(SourceImport, "created").Inc()
+
+	body := scrape(t)
+	for _, want := range []string{
+		`car_api_http_requests_total{code="200",method="GET",route="/api/cars"}`,
+		`car_api_http_request_duration_seconds_bucket{code="200",method="GET",route="/api/cars",le="0.025"}`,
+		"car_api_http_requests_in_flight ",
+		`car_api_upstream_requests_total{outcome="ok",provider="http"}`,
+		`car_api_upstream_request_duration_seconds_count{outcome="ok",provider="http"}`,
+		"car_api_plate_workers_busy ",
+		"car_api_plate_workers ",
+		`car_api_plates_total{source="import",status="created"}`,
+		"go_goroutines ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape lacks %s", want)
+		}
+	}
+}
+
+func TestHandlerReportsCurrentValues(t *testing.T) {
+	PlateWorkers.Set(0)
+	PlateWorkers.Add(4)
+	PlateWorkersBusy.Set(3)
+	PlateWorkersBusy.Dec()
+
+	body := scrape(t)
+	for _, want := range []string{"car_api_plate_workers 4\n", "car_api_plate_workers_busy 2\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape lacks %q", want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Package plates normalizes registration plates and checks them against the
+// formats of the configured countries.
+package plates
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrEmptyPlate   = errors.New("registration number is empty")
+	ErrInvalidPlate = errors.New("registration number does not match any known format")
+)
+
+// lookalikes maps the Cyrillic letters used on Russian plates to the Latin
+// letters they are indistinguishable from, so both spellings name one car.
+var lookalikes = map[rune]rune{
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H',
+	'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T', 'У': 'Y', 'Х': 'X',
+}
+
+// builtin holds the formats known without configuration, matched against
+// normalized plates. RU accepts the standard private car plate (X123XX150)
+// and the two letter series used by the third party API (AB123CD456).
+var builtin = map[string][]string{
+	"RU": {
+		`^[ABEKMHOPCTYX]\d{3}[ABEKMHOPCTYX]{2}\d{2,3}$`,
+		`^[A-Z]{2}\d{3}[A-Z]{2}\d{3}$`,
+	},
+}
+
+// Normalize upper-cases a plate, drops whitespace and dashes and replaces
+// Cyrillic look-alike letters with Latin ones.
+func Normalize(plate string) string {
+	var b strings.Builder
+	for _, r := range plate {
+		if unicode.IsSpace(r) || r == '-' {
+			continue
+		}
+		r = unicode.ToUpper(r)
+		if latin, ok := lookalikes[r]; ok {
+			r = latin
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+type rule struct {
+	country  string
+	patterns []*regexp.Regexp
+}
+
+// Validator accepts plates matching the format of one of its countries.
+// Without countries every non-empty plate is accepted.
+type Validator struct {
+	rules []rule
+}
+
+// NewValidator enables the built-in formats of countries. formats adds or
+// replaces the format of a country with a regular expression matched against
+// normalized plates; a country listed only there is enabled too.
+func NewValidator(countries []string, formats map[string]string) (*Validator, error) {
+	custom := make(map[string]string, len(formats))
+	for country, format := range formats {
+		country = countryCode(country)
+		if country == "" {
+			return nil, fmt.Errorf("plate format %q has no country", format)
+		}
+		custom[country] = format
+	}
+
+	patterns := map[string][]string{}
+	for _, country := range countries {
+		country = countryCode(country)
+		if country == "" {
+			continue
+		}
+		if _, ok := builtin[country]; !ok {
+			if _, ok := custom[country]; !ok {
+				return nil, fmt.Errorf("no plate format known for country %q", country)
+			}
+		}
+		patterns[country] = builtin[country]
+	}
+	for country, format := range custom {
+		patterns[country] = []string{format}
+	}
+
+	v := &Validator{}
+	for country, exprs := range patterns {
+		r := rule{country: country}
+		for _, expr := range exprs {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid plate format for country %q: %v", country, err)
+			}
+			r.patterns = append(r.patterns, re)
+		}
+		v.rules = append(v.rules, r)
+	}
+	sort.Slice(v.rules, func(i, j int) bool { return v.rules[i].country < v.rules[j].country })
+	return v, nil
+}
+
+func countryCode(country string) string {
+	return strings.ToUpper(strings.TrimSpace(country))
+}
+
+// Validate normalizes a plate and returns it with the country whose format it
+// matches, the first in alphabetical order if several do.
+func (v *Validator) Validate(plate string) (normalized, country string, err error) {
+	normalized = Normalize(plate)
+	if normalized == "" {
+		return "", "", ErrEmptyPlate
+	}
+	if len(v.rules) == 0 {
+		return normalized, "", nil
+	}
+	for _, r := range v.rules {
+		for _, re := range r.patterns {
+			if re.MatchString(normalized) {
+				return normalized, r.country, nil
+			}
+		}
+	}
+	return normalized, "", ErrInvalidPlate
+}
+
+// Countries lists the countries whose formats are accepted.
+func (v *Validator) Countries() []string {
+	countries := make([]string, len(v.rules))
+	for i, r := range v.rules {
+		countries[i] = r.country
+	}
+	return countries
+}
@@ -0,0 +1,123 @@
+package plates
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		plate string
+		want  string
+	}{
+		{"X123XX150", "X123XX150"},
+		{"x123xx150", "X123XX150"},
+		{"Х123ХХ150", "X123XX150"},
+		{"а123вс77", "A123BC77"},
+		{" X 123 XX 150 ", "X123XX150"},
+		{"X-123-XX-150", "X123XX150"},
+		{"x\t123\nхх-150", "X123XX150"},
+		{"Ж123", "Ж123"},
+		{" - ", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.plate, func(t *testing.T) {
+			if got := Normalize(tt.plate); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.plate, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidator(t *testing.T) {
+	tests := []struct {
+		name      string
+		countries []string
+		formats   map[string]string
+		plate     string
+		want      string
+		country   string
+		err       error
+	}{
+		{name: "no countries accept anything", plate: "anything", want: "ANYTHING"},
+		{name: "no countries reject empty", plate: "  ", err: ErrEmptyPlate},
+		{name: "RU private plate", countries: []string{"RU"}, plate: "х123хх150", want: "X123XX150", country: "RU"},
+		{name: "RU two letter series", countries: []string{"RU"}, plate: "AB123CD456", want: "AB123CD456", country: "RU"},
+		{name: "RU country is trimmed and upper-cased", countries: []string{" ru "}, plate: "X123XX15", want: "X123XX15", country: "RU"},
+		{name: "RU rejects a foreign plate", countries: []string{"RU"}, plate: "1234AB-7", want: "1234AB7", err: ErrInvalidPlate},
+		{name: "RU rejects a non look-alike letter", countries: []string{"RU"}, plate: "Ж123XX150", want: "Ж123XX150", err: ErrInvalidPlate},
+		{name: "RU rejects empty", countries: []string{"RU"}, plate: "", err: ErrEmptyPlate},
+		{
+			name:    "lower-case format key",
+			formats: map[string]string{"by": `^\d{4}[A-Z]{2}\d$`},
+			plate:   "1234 AB-7", want: "1234AB7", country: "BY",
+		},
+		{
+			name:      "lower-case format key backs a listed country",
+			countries: []string{"RU", "by"},
+			formats:   map[string]string{" by ": `^\d{4}[A-Z]{2}\d$`},
+			plate:     "1234AB7", want: "1234AB7", country: "BY",
+		},
+		{
+			name:      "format replaces the built-in one",
+			countries: []string{"RU"},
+			formats:   map[string]string{"ru": `^[A-Z]\d{3}$`},
+			plate:     "X123XX150", want: "X123XX150", err: ErrInvalidPlate,
+		},
+		{
+			name:      "first country in alphabetical order wins",
+			countries: []string{"RU"},
+			formats:   map[string]string{"KZ": `^X\d{3}XX\d{3}$`},
+			plate:     "X123XX150", want: "X123XX150", country: "KZ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewValidator(tt.countries, tt.formats)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, country, err := v.Validate(tt.plate)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if got != tt.want || country != tt.country {
+				t.Errorf("Validate(%q) = %q, %q, want %q, %q", tt.plate, got, country, tt.want, tt.country)
+			}
+		})
+	}
+}
+
+func TestValidatorCountries(t *testing.T) {
+	v, err := NewValidator([]string{"ru", ""}, map[string]string{"by": `^\d{4}[A-Z]{2}\d$`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := v.Countries(), []string{"BY", "RU"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("countries = %v, want %v", got, want)
+	}
+}
+
+func TestNewValidatorErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		countries []string
+		formats   map[string]string
+		want      string
+	}{
+		{"unknown country", []string{"RU", "BY"}, nil, `no plate format known for country "BY"`},
+		{"invalid format", nil, map[string]string{"by": `^[A-Z`}, `invalid plate format for country "BY"`},
+		{"format without country", nil, map[string]string{" ": `^\d+$`}, "has no country"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewValidator(tt.countries, tt.formats)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,24 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"github.com/likimiad/car-management-api/api"
+	"github.com/likimiad/car-management-api/internal/catalog"
 	"github.com/likimiad/car-management-api/internal/config"
 	"github.com/likimiad/car-management-api/internal/database"
+	"github.com/likimiad/car-management-api/internal/enrichment"
+	"github.com/likimiad/car-management-api/internal/imports"
+	"github.com/likimiad/car-management-api/internal/logging"
+	"github.com/likimiad/car-management-api/internal/metrics"
+	"github.com/likimiad/car-management-api/internal/plates"
+	"github.com/likimiad/car-management-api/internal/tracing"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 // @title Effective Mobile Go API
@@ -12,10 +26,72 @@ import (
 // @description API Server for registration car plates in Effective Mobile
 
 func main() {
+	migrate := flag.String("migrate", "", "run a migration command (up, down, status) and exit")
+	adminToken := flag.String("admin-token", "", "print the administrator token of the given actor and exit")
+	flag.Parse()
+
 	cfg := config.GetConfig()
-	db := database.InitDatabase(cfg.DatabaseConfig)
-	server := api.NewServer(db, cfg.HTTPServer)
-	if err := server.Start(cfg.HTTPServer.Address); err != nil {
+	if _, err := logging.Setup(cfg.LogConfig); err != nil {
+		log.Fatalf("Failed to set up logging %s", err.Error())
+	}
+	if *migrate != "" {
+		if err := database.RunMigrationCommand(cfg.DatabaseConfig, *migrate); err != nil {
+			log.Fatalf("Failed to run migration command %s", err.Error())
+		}
+		return
+	}
+	if *adminToken != "" {
+		if cfg.SecretKey == "" {
+			log.Fatalf("Failed to create administrator token: SECRET_KEY is empty")
+		}
+		fmt.Println(api.AdminToken(cfg.SecretKey, *adminToken))
+		return
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingConfig)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing %s", err.Error())
+	}
+	db := database.Open(cfg.DatabaseConfig)
+	if pg, ok := db.(*database.Database); ok {
+		metrics.RegisterDB(pg.DB, "car_api")
+	}
+	enricher, err := enrichment.NewFromConfig(cfg.HTTPServer, cfg.EnrichmentConfig)
+	if err != nil {
+		log.Fatalf("Failed to create car info provider %s", err.Error())
+	}
+	syncer, err := catalog.NewSyncer(db, enricher, cfg.SyncConfig)
+	if err != nil {
+		log.Fatalf("Failed to create catalog sync %s", err.Error())
+	}
+	importer := imports.NewImporter(context.Background(), db, enricher, cfg.ImportConfig)
+	if err := importer.ResumeUnfinished(); err != nil {
+		log.Fatalf("Failed to resume import jobs %s", err.Error())
+	}
+	validator, err := plates.NewValidator(cfg.PlateConfig.Countries, cfg.PlateConfig.Formats)
+	if err != nil {
+		log.Fatalf("Failed to create plate validator %s", err.Error())
+	}
+	server := api.NewServer(db, enricher, syncer, importer, validator, cfg.HTTPServer, cfg.SyncConfig, cfg.HealthConfig, cfg.AdminConfig, cfg.SecretKey)
+	if cfg.PurgeInterval > 0 {
+		server.Go(func(ctx context.Context) {
+			database.RunPurger(ctx, db, cfg.PurgeRetention, cfg.PurgeInterval)
+		})
+	}
+	if cfg.SyncConfig.Interval > 0 {
+		server.Go(func(ctx context.Context) {
+			syncer.RunScheduled(ctx, cfg.SyncConfig.Interval)
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := server.Start(ctx, cfg.HTTPServer.Address); err != nil {
 		log.Fatalf("Failed to start server %s", err.Error())
 	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("error flushing traces", logging.Err(err))
+	}
 }
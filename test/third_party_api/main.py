@@ -1,6 +1,6 @@
-from typing import List
+from typing import List, Optional
 
-from fastapi import FastAPI, HTTPException
+from fastapi import FastAPI, HTTPException, Query
 from models import Car, CarDatabase
 
 
@@ -18,5 +18,8 @@ async def get_car_info(regNum: str):
 
 
 @app.get("/api/all_reg_numbers", response_model=List[str])
-async def get_all_reg_numbers():
-    return car_db.get_all_reg_numbers()
\ No newline at end of file
+async def get_all_reg_numbers(offset: int = Query(0, ge=0), limit: Optional[int] = Query(None, ge=1)):
+    reg_numbers = car_db.get_all_reg_numbers()
+    if limit is None:
+        return reg_numbers[offset:]
+    return reg_numbers[offset:offset + limit]
\ No newline at end of file